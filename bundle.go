@@ -0,0 +1,200 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/krylphi/autotiler/internal/exporter"
+	"github.com/krylphi/autotiler/internal/output"
+	"github.com/krylphi/autotiler/internal/unpack"
+)
+
+const (
+	bundleKey   = "bundle"
+	exporterKey = "exporter"
+)
+
+// bundleIndexName is the name of the index file in the root of a bundle.
+const bundleIndexName = "README.md"
+
+var errBundleWithOutput = errors.New("--bundle can't be combined with -o")
+
+// bundleEntry holds tile sets generated from a single input of a bundle.
+type bundleEntry struct {
+	// input is the input file as passed with -in.
+	input string
+	// dir is the directory of the tile sets in the bundle, the root if it is empty.
+	dir     string
+	outputs []*output.Tileset
+}
+
+// bundleExporters returns the manifest exporter followed by exporters passed with --exporter
+// or by all other exporters if there are none.
+func bundleExporters(args map[string][]string) ([]exporter.Exporter, error) {
+	names, ok := args[exporterKey]
+	if !ok {
+		names = exporter.Names()
+	}
+	return output.Exporters(append([]string{"manifest"}, names...))
+}
+
+// bundleDirs returns directories of the inputs in a bundle.
+// A single input is placed in the root, otherwise every input gets a directory named after its file.
+func bundleDirs(inFiles []string) []string {
+	dirs := make([]string, len(inFiles))
+	if len(inFiles) == 1 {
+		return dirs
+	}
+	seen := make(map[string]bool)
+	for i, f := range inFiles {
+		dir := "stdin"
+		if f != stdio {
+			base := filepath.Base(f)
+			dir = strings.TrimSuffix(base, filepath.Ext(base))
+		}
+		name := dir
+		for n := 2; seen[name]; n++ {
+			name = fmt.Sprintf("%s_%d", dir, n)
+		}
+		seen[name] = true
+		dirs[i] = name
+	}
+	return dirs
+}
+
+// bundleFiles generates tile sets of every input and writes them to a zip bundle at bundlePath or to stdout if it is "-".
+// Every (input, layout) pair is generated as a separate job by a pool of workers.
+//
+// Parameters:
+// - ctx: The context which cancels generation.
+// - bundlePath: The path of the zip archive.
+// - inFiles: Input files as passed with -in.
+// - unpackers: Initialized unpackers of the inputs.
+// - layouts: Names of the layouts to generate.
+// - padding: The padding of every tile in px the unpackers were created with.
+// - exporters: Exporters which files are written along with every image.
+// - workers: The maximum number of jobs running at once.
+//
+// Returns:
+// - An error if any job or writing fails. The bundle isn't written if any job fails.
+func bundleFiles(
+	ctx context.Context, bundlePath string, inFiles []string, unpackers []*unpack.Unpacker,
+	layouts []string, padding int, exporters []exporter.Exporter, workers int,
+) error {
+	dirs := bundleDirs(inFiles)
+	entries := make([]bundleEntry, len(inFiles))
+	for i := range entries {
+		entries[i] = bundleEntry{input: inFiles[i], dir: dirs[i], outputs: make([]*output.Tileset, len(layouts))}
+	}
+	err := runJobs(ctx, len(inFiles)*len(layouts), workers, func(job int) error {
+		entry := &entries[job/len(layouts)]
+		i := job % len(layouts)
+		out, err := output.Generate(ctx, unpackers[job/len(layouts)], layouts[i:i+1], padding)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", entry.input, layouts[i], err)
+		}
+		entry.outputs[i] = out[0]
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if bundlePath == stdio {
+		return writeBundle(os.Stdout, entries, exporters)
+	}
+	f, err := os.Create(bundlePath)
+	if err != nil {
+		return err
+	}
+	if err := writeBundle(f, entries, exporters); err != nil {
+		_ = f.Close()
+		_ = os.Remove(bundlePath)
+		return fmt.Errorf("%s: %w", bundlePath, err)
+	}
+	return f.Close()
+}
+
+// writeBundle writes images and exporter files of the entries along with an index to a zip archive.
+// Paths in the archive are relative, so image references of exporter files resolve after extraction.
+func writeBundle(w io.Writer, entries []bundleEntry, exporters []exporter.Exporter) error {
+	archive := zip.NewWriter(w)
+	for _, entry := range entries {
+		if err := output.AddZipFiles(archive, entry.dir, entry.outputs, exporters); err != nil {
+			return fmt.Errorf("%s: %w", entry.input, err)
+		}
+	}
+	index, err := archive.Create(bundleIndexName)
+	if err != nil {
+		return err
+	}
+	if err := writeBundleIndex(index, entries, exporters); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// writeBundleIndex writes a markdown index of the bundle listing every tile set with its files.
+func writeBundleIndex(w io.Writer, entries []bundleEntry, exporters []exporter.Exporter) error {
+	var b strings.Builder
+	b.WriteString("# Tilesets\n\n")
+	b.WriteString("Generated by autotiler from 2x3 tilesets. Paths are relative to this file, ")
+	b.WriteString("engine files reference images placed next to them.\n")
+	for _, entry := range entries {
+		source := entry.input
+		if source == stdio {
+			source = "stdin"
+		}
+		if entry.dir == "" {
+			fmt.Fprintf(&b, "\nSource: `%s`\n\n", source)
+		} else {
+			fmt.Fprintf(&b, "\n## %s\n\nSource: `%s`\n\n", entry.dir, source)
+		}
+		b.WriteString("| Layout | Image | Size | Tile | Grid | Padding | Files |\n")
+		b.WriteString("|---|---|---|---|---|---|---|\n")
+		for _, out := range entry.outputs {
+			ts := out.Description
+			files := make([]string, 0, len(exporters))
+			for _, e := range exporters {
+				files = append(files, fmt.Sprintf("[%s](%s)", e.Name(), path.Join(entry.dir, out.FileName(e))))
+			}
+			fmt.Fprintf(&b, "| %s | [%s](%s) | %dx%d | %dx%d | %dx%d | %d | %s |\n",
+				out.Layout, out.ImageName(), path.Join(entry.dir, out.ImageName()),
+				ts.ImageWidth, ts.ImageHeight, ts.TileWidth, ts.TileHeight, ts.Columns, ts.Rows, ts.Padding,
+				strings.Join(files, ", "))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,147 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/krylphi/autotiler/internal/unpack"
+)
+
+func TestBundleDirs(t *testing.T) {
+	tests := []struct {
+		in   []string
+		want []string
+	}{
+		{[]string{"a/tiles.png"}, []string{""}},
+		{[]string{"a/grass.png", "b/water.png"}, []string{"grass", "water"}},
+		{[]string{"a/tiles.png", "b/tiles.png", stdio}, []string{"tiles", "tiles_2", "stdin"}},
+	}
+	for _, tt := range tests {
+		if got := bundleDirs(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("bundleDirs(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestBundleFiles(t *testing.T) {
+	unpacker, err := loadUnpacker(exampleSource, 0, source2x3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporters, err := bundleExporters(map[string][]string{exporterKey: {"tiled"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundlePath := filepath.Join(t.TempDir(), "bundle.zip")
+	inFiles := []string{"grass.png", "water.png"}
+	err = bundleFiles(context.Background(), bundlePath, inFiles, []*unpack.Unpacker{unpacker, unpacker},
+		[]string{unpack.Layout28, unpack.Layout48Terrain1}, 0, exporters, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(bundlePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	files := make(map[string]*zip.File)
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+		files[f.Name] = f
+	}
+	var want []string
+	for _, dir := range []string{"grass", "water"} {
+		for _, layout := range []string{"14x2", "12x4_terrain1"} {
+			for _, ext := range []string{".png", ".json", ".tsx"} {
+				want = append(want, dir+"/"+layout+ext)
+			}
+		}
+	}
+	want = append(want, bundleIndexName)
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("got files %v, want %v", names, want)
+	}
+
+	// engine files reference images next to them
+	tsx := readZipFile(t, files["grass/14x2.tsx"])
+	if !strings.Contains(tsx, `source="14x2.png"`) {
+		t.Errorf("tiled file doesn't reference the image by a relative path:\n%s", tsx)
+	}
+	index := readZipFile(t, files[bundleIndexName])
+	for _, link := range []string{"(grass/14x2.png)", "(water/12x4_terrain1.tsx)", "`water.png`"} {
+		if !strings.Contains(index, link) {
+			t.Errorf("index doesn't contain %s:\n%s", link, index)
+		}
+	}
+}
+
+func TestBundleFilesCanceled(t *testing.T) {
+	unpacker, err := loadUnpacker(exampleSource, 0, source2x3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bundlePath := filepath.Join(t.TempDir(), "bundle.zip")
+	err = bundleFiles(ctx, bundlePath, []string{exampleSource}, []*unpack.Unpacker{unpacker},
+		[]string{unpack.Layout28}, 0, nil, 1)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := os.Stat(bundlePath); !os.IsNotExist(err) {
+		t.Errorf("bundle was written after cancellation: %v", err)
+	}
+}
+
+func readZipFile(t *testing.T, f *zip.File) string {
+	t.Helper()
+	if f == nil {
+		t.Fatal("missing file")
+	}
+	r, err := f.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
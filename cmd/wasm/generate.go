@@ -0,0 +1,130 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Command wasm exposes tile set generation to JavaScript. Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o autotiler.wasm ./cmd/wasm
+//
+// and run it with wasm_exec.js shipped with Go. It sets the global autotiler object with functions:
+//   - generate(png: Uint8Array, options?: {layouts?: string[], padding?: number, exporters?: string[],
+//     grid?: "square" | "iso", weights?: number[]})
+//     returns {tilesets: [{layout, png: Uint8Array, manifest: object, files: {name: string}}]} or {error: string};
+//   - layouts() returns names of layouts which can be generated;
+//   - exporters() returns names of exporters.
+package main
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+
+	"github.com/krylphi/autotiler/internal/exporter"
+	"github.com/krylphi/autotiler/internal/output"
+	"github.com/krylphi/autotiler/internal/unpack"
+)
+
+// options are options of a generate call passed from JavaScript.
+type options struct {
+	// Layouts lists layouts to generate, unpack.DefaultExportLayouts are generated if it is empty.
+	Layouts []string
+	// Padding is the padding of every tile in px.
+	Padding int
+	// Exporters lists names of exporters which files are generated along with every tile set.
+	Exporters []string
+	// Grid is output.GridSquare or output.GridIso, tiles are square if it is empty.
+	Grid string
+	// Weights are weights of random variants packed side by side in the image, see unpack.Unpacker.SetRandomVariants.
+	Weights []float64
+}
+
+// generatedTileset is a tile set generated by a generate call.
+type generatedTileset struct {
+	Layout string
+	// PNG is the encoded tile set image.
+	PNG []byte
+	// Manifest is the manifest of the tile set in JSON.
+	Manifest []byte
+	// Files holds files of the requested exporters by their names.
+	Files map[string][]byte
+}
+
+// generate generates tile sets from the encoded 2x3 tile set the same way as the CLI does.
+//
+// Parameters:
+// - src: The encoded 2x3 tile set image.
+// - opts: Generation options.
+//
+// Returns:
+// - Generated tile sets in the order of layouts.
+// - An error if the image or options are invalid.
+func generate(src []byte, opts options) ([]generatedTileset, error) {
+	layouts, err := output.Layouts(opts.Layouts)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := exporter.New("manifest")
+	if err != nil {
+		return nil, err
+	}
+	exporters, err := output.Exporters(opts.Exporters)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := unpack.Decode(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	unpacker, err := output.NewUnpacker(img, opts.Padding, opts.Grid, opts.Weights)
+	if err != nil {
+		return nil, err
+	}
+	tilesets, err := output.Generate(context.Background(), unpacker, layouts, opts.Padding)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]generatedTileset, 0, len(tilesets))
+	for _, ts := range tilesets {
+		out := generatedTileset{Layout: ts.Layout, Files: make(map[string][]byte)}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, ts.Image); err != nil {
+			return nil, err
+		}
+		out.PNG = buf.Bytes()
+		buf = bytes.Buffer{}
+		if err := manifest.Export(&buf, ts.Description); err != nil {
+			return nil, err
+		}
+		out.Manifest = buf.Bytes()
+		for _, e := range exporters {
+			buf = bytes.Buffer{}
+			if err := e.Export(&buf, ts.Description); err != nil {
+				return nil, err
+			}
+			out.Files[ts.FileName(e)] = buf.Bytes()
+		}
+		res = append(res, out)
+	}
+	return res, nil
+}
@@ -0,0 +1,147 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image/png"
+	"os"
+	"testing"
+
+	"github.com/krylphi/autotiler/internal/exporter"
+	"github.com/krylphi/autotiler/internal/output"
+	"github.com/krylphi/autotiler/internal/unpack"
+)
+
+const exampleSource = "../../examples/2x3_packed.png"
+
+func readExample(t *testing.T) []byte {
+	t.Helper()
+	src, err := os.ReadFile(exampleSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func TestGenerate(t *testing.T) {
+	tilesets, err := generate(readExample(t), options{
+		Layouts:   []string{unpack.Layout48Terrain1},
+		Padding:   1,
+		Exporters: []string{"tiled", "godot"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tilesets) != 1 {
+		t.Fatalf("got %d tilesets, want 1", len(tilesets))
+	}
+	ts := tilesets[0]
+	img, err := png.Decode(bytes.NewReader(ts.PNG))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest exporter.Tileset
+	if err := json.Unmarshal(ts.Manifest, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Name != unpack.Layout48Terrain1 || manifest.Padding != 1 ||
+		manifest.ImageWidth != img.Bounds().Dx() || manifest.ImageHeight != img.Bounds().Dy() {
+		t.Errorf("manifest %+v doesn't match image %v", manifest, img.Bounds())
+	}
+	for _, name := range []string{unpack.Layout48Terrain1 + ".tsx", unpack.Layout48Terrain1 + ".tres"} {
+		if len(ts.Files[name]) == 0 {
+			t.Errorf("missing exporter file %s in %v", name, ts.Files)
+		}
+	}
+}
+
+func TestGenerateAllLayouts(t *testing.T) {
+	tilesets, err := generate(readExample(t), options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := unpack.DefaultExportLayouts()
+	if len(tilesets) != len(want) {
+		t.Fatalf("got %d tilesets, want %d", len(tilesets), len(want))
+	}
+	for i, ts := range tilesets {
+		if ts.Layout != want[i] {
+			t.Errorf("tileset %d: got layout %s, want %s", i, ts.Layout, want[i])
+		}
+	}
+}
+
+func TestGenerateIsometricVariants(t *testing.T) {
+	src, err := os.ReadFile("../../examples/2x3_packed_variants.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tilesets, err := generate(src, options{
+		Layouts: []string{unpack.Layout48Terrain1},
+		Grid:    output.GridIso,
+		Weights: []float64{3, 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest exporter.Tileset
+	if err := json.Unmarshal(tilesets[0].Manifest, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Orientation != exporter.OrientationIsometric {
+		t.Errorf("got orientation %q, want %q", manifest.Orientation, exporter.OrientationIsometric)
+	}
+	if len(manifest.Tiles) != 2*48 || manifest.Tiles[0].Probability != 3 || manifest.Tiles[2*48-1].Probability != 1 {
+		t.Errorf("got %d tiles without weights of variants", len(manifest.Tiles))
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	src := readExample(t)
+	tests := []struct {
+		name string
+		src  []byte
+		opts options
+		want error
+	}{
+		{"not image", []byte("not an image"), options{}, unpack.ErrDecode},
+		{"unknown layout", src, options{Layouts: []string{"7x7"}}, unpack.ErrUnknownLayout},
+		{"unknown exporter", src, options{Exporters: []string{"unity"}}, exporter.ErrUnknownExporter},
+		{"invalid padding", src, options{Padding: -1}, unpack.ErrInvalidPadding},
+		{"unknown grid", src, options{Grid: "hex"}, output.ErrUnknownGrid},
+		{"invalid weights", src, options{Weights: []float64{1, 0}}, unpack.ErrInvalidWeight},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := generate(tt.src, tt.opts)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
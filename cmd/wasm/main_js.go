@@ -0,0 +1,141 @@
+//go:build js && wasm
+
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"math"
+	"syscall/js"
+
+	"github.com/krylphi/autotiler/internal/exporter"
+	"github.com/krylphi/autotiler/internal/unpack"
+)
+
+func main() {
+	register()
+	// keep the functions available to JavaScript
+	select {}
+}
+
+// register sets the global autotiler object.
+func register() {
+	js.Global().Set("autotiler", js.ValueOf(map[string]any{
+		"generate": js.FuncOf(generateJS),
+		"layouts": js.FuncOf(func(js.Value, []js.Value) any {
+			return stringsToJS(unpack.ExportLayouts())
+		}),
+		"exporters": js.FuncOf(func(js.Value, []js.Value) any {
+			return stringsToJS(exporter.Names())
+		}),
+	}))
+}
+
+// generateJS is the JavaScript binding of generate.
+func generateJS(_ js.Value, args []js.Value) any {
+	if len(args) < 1 || args[0].Type() != js.TypeObject {
+		return errorToJS("the first argument has to be Uint8Array with PNG image")
+	}
+	src := make([]byte, args[0].Get("length").Int())
+	js.CopyBytesToGo(src, args[0])
+	var opts options
+	if len(args) > 1 && args[1].Type() == js.TypeObject {
+		opts.Layouts = stringsFromJS(args[1].Get("layouts"))
+		opts.Exporters = stringsFromJS(args[1].Get("exporters"))
+		if padding := args[1].Get("padding"); padding.Type() == js.TypeNumber {
+			opts.Padding = padding.Int()
+		}
+		if grid := args[1].Get("grid"); grid.Type() == js.TypeString {
+			opts.Grid = grid.String()
+		}
+		opts.Weights = numbersFromJS(args[1].Get("weights"))
+	}
+
+	tilesets, err := generate(src, opts)
+	if err != nil {
+		return errorToJS(err.Error())
+	}
+	res := make([]any, 0, len(tilesets))
+	jsonParse := js.Global().Get("JSON").Get("parse")
+	for _, ts := range tilesets {
+		files := make(map[string]any, len(ts.Files))
+		for name, data := range ts.Files {
+			files[name] = string(data)
+		}
+		res = append(res, map[string]any{
+			"layout":   ts.Layout,
+			"png":      bytesToJS(ts.PNG),
+			"manifest": jsonParse.Invoke(string(ts.Manifest)),
+			"files":    files,
+		})
+	}
+	return map[string]any{"tilesets": res}
+}
+
+func errorToJS(msg string) any {
+	return map[string]any{"error": msg}
+}
+
+func bytesToJS(data []byte) js.Value {
+	res := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(res, data)
+	return res
+}
+
+func stringsToJS(values []string) []any {
+	res := make([]any, len(values))
+	for i, v := range values {
+		res[i] = v
+	}
+	return res
+}
+
+// stringsFromJS returns strings of the JavaScript array or nil if the value is not an array.
+func stringsFromJS(value js.Value) []string {
+	if !js.Global().Get("Array").Call("isArray", value).Bool() {
+		return nil
+	}
+	res := make([]string, value.Length())
+	for i := range res {
+		res[i] = value.Index(i).String()
+	}
+	return res
+}
+
+// numbersFromJS returns numbers of the JavaScript array or nil if the value is not an array.
+// Elements which are not numbers are NaN.
+func numbersFromJS(value js.Value) []float64 {
+	if !js.Global().Get("Array").Call("isArray", value).Bool() {
+		return nil
+	}
+	res := make([]float64, value.Length())
+	for i := range res {
+		res[i] = math.NaN()
+		if v := value.Index(i); v.Type() == js.TypeNumber {
+			res[i] = v.Float()
+		}
+	}
+	return res
+}
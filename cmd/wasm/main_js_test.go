@@ -0,0 +1,98 @@
+//go:build js && wasm
+
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"syscall/js"
+	"testing"
+
+	"github.com/krylphi/autotiler/internal/exporter"
+	"github.com/krylphi/autotiler/internal/output"
+	"github.com/krylphi/autotiler/internal/unpack"
+)
+
+func TestGenerateJS(t *testing.T) {
+	register()
+	autotiler := js.Global().Get("autotiler")
+	src := readExample(t)
+	arr := js.Global().Get("Uint8Array").New(len(src))
+	js.CopyBytesToJS(arr, src)
+	opts := js.ValueOf(map[string]any{
+		"layouts":   []any{unpack.Layout16Terrain1},
+		"exporters": []any{"tiled"},
+		"padding":   1,
+	})
+
+	res := autotiler.Call("generate", arr, opts)
+	if e := res.Get("error"); !e.IsUndefined() {
+		t.Fatal(e.String())
+	}
+	tilesets := res.Get("tilesets")
+	if tilesets.Length() != 1 {
+		t.Fatalf("got %d tilesets, want 1", tilesets.Length())
+	}
+	ts := tilesets.Index(0)
+	if got := ts.Get("layout").String(); got != unpack.Layout16Terrain1 {
+		t.Errorf("got layout %s, want %s", got, unpack.Layout16Terrain1)
+	}
+	data := make([]byte, ts.Get("png").Length())
+	js.CopyBytesToGo(data, ts.Get("png"))
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ts.Get("manifest").Get("imageWidth").Int(); got != img.Bounds().Dx() {
+		t.Errorf("manifest imageWidth %d, image width %d", got, img.Bounds().Dx())
+	}
+	if ts.Get("files").Get(unpack.Layout16Terrain1+".tsx").Type() != js.TypeString {
+		t.Error("missing tiled file")
+	}
+
+	if got := autotiler.Call("layouts").Length(); got != len(unpack.ExportLayouts()) {
+		t.Errorf("got %d layouts, want %d", got, len(unpack.ExportLayouts()))
+	}
+	if res := autotiler.Call("generate", js.Null()); res.Get("error").Type() != js.TypeString {
+		t.Error("expected error for missing image")
+	}
+	opts = js.ValueOf(map[string]any{
+		"layouts": []any{unpack.Layout16Terrain1},
+		"grid":    output.GridIso,
+	})
+	res = autotiler.Call("generate", arr, opts)
+	if e := res.Get("error"); !e.IsUndefined() {
+		t.Fatal(e.String())
+	}
+	if got := res.Get("tilesets").Index(0).Get("manifest").Get("orientation").String(); got != exporter.OrientationIsometric {
+		t.Errorf("got orientation %s, want %s", got, exporter.OrientationIsometric)
+	}
+	opts = js.ValueOf(map[string]any{"weights": []any{1, "two"}})
+	if res := autotiler.Call("generate", arr, opts); res.Get("error").Type() != js.TypeString {
+		t.Error("expected error for weights which are not numbers")
+	}
+}
@@ -0,0 +1,82 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+
+	"github.com/krylphi/autotiler/internal/unpack"
+)
+
+var errMissingConvertLayouts = errors.New("both -from and -to layouts are required")
+
+// convert remaps blob tile sets passed with -in from -from layout to -to layout.
+func convert(ctx context.Context, args map[string][]string) error {
+	inFiles, ok := args[inKey]
+	if !ok {
+		return errMissingInput
+	}
+	froms, fromOk := args[fromKey]
+	tos, toOk := args[toKey]
+	if !fromOk || !toOk {
+		return errMissingConvertLayouts
+	}
+	from, err := unpack.NewBlobLayout(froms[0])
+	if err != nil {
+		return fmt.Errorf("-%s: %w", fromKey, err)
+	}
+	to, err := unpack.NewBlobLayout(tos[0])
+	if err != nil {
+		return fmt.Errorf("-%s: %w", toKey, err)
+	}
+	padding, err := parsePadding(args)
+	if err != nil {
+		return err
+	}
+	outFiles := args[outKey]
+	for i, inputFile := range inFiles {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		outputFile := fmt.Sprintf("%d.local.png", i)
+		if len(outFiles) > i {
+			outputFile = outFiles[i]
+		}
+		img, err := decodeImage(inputFile)
+		if err != nil {
+			return err
+		}
+		err = produceTileset(func() (*image.NRGBA, error) {
+			return unpack.ConvertLayout(img, from, to, padding)
+		}, outputFile, to.Name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", inputFile, err)
+		}
+	}
+	return nil
+}
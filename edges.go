@@ -0,0 +1,72 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/krylphi/autotiler/internal/exporter"
+	"github.com/krylphi/autotiler/internal/output"
+	"github.com/krylphi/autotiler/internal/unpack"
+)
+
+// edges generates edge Wang tile sets from strips of pieces passed with -in.
+// Images are written to -o and files of the manifest and exporters passed with --exporter next to them.
+func edges(ctx context.Context, args map[string][]string) error {
+	inFiles, ok := args[inKey]
+	if !ok {
+		return errMissingInput
+	}
+	padding, err := parsePadding(args)
+	if err != nil {
+		return err
+	}
+	exporters, err := output.Exporters(append([]string{"manifest"}, args[exporterKey]...))
+	if err != nil {
+		return err
+	}
+	outFiles := args[outKey]
+	for i, inputFile := range inFiles {
+		outputFile := fmt.Sprintf("%d.local.png", i)
+		if len(outFiles) > i {
+			outputFile = outFiles[i]
+		}
+		img, err := decodeImage(inputFile)
+		if err != nil {
+			return err
+		}
+		res, err := unpack.FromEdgeStrip(ctx, img, padding, nil)
+		if err != nil {
+			return fmt.Errorf("%s: %w", inputFile, err)
+		}
+		ts := exporter.NewEdgeWangTileset(filepath.Base(outputFile), res.Rect.Dx(), res.Rect.Dy(), padding)
+		if err := writeTilesetFiles(outputFile, res, ts, exporters); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/krylphi/autotiler/internal/exporter"
+	"github.com/krylphi/autotiler/internal/unpack"
+)
+
+const exampleEdgeStrip = "examples/6x1_edge.png"
+
+func TestEdges(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "roads.png")
+	args := map[string][]string{
+		inKey:       {exampleEdgeStrip},
+		outKey:      {out},
+		exporterKey: {"tiled"},
+	}
+	if err := edges(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+	img, err := decodeImage(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size.X != 4*32 || size.Y != 4*32 {
+		t.Errorf("got %v, want 4x4 tiles of 32 px", size)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "roads.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ts exporter.Tileset
+	if err := json.Unmarshal(data, &ts); err != nil {
+		t.Fatal(err)
+	}
+	if ts.Name != unpack.LayoutEdgeWang16 || ts.WangType != exporter.WangEdge || len(ts.Tiles) != 16 {
+		t.Errorf("unexpected manifest %s of %s type with %d tiles", ts.Name, ts.WangType, len(ts.Tiles))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "roads.tsx")); err != nil {
+		t.Error(err)
+	}
+
+	if err := edges(context.Background(), map[string][]string{}); !errors.Is(err, errMissingInput) {
+		t.Errorf("no input: got %v", err)
+	}
+	args[inKey] = []string{exampleSource}
+	if err := edges(context.Background(), args); !errors.Is(err, unpack.ErrInvalidTileSize) {
+		t.Errorf("2x3 input: got %v", err)
+	}
+}
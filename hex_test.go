@@ -0,0 +1,86 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/krylphi/autotiler/internal/exporter"
+	"github.com/krylphi/autotiler/internal/unpack"
+)
+
+const exampleHexStrip = "examples/2x1_hex_flat.png"
+
+func TestHex(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "coast.png")
+	args := map[string][]string{
+		inKey:          {exampleHexStrip},
+		outKey:         {out},
+		orientationKey: {"flat"},
+		exporterKey:    {"tiled"},
+	}
+	if err := hex(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+	img, err := decodeImage(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size.X != 8*64 || size.Y != 8*56 {
+		t.Errorf("got %v, want 8x8 tiles of 64x56 px", size)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "coast.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ts exporter.Tileset
+	if err := json.Unmarshal(data, &ts); err != nil {
+		t.Fatal(err)
+	}
+	if ts.Name != unpack.LayoutHex64 || ts.StaggerAxis != "x" || len(ts.Tiles) != 64 {
+		t.Errorf("unexpected manifest %s staggered along %s with %d tiles", ts.Name, ts.StaggerAxis, len(ts.Tiles))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "coast.tsx")); err != nil {
+		t.Error(err)
+	}
+
+	args[exporterKey] = []string{"godot"}
+	if err := hex(context.Background(), args); !errors.Is(err, exporter.ErrUnsupportedTileset) {
+		t.Errorf("godot: got %v", err)
+	}
+	args[orientationKey] = []string{"round"}
+	if err := hex(context.Background(), args); !errors.Is(err, unpack.ErrUnknownOrientation) {
+		t.Errorf("unknown orientation: got %v", err)
+	}
+	if err := hex(context.Background(), map[string][]string{}); !errors.Is(err, errMissingInput) {
+		t.Errorf("no input: got %v", err)
+	}
+}
@@ -0,0 +1,372 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package exporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/krylphi/autotiler/internal/unpack"
+)
+
+func newTestTileset(t *testing.T, layout string) *Tileset {
+	t.Helper()
+	info, err := unpack.DescribeLayout(layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, err := NewTileset(layout, "images/"+layout+".png", info.Cols*66, info.Rows*66, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+func export(t *testing.T, name string, ts *Tileset) []byte {
+	t.Helper()
+	e, err := New(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := e.Export(&buf, ts); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestManifest(t *testing.T) {
+	ts := newTestTileset(t, unpack.Layout48Terrain1)
+	var got Tileset
+	if err := json.Unmarshal(export(t, "manifest", ts), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&got, ts) {
+		t.Errorf("manifest doesn't round trip:\n%+v\n%+v", got, *ts)
+	}
+	if ts.TileWidth != 64 || ts.TileHeight != 64 || len(ts.Tiles) != 48 {
+		t.Errorf("unexpected tile set %dx%d px, %d tiles", ts.TileWidth, ts.TileHeight, len(ts.Tiles))
+	}
+}
+
+func TestTiledWangIDsAreUnique(t *testing.T) {
+	for _, layout := range unpack.ExportLayouts() {
+		var tsx tsxTileset
+		if err := xml.Unmarshal(export(t, "tiled", newTestTileset(t, layout)), &tsx); err != nil {
+			t.Fatal(err)
+		}
+		if tsx.Image.Source != "images/"+layout+".png" || tsx.Margin != 1 || tsx.Spacing != 2 {
+			t.Errorf("%s: unexpected image %+v, margin %d, spacing %d", layout, tsx.Image, tsx.Margin, tsx.Spacing)
+		}
+		seen := make(map[string]int)
+		for _, tile := range tsx.WangSets[0].Tiles {
+			if other, ok := seen[tile.WangID]; ok {
+				t.Errorf("%s: tiles %d and %d have the same wang id %s", layout, other, tile.TileID, tile.WangID)
+			}
+			seen[tile.WangID] = tile.TileID
+		}
+		if layout == unpack.Layout48Terrain1 && len(seen) != 47 {
+			t.Errorf("%s: %d wang tiles, want 47 (all but the isolated one)", layout, len(seen))
+		}
+	}
+}
+
+func TestGodotBitmasks(t *testing.T) {
+	flag := regexp.MustCompile(`Vector2\( (\d+), (\d+) \), (\d+)`)
+	for _, layout := range []string{unpack.Layout48Terrain1, unpack.Layout48Terrain2} {
+		tres := export(t, "godot", newTestTileset(t, layout))
+		if !bytes.Contains(tres, []byte(`path="res://images/`+layout+`.png"`)) {
+			t.Errorf("%s: texture path is missing", layout)
+		}
+		seen := make(map[int]bool)
+		for _, m := range flag.FindAllSubmatch(tres, -1) {
+			bitmask, _ := strconv.Atoi(string(m[3]))
+			if bitmask&16 == 0 {
+				t.Errorf("%s: tile %s,%s bitmask %d lacks the center", layout, m[1], m[2], bitmask)
+			}
+			if seen[bitmask] {
+				t.Errorf("%s: bitmask %d is used twice", layout, bitmask)
+			}
+			seen[bitmask] = true
+		}
+		if len(seen) != 47 {
+			t.Errorf("%s: %d tiles have bitmasks, want 47", layout, len(seen))
+		}
+	}
+}
+
+func TestUnknown(t *testing.T) {
+	if _, err := New("unity"); !errors.Is(err, ErrUnknownExporter) {
+		t.Errorf("got %v", err)
+	}
+	if _, err := NewTileset("3x3", "x.png", 10, 10, 0); !errors.Is(err, unpack.ErrUnknownLayout) {
+		t.Errorf("got %v", err)
+	}
+	if got := Names(); !reflect.DeepEqual(got, []string{"godot", "manifest", "tiled"}) {
+		t.Errorf("names %v", got)
+	}
+}
+
+func TestCornerWangTileset(t *testing.T) {
+	terrains := []string{"grass", "dirt", "water"}
+	ts := NewCornerWangTileset(terrains, "terrains.png", 9*34, 9*34, 1)
+	if ts.TileWidth != 32 || ts.Columns != 9 || len(ts.Tiles) != 81 {
+		t.Fatalf("unexpected tile set %d px, %d columns, %d tiles", ts.TileWidth, ts.Columns, len(ts.Tiles))
+	}
+	var manifest Tileset
+	if err := json.Unmarshal(export(t, "manifest", ts), &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&manifest, ts) {
+		t.Errorf("manifest doesn't round trip:\n%+v\n%+v", manifest, *ts)
+	}
+	if got := manifest.Tiles[5].Corners; !reflect.DeepEqual(got, []int{1, 1, 2, 3}) {
+		t.Errorf("tile 5 corners %v", got)
+	}
+	// three terrains meet in the top left quarter of tile 5, tile 0 is grass only
+	if !manifest.Tiles[5].Approximate || manifest.Tiles[0].Approximate {
+		t.Errorf("tile 5 approximate %v, tile 0 approximate %v", manifest.Tiles[5].Approximate, manifest.Tiles[0].Approximate)
+	}
+
+	var tsx tsxTileset
+	if err := xml.Unmarshal(export(t, "tiled", ts), &tsx); err != nil {
+		t.Fatal(err)
+	}
+	wangSet := tsx.WangSets[0]
+	if wangSet.Type != "corner" || len(wangSet.Colors) != 3 || wangSet.Colors[2].Name != "water" {
+		t.Fatalf("unexpected wang set %s with colors %+v", wangSet.Type, wangSet.Colors)
+	}
+	// three terrains meet in a quarter of 32 of the 36 tiles with three corner terrains
+	if len(wangSet.Tiles) != 81-32 {
+		t.Errorf("got %d wang tiles, want %d", len(wangSet.Tiles), 81-32)
+	}
+	seen := make(map[string]bool)
+	wangIDs := make(map[int]string)
+	for _, tile := range wangSet.Tiles {
+		if seen[tile.WangID] {
+			t.Errorf("wang id %s is used twice", tile.WangID)
+		}
+		seen[tile.WangID] = true
+		wangIDs[tile.TileID] = tile.WangID
+		if ts.Tiles[tile.TileID].Approximate {
+			t.Errorf("approximate tile %d is in the wang set", tile.TileID)
+		}
+	}
+	// top, top right, right, bottom right, bottom, bottom left, left, top left
+	if got := wangIDs[4]; got != "0,1,0,2,0,2,0,1" {
+		t.Errorf("tile 4 wang id %s", got)
+	}
+
+	e, err := New("godot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Export(&bytes.Buffer{}, ts); !errors.Is(err, ErrUnsupportedTileset) {
+		t.Errorf("godot: got %v", err)
+	}
+}
+
+func TestEdgeWangTileset(t *testing.T) {
+	ts := NewEdgeWangTileset("roads.png", 4*34, 4*34, 1)
+	if ts.TileWidth != 32 || len(ts.Tiles) != 16 || ts.WangType != WangEdge {
+		t.Fatalf("unexpected %s tile set of %d px with %d tiles", ts.WangType, ts.TileWidth, len(ts.Tiles))
+	}
+	if tile := ts.Tiles[unpack.EdgeNorth|unpack.EdgeWest]; tile.Mask != uint8(unpack.North|unpack.West) ||
+		tile.Grid != [3][3]int{{0, 1, 0}, {1, 1, 0}, {0, 0, 0}} {
+		t.Errorf("unexpected tile %+v", tile)
+	}
+	var tsx tsxTileset
+	if err := xml.Unmarshal(export(t, "tiled", ts), &tsx); err != nil {
+		t.Fatal(err)
+	}
+	wangSet := tsx.WangSets[0]
+	if wangSet.Type != "edge" || len(wangSet.Colors) != 1 || len(wangSet.Tiles) != 16 {
+		t.Fatalf("unexpected %s wang set with %d colors and %d tiles", wangSet.Type, len(wangSet.Colors), len(wangSet.Tiles))
+	}
+	// top, top right, right, bottom right, bottom, bottom left, left, top left
+	if got := wangSet.Tiles[unpack.EdgeEast|unpack.EdgeSouth].WangID; got != "0,0,1,0,1,0,0,0" {
+		t.Errorf("east and south wang id %s", got)
+	}
+	if got := wangSet.Tiles[0].WangID; got != "0,0,0,0,0,0,0,0" {
+		t.Errorf("no connections wang id %s", got)
+	}
+}
+
+func TestIsometric(t *testing.T) {
+	ts := newTestTileset(t, unpack.Layout48Terrain1)
+	ts.Orientation = OrientationIsometric
+	var tsx tsxTileset
+	if err := xml.Unmarshal(export(t, "tiled", ts), &tsx); err != nil {
+		t.Fatal(err)
+	}
+	if tsx.Grid == nil || *tsx.Grid != (tsxGrid{Orientation: "isometric", Width: 64, Height: 64}) {
+		t.Errorf("unexpected grid %+v", tsx.Grid)
+	}
+	if !bytes.Contains(export(t, "godot", ts), []byte("mode = 1")) {
+		t.Error("godot tile set doesn't mention the isometric mode")
+	}
+	ts.Orientation = ""
+	var orthogonal tsxTileset
+	if err := xml.Unmarshal(export(t, "tiled", ts), &orthogonal); err != nil || orthogonal.Grid != nil {
+		t.Errorf("orthogonal tile set has grid %+v: %v", orthogonal.Grid, err)
+	}
+}
+
+func TestHexTileset(t *testing.T) {
+	ts, err := NewHexTileset(unpack.HexPointy, "hex.png", 8*58, 8*66, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts.TileWidth != 56 || ts.TileHeight != 64 || len(ts.Tiles) != 64 || ts.StaggerAxis != "y" || ts.HexSideLength != 32 {
+		t.Fatalf("unexpected tile set %dx%d px, %d tiles, stagger axis %s, side %d px",
+			ts.TileWidth, ts.TileHeight, len(ts.Tiles), ts.StaggerAxis, ts.HexSideLength)
+	}
+	var manifest Tileset
+	if err := json.Unmarshal(export(t, "manifest", ts), &manifest); err != nil || !reflect.DeepEqual(&manifest, ts) {
+		t.Errorf("manifest doesn't round trip: %v", err)
+	}
+	var tsx tsxTileset
+	if err := xml.Unmarshal(export(t, "tiled", ts), &tsx); err != nil {
+		t.Fatal(err)
+	}
+	if len(tsx.WangSets) != 0 || len(tsx.Properties) != 3 || tsx.Properties[1].Value != "y" || len(tsx.Tiles) != 64 {
+		t.Fatalf("unexpected tile set with %d wang sets, properties %+v and %d tiles",
+			len(tsx.WangSets), tsx.Properties, len(tsx.Tiles))
+	}
+	if got := tsx.Tiles[0b100101].Properties[1].Value; got != "NE,SE,NW" {
+		t.Errorf("sides of tile 37 %s", got)
+	}
+	e, err := New("godot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Export(&bytes.Buffer{}, ts); !errors.Is(err, ErrUnsupportedTileset) {
+		t.Errorf("godot: got %v", err)
+	}
+
+	flat, err := NewHexTileset(unpack.HexFlat, "hex.png", 8*66, 8*58, 1)
+	if err != nil || flat.StaggerAxis != "x" || flat.HexSideLength != 32 || flat.Sides[0] != "N" {
+		t.Errorf("unexpected flat tile set %+v: %v", flat, err)
+	}
+	if _, err := NewHexTileset("round", "hex.png", 8, 8, 0); !errors.Is(err, unpack.ErrUnknownOrientation) {
+		t.Errorf("unknown orientation: got %v", err)
+	}
+}
+
+func TestDualGridTileset(t *testing.T) {
+	ts := newTestTileset(t, unpack.LayoutDualGrid16)
+	if ts.WangType != WangCorner || len(ts.Tiles) != 16 || ts.DisplayOffsetX != -32 || ts.DisplayOffsetY != -32 {
+		t.Fatalf("unexpected %s tile set with %d tiles offset by %d,%d",
+			ts.WangType, len(ts.Tiles), ts.DisplayOffsetX, ts.DisplayOffsetY)
+	}
+	// references/4x4_bitmask_reference_2x2.png: the first tile has terrain 1 in the bottom left corner
+	if got := ts.Tiles[0].Corners; !reflect.DeepEqual(got, []int{2, 2, 1, 2}) {
+		t.Errorf("tile 0 corners %v", got)
+	}
+	var manifest Tileset
+	if err := json.Unmarshal(export(t, "manifest", ts), &manifest); err != nil || !reflect.DeepEqual(&manifest, ts) {
+		t.Errorf("manifest doesn't round trip: %v", err)
+	}
+
+	var tsx tsxTileset
+	if err := xml.Unmarshal(export(t, "tiled", ts), &tsx); err != nil {
+		t.Fatal(err)
+	}
+	if tsx.TileOffset == nil || *tsx.TileOffset != (tsxTileOffset{X: -32, Y: -32}) {
+		t.Errorf("unexpected tile offset %+v", tsx.TileOffset)
+	}
+	if wangSet := tsx.WangSets[0]; wangSet.Type != "corner" || len(wangSet.Tiles) != 16 {
+		t.Errorf("unexpected %s wang set with %d tiles", wangSet.Type, len(wangSet.Tiles))
+	}
+
+	tres := export(t, "godot", ts)
+	if !bytes.Contains(tres, []byte("bitmask_mode = 0")) || !bytes.Contains(tres, []byte("position = Vector2( -32, -32 )")) {
+		t.Errorf("godot tile set isn't a 2x2 autotile offset by half a tile:\n%s", tres)
+	}
+	flag := regexp.MustCompile(`Vector2\( (\d+), (\d+) \), (\d+)`)
+	seen := make(map[string]bool)
+	for _, m := range flag.FindAllSubmatch(tres, -1) {
+		if seen[string(m[3])] {
+			t.Errorf("bitmask %s is used twice", m[3])
+		}
+		seen[string(m[3])] = true
+	}
+	// the tile without terrain 1 has no bitmask, bottom left is 64
+	if len(seen) != 15 || !seen["64"] || !seen["325"] {
+		t.Errorf("unexpected bitmasks %v", seen)
+	}
+}
+
+func TestVariantTileset(t *testing.T) {
+	layout := unpack.Layout48Terrain1
+	ts, err := NewVariantTileset(layout, layout+".png", 12*66, 2*4*66, 1, []float64{3, 1.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts.TileHeight != 64 || ts.Rows != 8 || len(ts.Tiles) != 96 {
+		t.Fatalf("unexpected tile set of %d px tiles, %d rows, %d tiles", ts.TileHeight, ts.Rows, len(ts.Tiles))
+	}
+	base, variant := ts.Tiles[5], ts.Tiles[48+5]
+	if variant.Y != base.Y+4 || variant.ID != base.ID+48 || variant.Mask != base.Mask ||
+		variant.Variant != 1 || base.Probability != 3 || variant.Probability != 1.5 {
+		t.Errorf("unexpected base tile %+v and variant %+v", base, variant)
+	}
+	var manifest Tileset
+	if err := json.Unmarshal(export(t, "manifest", ts), &manifest); err != nil || !reflect.DeepEqual(&manifest, ts) {
+		t.Errorf("manifest doesn't round trip: %v", err)
+	}
+
+	var tsx tsxTileset
+	if err := xml.Unmarshal(export(t, "tiled", ts), &tsx); err != nil {
+		t.Fatal(err)
+	}
+	if len(tsx.Tiles) != 96 || tsx.Tiles[48].Probability != 1.5 {
+		t.Fatalf("unexpected %d tiles with probabilities", len(tsx.Tiles))
+	}
+	wangIDs := make(map[int]string)
+	for _, tile := range tsx.WangSets[0].Tiles {
+		wangIDs[tile.TileID] = tile.WangID
+	}
+	if wangIDs[base.ID] == "" || wangIDs[variant.ID] != wangIDs[base.ID] {
+		t.Errorf("variant wang id %q, base %q", wangIDs[variant.ID], wangIDs[base.ID])
+	}
+
+	tres := string(export(t, "godot", ts))
+	if !strings.Contains(tres, fmt.Sprintf("Vector3( %d, %d, 2 )", base.X, base.Y)) ||
+		!strings.Contains(tres, fmt.Sprintf("Vector3( %d, %d, 1 )", variant.X, variant.Y)) {
+		t.Errorf("godot priorities don't follow probabilities:\n%s", tres)
+	}
+	if plain := export(t, "godot", newTestTileset(t, layout)); !bytes.Contains(plain, []byte("priority_map = [  ]")) {
+		t.Error("tile set without variants has priorities")
+	}
+}
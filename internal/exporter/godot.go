@@ -0,0 +1,186 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strings"
+
+	"github.com/krylphi/autotiler/internal/unpack"
+)
+
+// godot writes the tile set as a Godot 3 TileSet resource (.tres) with a single 3x3 minimal autotile.
+// The autotile is drawn with the terrain most tiles of the tile set are blobs of;
+// tiles of blobs of the other terrain and background tiles are left out of it.
+// The dual grid tile set becomes a 2x2 autotile drawn with terrain 1.
+// Probabilities of random variants become priorities of tiles, which Godot uses as weights of tiles
+// with the same bitmask.
+type godot struct{}
+
+func (godot) Name() string {
+	return "godot"
+}
+
+func (godot) Ext() string {
+	return ".tres"
+}
+
+// Values of autotile/bitmask_mode.
+const (
+	// godotBitmaskMode2x2 matches terrains at corners of tiles.
+	godotBitmaskMode2x2 = 0
+	// godotBitmaskModeMinimal matches 3x3 minimal blobs.
+	godotBitmaskModeMinimal = 1
+)
+
+// godotTerrain returns the terrain most tiles of the tile set are blobs of.
+func godotTerrain(ts *Tileset) int {
+	counts := make(map[int]int)
+	res := 0
+	for _, tile := range ts.Tiles {
+		counts[tile.Terrain]++
+		if counts[tile.Terrain] > counts[res] {
+			res = tile.Terrain
+		}
+	}
+	return res
+}
+
+// godotBitmaskCenter is the bit of the center cell of an autotile bitmask.
+const godotBitmaskCenter = 1 << 4
+
+// godotBitmask returns the autotile bitmask of the tile or 0 if the tile is not a blob of the given terrain.
+// Bits are set for the center and the sides and corners of the terrain grid covered by the terrain,
+// from the top left cell (1) to the bottom right one (256).
+func godotBitmask(tile Tile, terrain int) int {
+	if tile.Background || tile.Terrain != terrain {
+		return 0
+	}
+	res := godotBitmaskCenter
+	for y, row := range tile.Grid {
+		for x, cellTerrain := range row {
+			if cellTerrain == terrain {
+				res |= 1 << (y*3 + x)
+			}
+		}
+	}
+	return res
+}
+
+// godotCornerBitmask returns the 2x2 autotile bitmask of a corner Wang tile: bits of the top left (1),
+// top right (4), bottom left (64) and bottom right (256) cells are set if the corner is the given terrain.
+func godotCornerBitmask(tile Tile, terrain int) int {
+	res := 0
+	for corner, cornerTerrain := range tile.Corners {
+		if cornerTerrain == terrain {
+			res |= 1 << (corner/2*6 + corner%2*2)
+		}
+	}
+	return res
+}
+
+// godotPriorities returns autotile priorities of the tiles with bitmasks: probabilities divided by the lowest one
+// and rounded, as priorities are integers. It returns nil if the tile set has no random variants.
+func godotPriorities(tiles []Tile) []string {
+	lowest := 0.0
+	for _, tile := range tiles {
+		if tile.Probability > 0 && (lowest == 0 || tile.Probability < lowest) {
+			lowest = tile.Probability
+		}
+	}
+	if lowest == 0 {
+		return nil
+	}
+	res := make([]string, 0, len(tiles))
+	for _, tile := range tiles {
+		priority := max(int(math.Round(tile.Probability/lowest)), 1)
+		res = append(res, fmt.Sprintf("Vector3( %d, %d, %d )", tile.X, tile.Y, priority))
+	}
+	return res
+}
+
+func (godot) Export(w io.Writer, ts *Tileset) error {
+	mode, tileBitmask := godotBitmaskModeMinimal, godotBitmask
+	switch {
+	case ts.Name == unpack.LayoutDualGrid16:
+		mode, tileBitmask = godotBitmaskMode2x2, godotCornerBitmask
+	case ts.WangType != "":
+		return fmt.Errorf("%w: godot autotiles are blobs of two terrains, got a %s wang tile set", ErrUnsupportedTileset, ts.WangType)
+	}
+	if ts.Orientation == OrientationHexagonal {
+		return fmt.Errorf("%w: godot autotiles have no hex bitmasks", ErrUnsupportedTileset)
+	}
+	terrain := godotTerrain(ts)
+	var flags []string
+	var masked []Tile
+	for _, tile := range ts.Tiles {
+		if bitmask := tileBitmask(tile, terrain); bitmask != 0 {
+			flags = append(flags, fmt.Sprintf("Vector2( %d, %d ), %d", tile.X, tile.Y, bitmask))
+			masked = append(masked, tile)
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "[gd_resource type=\"TileSet\" load_steps=2 format=2]\n\n")
+	fmt.Fprintf(&b, "[ext_resource path=\"res://%s\" type=\"Texture\" id=1]\n\n", path.Clean(ts.Image))
+	if ts.Orientation == OrientationIsometric {
+		// TileSet resources of Godot 3 have no orientation, it is a property of the TileMap
+		fmt.Fprintf(&b, "; isometric tiles: use a TileMap with mode = 1 and cell_size = Vector2( %d, %d )\n\n",
+			ts.TileWidth, ts.TileHeight)
+	}
+	if ts.DisplayOffsetX != 0 || ts.DisplayOffsetY != 0 {
+		fmt.Fprintf(&b, "; dual grid: draw tiles on a TileMap at position = Vector2( %d, %d ) over the logic grid\n\n",
+			ts.DisplayOffsetX, ts.DisplayOffsetY)
+	}
+	fmt.Fprintf(&b, "[resource]\n")
+	fmt.Fprintf(&b, "0/name = %q\n", ts.Name)
+	fmt.Fprintf(&b, "0/texture = ExtResource( 1 )\n")
+	fmt.Fprintf(&b, "0/tex_offset = Vector2( 0, 0 )\n")
+	fmt.Fprintf(&b, "0/modulate = Color( 1, 1, 1, 1 )\n")
+	fmt.Fprintf(&b, "0/region = Rect2( %d, %d, %d, %d )\n",
+		ts.Padding, ts.Padding, ts.ImageWidth-ts.Padding*2, ts.ImageHeight-ts.Padding*2)
+	fmt.Fprintf(&b, "0/tile_mode = 1\n")
+	fmt.Fprintf(&b, "0/autotile/bitmask_mode = %d\n", mode)
+	fmt.Fprintf(&b, "0/autotile/bitmask_flags = [ %s ]\n", strings.Join(flags, ", "))
+	fmt.Fprintf(&b, "0/autotile/icon_coordinate = Vector2( 0, 0 )\n")
+	fmt.Fprintf(&b, "0/autotile/tile_size = Vector2( %d, %d )\n", ts.TileWidth, ts.TileHeight)
+	fmt.Fprintf(&b, "0/autotile/spacing = %d\n", ts.Padding*2)
+	fmt.Fprintf(&b, "0/autotile/occluder_map = [  ]\n")
+	fmt.Fprintf(&b, "0/autotile/navpoly_map = [  ]\n")
+	fmt.Fprintf(&b, "0/autotile/priority_map = [ %s ]\n", strings.Join(godotPriorities(masked), ", "))
+	fmt.Fprintf(&b, "0/autotile/z_index_map = [  ]\n")
+	fmt.Fprintf(&b, "0/occluder_offset = Vector2( 0, 0 )\n")
+	fmt.Fprintf(&b, "0/navigation_offset = Vector2( 0, 0 )\n")
+	fmt.Fprintf(&b, "0/shape_offset = Vector2( 0, 0 )\n")
+	fmt.Fprintf(&b, "0/shape_transform = Transform2D( 1, 0, 0, 1, 0, 0 )\n")
+	fmt.Fprintf(&b, "0/shape_one_way = false\n")
+	fmt.Fprintf(&b, "0/shape_one_way_margin = 0.0\n")
+	fmt.Fprintf(&b, "0/shapes = [  ]\n")
+	fmt.Fprintf(&b, "0/z_index = 0\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
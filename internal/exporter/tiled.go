@@ -0,0 +1,275 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package exporter
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// tiled writes the tile set as a Tiled tile set (.tsx) with a mixed wang set of both terrains,
+// a corner wang set of all terrains of a multi-terrain tile set or an edge wang set of connections.
+// Wang sets can't describe six sides of hex tiles, so hex tile sets list sides of terrain 2 in tile properties.
+type tiled struct{}
+
+func (tiled) Name() string {
+	return "tiled"
+}
+
+func (tiled) Ext() string {
+	return ".tsx"
+}
+
+// tiledWangOrder lists positions of the terrain grid in the order of Tiled wang ids:
+// top, top right, right, bottom right, bottom, bottom left, left, top left.
+//
+//nolint:gochecknoglobals //static table
+var tiledWangOrder = [8][2]int{{1, 0}, {2, 0}, {2, 1}, {2, 2}, {1, 2}, {0, 2}, {0, 1}, {0, 0}}
+
+type tsxTileset struct {
+	XMLName    xml.Name       `xml:"tileset"`
+	Version    string         `xml:"version,attr"`
+	Name       string         `xml:"name,attr"`
+	TileWidth  int            `xml:"tilewidth,attr"`
+	TileHeight int            `xml:"tileheight,attr"`
+	Spacing    int            `xml:"spacing,attr"`
+	Margin     int            `xml:"margin,attr"`
+	TileCount  int            `xml:"tilecount,attr"`
+	Columns    int            `xml:"columns,attr"`
+	TileOffset *tsxTileOffset `xml:"tileoffset"`
+	Grid       *tsxGrid       `xml:"grid"`
+	Properties []tsxProperty  `xml:"properties>property"`
+	Image      tsxImage       `xml:"image"`
+	Tiles      []tsxTile      `xml:"tile"`
+	WangSets   []tsxWangSet   `xml:"wangsets>wangset"`
+}
+
+type tsxProperty struct {
+	Name  string `xml:"name,attr"`
+	Type  string `xml:"type,attr,omitempty"`
+	Value string `xml:"value,attr"`
+}
+
+type tsxTile struct {
+	ID          int           `xml:"id,attr"`
+	Probability float64       `xml:"probability,attr,omitempty"`
+	Properties  []tsxProperty `xml:"properties>property"`
+}
+
+// tsxTileOffset is the offset of drawn tiles in px, which shifts a dual grid by half a tile.
+type tsxTileOffset struct {
+	X int `xml:"x,attr"`
+	Y int `xml:"y,attr"`
+}
+
+// tsxGrid tells Tiled how to render terrain and collision overlays of tiles of non-orthogonal maps.
+type tsxGrid struct {
+	Orientation string `xml:"orientation,attr"`
+	Width       int    `xml:"width,attr"`
+	Height      int    `xml:"height,attr"`
+}
+
+type tsxImage struct {
+	Source string `xml:"source,attr"`
+	Width  int    `xml:"width,attr"`
+	Height int    `xml:"height,attr"`
+}
+
+type tsxWangSet struct {
+	Name   string         `xml:"name,attr"`
+	Type   string         `xml:"type,attr"`
+	Tile   int            `xml:"tile,attr"`
+	Colors []tsxWangColor `xml:"wangcolor"`
+	Tiles  []tsxWangTile  `xml:"wangtile"`
+}
+
+type tsxWangColor struct {
+	Name        string  `xml:"name,attr"`
+	Color       string  `xml:"color,attr"`
+	Tile        int     `xml:"tile,attr"`
+	Probability float64 `xml:"probability,attr"`
+}
+
+type tsxWangTile struct {
+	TileID int    `xml:"tileid,attr"`
+	WangID string `xml:"wangid,attr"`
+}
+
+// tiledWangID returns the wang id of the tile and false if Tiled can't place the tile:
+// an isolated tile has the same wang id as the background one.
+func tiledWangID(tile Tile) (string, bool) {
+	ids := make([]string, len(tiledWangOrder))
+	placeable := tile.Background
+	for i, xy := range tiledWangOrder {
+		terrain := tile.Grid[xy[1]][xy[0]]
+		ids[i] = fmt.Sprint(terrain)
+		if terrain == tile.Grid[1][1] {
+			placeable = true
+		}
+	}
+	return strings.Join(ids, ","), placeable
+}
+
+// tiledTerrainColors lists colors of terrains of corner wang sets, repeated if there are more terrains.
+//
+//nolint:gochecknoglobals //static table
+var tiledTerrainColors = []string{"#3f7fff", "#ffffff", "#7fbf3f", "#bf7f3f", "#bfbfbf", "#ff7f7f", "#ffdf3f", "#7f3fbf"}
+
+// tiledCornerWangSet returns the corner wang set of a multi-terrain tile set.
+// Wang ids list terrains of corners only, sides are 0. Approximate tiles are left out, so terrain tools
+// never place tiles whose sides may not match their neighbours.
+func tiledCornerWangSet(ts *Tileset) tsxWangSet {
+	wangSet := tsxWangSet{Name: ts.Name, Type: WangCorner, Tile: -1}
+	for i, name := range ts.Terrains {
+		wangSet.Colors = append(wangSet.Colors, tsxWangColor{
+			Name: name, Color: tiledTerrainColors[i%len(tiledTerrainColors)], Tile: -1, Probability: 1,
+		})
+	}
+	for _, tile := range ts.Tiles {
+		if tile.Approximate {
+			continue
+		}
+		ids := make([]string, len(tiledWangOrder))
+		for i, xy := range tiledWangOrder {
+			ids[i] = "0"
+			if xy[0] != 1 && xy[1] != 1 {
+				ids[i] = fmt.Sprint(tile.Grid[xy[1]][xy[0]])
+			}
+		}
+		wangSet.Tiles = append(wangSet.Tiles, tsxWangTile{TileID: tile.ID, WangID: strings.Join(ids, ",")})
+	}
+	return wangSet
+}
+
+// tiledEdgeWangSet returns the edge wang set of an edge Wang tile set with a single color of connections.
+// Wang ids list connected sides only, corners are 0.
+func tiledEdgeWangSet(ts *Tileset) tsxWangSet {
+	wangSet := tsxWangSet{
+		Name:   ts.Name,
+		Type:   WangEdge,
+		Tile:   -1,
+		Colors: []tsxWangColor{{Name: "connection", Color: tiledTerrainColors[0], Tile: -1, Probability: 1}},
+	}
+	for _, tile := range ts.Tiles {
+		ids := make([]string, len(tiledWangOrder))
+		for i, xy := range tiledWangOrder {
+			ids[i] = "0"
+			if (xy[0] == 1) != (xy[1] == 1) {
+				ids[i] = fmt.Sprint(tile.Grid[xy[1]][xy[0]])
+			}
+		}
+		wangSet.Tiles = append(wangSet.Tiles, tsxWangTile{TileID: tile.ID, WangID: strings.Join(ids, ",")})
+	}
+	return wangSet
+}
+
+// tiledMixedWangSet returns the mixed wang set of both terrains of a tile set generated from a 2x3 tile set.
+func tiledMixedWangSet(ts *Tileset) tsxWangSet {
+	wangSet := tsxWangSet{
+		Name: ts.Name,
+		Type: "mixed",
+		Tile: -1,
+		Colors: []tsxWangColor{
+			{Name: "terrain1", Color: "#3f7fff", Tile: -1, Probability: 1},
+			{Name: "terrain2", Color: "#ffffff", Tile: -1, Probability: 1},
+		},
+	}
+	for _, tile := range ts.Tiles {
+		if wangID, ok := tiledWangID(tile); ok {
+			wangSet.Tiles = append(wangSet.Tiles, tsxWangTile{TileID: tile.ID, WangID: wangID})
+		}
+	}
+	return wangSet
+}
+
+// tiledHexProperties sets the map settings of a hex tile set as tile set properties and names of sides
+// with a neighbour of terrain 2 as properties of every tile.
+func tiledHexProperties(tsx *tsxTileset, ts *Tileset) {
+	tsx.Properties = []tsxProperty{
+		{Name: "orientation", Value: ts.Orientation},
+		{Name: "staggeraxis", Value: ts.StaggerAxis},
+		{Name: "hexsidelength", Type: "int", Value: fmt.Sprint(ts.HexSideLength)},
+	}
+	for _, tile := range ts.Tiles {
+		var sides []string
+		for i, side := range ts.Sides {
+			if tile.Mask&(1<<i) != 0 {
+				sides = append(sides, side)
+			}
+		}
+		tsx.Tiles = append(tsx.Tiles, tsxTile{ID: tile.ID, Properties: []tsxProperty{
+			{Name: "mask", Type: "int", Value: fmt.Sprint(tile.Mask)},
+			{Name: "sides", Value: strings.Join(sides, ",")},
+		}})
+	}
+}
+
+func (tiled) Export(w io.Writer, ts *Tileset) error {
+	tsx := tsxTileset{
+		Version:    "1.10",
+		Name:       ts.Name,
+		TileWidth:  ts.TileWidth,
+		TileHeight: ts.TileHeight,
+		Spacing:    ts.Padding * 2,
+		Margin:     ts.Padding,
+		TileCount:  ts.Columns * ts.Rows,
+		Columns:    ts.Columns,
+		Image:      tsxImage{Source: ts.Image, Width: ts.ImageWidth, Height: ts.ImageHeight},
+	}
+	switch {
+	case ts.Orientation == OrientationHexagonal:
+		tiledHexProperties(&tsx, ts)
+	case ts.WangType == WangCorner:
+		tsx.WangSets = []tsxWangSet{tiledCornerWangSet(ts)}
+	case ts.WangType == WangEdge:
+		tsx.WangSets = []tsxWangSet{tiledEdgeWangSet(ts)}
+	default:
+		tsx.WangSets = []tsxWangSet{tiledMixedWangSet(ts)}
+	}
+	for _, tile := range ts.Tiles {
+		// wang sets place tiles with the same wang id randomly by their probability
+		if tile.Probability != 0 {
+			tsx.Tiles = append(tsx.Tiles, tsxTile{ID: tile.ID, Probability: tile.Probability})
+		}
+	}
+	if ts.DisplayOffsetX != 0 || ts.DisplayOffsetY != 0 {
+		tsx.TileOffset = &tsxTileOffset{X: ts.DisplayOffsetX, Y: ts.DisplayOffsetY}
+	}
+	if ts.Orientation == OrientationIsometric {
+		tsx.Grid = &tsxGrid{Orientation: ts.Orientation, Width: ts.TileWidth, Height: ts.TileHeight}
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", " ")
+	if err := enc.Encode(tsx); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
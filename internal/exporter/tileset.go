@@ -0,0 +1,356 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package exporter writes metadata of generated tile sets for game engines and editors.
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/krylphi/autotiler/internal/unpack"
+)
+
+var (
+	// ErrUnknownExporter is returned when an exporter name is not known.
+	ErrUnknownExporter = errors.New("unknown exporter")
+	// ErrUnsupportedTileset is returned when an exporter can't describe a tile set.
+	ErrUnsupportedTileset = errors.New("unsupported tile set")
+)
+
+// Tileset describes a generated tile set image.
+type Tileset struct {
+	// Name is the name of the layout of the tile set.
+	Name string `json:"name"`
+	// Image is the path of the tile set image relative to the exported files.
+	Image       string `json:"image"`
+	ImageWidth  int    `json:"imageWidth"`
+	ImageHeight int    `json:"imageHeight"`
+	Columns     int    `json:"columns"`
+	Rows        int    `json:"rows"`
+	// TileWidth and TileHeight are the size of a tile without padding.
+	TileWidth  int `json:"tileWidth"`
+	TileHeight int `json:"tileHeight"`
+	// Padding is the padding of every tile in px: the margin of the image is Padding
+	// and the spacing between tiles is 2*Padding.
+	Padding int `json:"padding"`
+	// Orientation is OrientationIsometric for diamond tiles, OrientationHexagonal for hex tiles
+	// and empty for square ones.
+	Orientation string `json:"orientation,omitempty"`
+	// StaggerAxis and HexSideLength are the Tiled map settings of a hex tile set: the axis is y for pointy tiles
+	// and x for flat ones, the length is the length of the sides parallel to the axis in px.
+	StaggerAxis   string `json:"staggerAxis,omitempty"`
+	HexSideLength int    `json:"hexSideLength,omitempty"`
+	// Sides are names of sides of hex tiles in the order of bits of their masks.
+	Sides []string `json:"sides,omitempty"`
+	// WangType is WangCorner or WangEdge for Wang tile sets and empty for tile sets generated from a 2x3 tile set.
+	WangType string `json:"wangType,omitempty"`
+	// Terrains are names of terrains of a corner Wang tile set, terrain i is Terrains[i-1].
+	Terrains []string `json:"terrains,omitempty"`
+	// DisplayOffsetX and DisplayOffsetY are the offset of the display grid of a dual grid tile set
+	// from the logic grid in px: minus half a tile, see unpack.DualGrid.
+	DisplayOffsetX int    `json:"displayOffsetX,omitempty"`
+	DisplayOffsetY int    `json:"displayOffsetY,omitempty"`
+	Tiles          []Tile `json:"tiles"`
+}
+
+// Orientations of tile sets named like Tiled map orientations.
+const (
+	// OrientationIsometric is the Orientation of tile sets of diamond tiles generated by an isometric unpacker.
+	OrientationIsometric = "isometric"
+	// OrientationHexagonal is the Orientation of tile sets of hex tiles generated by unpack.FromHexStrip.
+	OrientationHexagonal = "hexagonal"
+)
+
+// Types of Wang tile sets named like Tiled wang set types.
+const (
+	// WangCorner tile sets match terrains at corners of tiles, see NewCornerWangTileset.
+	WangCorner = "corner"
+	// WangEdge tile sets match connections at sides of tiles, see NewEdgeWangTileset.
+	WangEdge = "edge"
+)
+
+// Tile describes a tile of a tile set.
+type Tile struct {
+	// ID is the index of the tile in the tile set, row by row.
+	ID int `json:"id"`
+	X  int `json:"x"`
+	Y  int `json:"y"`
+	// Mask is the cr31 blob bitmask of the tile. Bits of hex tiles are sides of the tile set
+	// with a neighbour of terrain 2.
+	Mask uint8 `json:"mask"`
+	// Terrain is the terrain of the blob (1 or 2).
+	Terrain int `json:"terrain"`
+	// Background is true if the tile is filled with the terrain surrounding the blob.
+	Background bool `json:"background,omitempty"`
+	// Grid is the terrain at the corners, middles of edges and the center of the tile, row by row.
+	// It is zero for hex tiles.
+	Grid [3][3]int `json:"grid"`
+	// Corners are terrains at the top-left, top-right, bottom-left and bottom-right corners of a tile
+	// of a corner Wang tile set.
+	Corners []int `json:"corners,omitempty"`
+	// Approximate is true if three terrains meet in a quarter of a corner Wang tile. A 2x3 tile set shows two,
+	// so halves of sides through such a quarter may not match neighbouring tiles. Tiled wang sets leave such tiles out.
+	Approximate bool `json:"approximate,omitempty"`
+	// Variant is the index of the pack of random variants the tile was generated from.
+	Variant int `json:"variant,omitempty"`
+	// Probability is the weight of the pack of random variants of the tile, editors choose randomly
+	// among tiles with the same mask by their probabilities. It is zero for tile sets without variants.
+	Probability float64 `json:"probability,omitempty"`
+}
+
+// NewTileset describes the tile set image of the given layout generated from a 2x3 tile set.
+// The dual grid layout is described as a corner Wang tile set of both terrains.
+//
+// Parameters:
+// - layout: The layout of the tile set (one of unpack.Layout* constants).
+// - imagePath: The path of the image relative to the exported files.
+// - width, height: The size of the image in px.
+// - padding: The padding of every tile in px.
+//
+// Returns:
+// - A pointer to the Tileset.
+// - An error if the layout is unknown.
+func NewTileset(layout, imagePath string, width, height, padding int) (*Tileset, error) {
+	return NewVariantTileset(layout, imagePath, width, height, padding, nil)
+}
+
+// NewVariantTileset describes the tile set image of the given layout generated from a 2x3 tile set
+// with random variants (see unpack.Unpacker.SetRandomVariants): a block of the layout for every pack,
+// stacked below each other. Tiles of a block have the weight of its pack as their probability.
+//
+// Parameters:
+// - layout: The layout of the tile set (one of unpack.Layout* constants).
+// - imagePath: The path of the image relative to the exported files.
+// - width, height: The size of the image in px.
+// - padding: The padding of every tile in px.
+// - weights: Weights of the packs, nil for a tile set without variants.
+//
+// Returns:
+// - A pointer to the Tileset.
+// - An error if the layout is unknown.
+func NewVariantTileset(layout, imagePath string, width, height, padding int, weights []float64) (*Tileset, error) {
+	info, err := unpack.DescribeLayout(layout)
+	if err != nil {
+		return nil, err
+	}
+	packs := max(len(weights), 1)
+	res := &Tileset{
+		Name:        layout,
+		Image:       imagePath,
+		ImageWidth:  width,
+		ImageHeight: height,
+		Columns:     info.Cols,
+		Rows:        info.Rows * packs,
+		TileWidth:   width/info.Cols - padding*2,
+		TileHeight:  height/(info.Rows*packs) - padding*2,
+		Padding:     padding,
+	}
+	for pack := 0; pack < packs; pack++ {
+		for _, tile := range info.Tiles {
+			y := pack*info.Rows + tile.Cell.Y
+			res.Tiles = append(res.Tiles, Tile{
+				ID:         y*info.Cols + tile.Cell.X,
+				X:          tile.Cell.X,
+				Y:          y,
+				Mask:       uint8(tile.Mask),
+				Terrain:    tile.Terrain,
+				Background: tile.Background,
+				Grid:       tile.TerrainGrid(),
+				Variant:    pack,
+			})
+			if tile.Corners != [4]int{} {
+				res.Tiles[len(res.Tiles)-1].Corners = append([]int(nil), tile.Corners[:]...)
+			}
+			if weights != nil {
+				res.Tiles[len(res.Tiles)-1].Probability = weights[pack]
+			}
+		}
+	}
+	if layout == unpack.LayoutDualGrid16 {
+		res.WangType = WangCorner
+		res.Terrains = []string{"terrain1", "terrain2"}
+		res.DisplayOffsetX, res.DisplayOffsetY = -res.TileWidth/2, -res.TileHeight/2
+	}
+	return res, nil
+}
+
+// NewCornerWangTileset describes the corner Wang tile set image of several terrains generated by unpack.MultiTerrain.
+//
+// Parameters:
+// - terrains: Names of the terrains in the order of their numbers.
+// - imagePath: The path of the image relative to the exported files.
+// - width, height: The size of the image in px.
+// - padding: The padding of every tile in px.
+//
+// Returns:
+// - A pointer to the Tileset.
+func NewCornerWangTileset(terrains []string, imagePath string, width, height, padding int) *Tileset {
+	cols, rows, tiles := unpack.DescribeCornerWang(len(terrains))
+	res := &Tileset{
+		Name:        unpack.LayoutCornerWangTerrains,
+		Image:       imagePath,
+		ImageWidth:  width,
+		ImageHeight: height,
+		Columns:     cols,
+		Rows:        rows,
+		Padding:     padding,
+		WangType:    WangCorner,
+		Terrains:    append([]string(nil), terrains...),
+	}
+	if cols > 0 {
+		res.TileWidth = width/cols - padding*2
+		res.TileHeight = height/rows - padding*2
+	}
+	for _, tile := range tiles {
+		res.Tiles = append(res.Tiles, Tile{
+			ID:          tile.Cell.Y*cols + tile.Cell.X,
+			X:           tile.Cell.X,
+			Y:           tile.Cell.Y,
+			Grid:        tile.TerrainGrid(),
+			Corners:     tile.Corners[:],
+			Approximate: tile.Approximate(),
+		})
+	}
+	return res
+}
+
+// NewEdgeWangTileset describes the edge Wang tile set image generated by unpack.FromEdgeStrip.
+// The mask of a tile has neighbours at connected sides set, the grid is 1 at the center
+// and the middles of connected sides and 0 elsewhere.
+//
+// Parameters:
+// - imagePath: The path of the image relative to the exported files.
+// - width, height: The size of the image in px.
+// - padding: The padding of every tile in px.
+//
+// Returns:
+// - A pointer to the Tileset.
+func NewEdgeWangTileset(imagePath string, width, height, padding int) *Tileset {
+	const cols = 4
+	res := &Tileset{
+		Name:        unpack.LayoutEdgeWang16,
+		Image:       imagePath,
+		ImageWidth:  width,
+		ImageHeight: height,
+		Columns:     cols,
+		Rows:        cols,
+		TileWidth:   width/cols - padding*2,
+		TileHeight:  height/cols - padding*2,
+		Padding:     padding,
+		WangType:    WangEdge,
+	}
+	for id := 0; id < cols*cols; id++ {
+		edges := unpack.Edges(id)
+		tile := Tile{ID: id, X: id % cols, Y: id / cols, Mask: uint8(edges.Mask()), Terrain: 1}
+		tile.Grid[1][1] = 1
+		for i, xy := range [4][2]int{{1, 0}, {2, 1}, {1, 2}, {0, 1}} {
+			if edges&(1<<i) != 0 {
+				tile.Grid[xy[1]][xy[0]] = 1
+			}
+		}
+		res.Tiles = append(res.Tiles, tile)
+	}
+	return res
+}
+
+// NewHexTileset describes the hex tile set image generated by unpack.FromHexStrip.
+// Tiles are of terrain 1 and their masks are unpack.HexEdges.
+//
+// Parameters:
+// - orientation: The orientation of the tiles.
+// - imagePath: The path of the image relative to the exported files.
+// - width, height: The size of the image in px.
+// - padding: The padding of every tile in px.
+//
+// Returns:
+// - A pointer to the Tileset.
+// - An error if the orientation is unknown.
+func NewHexTileset(orientation unpack.HexOrientation, imagePath string, width, height, padding int) (*Tileset, error) {
+	sides, err := unpack.HexSides(orientation)
+	if err != nil {
+		return nil, err
+	}
+	const cols = 8
+	res := &Tileset{
+		Name:        unpack.LayoutHex64,
+		Image:       imagePath,
+		ImageWidth:  width,
+		ImageHeight: height,
+		Columns:     cols,
+		Rows:        cols,
+		TileWidth:   width/cols - padding*2,
+		TileHeight:  height/cols - padding*2,
+		Padding:     padding,
+		Orientation: OrientationHexagonal,
+		Sides:       sides[:],
+	}
+	res.StaggerAxis, res.HexSideLength = "y", res.TileHeight/2
+	if orientation == unpack.HexFlat {
+		res.StaggerAxis, res.HexSideLength = "x", res.TileWidth/2
+	}
+	for id := 0; id < cols*cols; id++ {
+		res.Tiles = append(res.Tiles, Tile{ID: id, X: id % cols, Y: id / cols, Mask: uint8(id), Terrain: 1})
+	}
+	return res, nil
+}
+
+// Exporter writes a tile set description in a format of an engine or an editor.
+type Exporter interface {
+	// Name returns the name of the exporter used in options.
+	Name() string
+	// Ext returns the extension of written files including the leading dot.
+	Ext() string
+	// Export writes the description of the tile set.
+	Export(w io.Writer, ts *Tileset) error
+}
+
+// exporters returns all exporters by their names.
+func exporters() map[string]Exporter {
+	res := make(map[string]Exporter)
+	for _, e := range []Exporter{manifest{}, tiled{}, godot{}} {
+		res[e.Name()] = e
+	}
+	return res
+}
+
+// New returns the exporter with the given name.
+func New(name string) (Exporter, error) {
+	if e, ok := exporters()[name]; ok {
+		return e, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownExporter, name)
+}
+
+// Names returns names of all exporters in alphabetical order.
+func Names() []string {
+	var res []string
+	for name := range exporters() {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
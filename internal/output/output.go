@@ -0,0 +1,195 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package output generates tile sets with their engine descriptions from a 2x3 tile set and writes them
+// to archives. It is shared by the CLI, the HTTP service and the WebAssembly module, so they produce the same files.
+package output
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"path"
+	"slices"
+
+	"github.com/krylphi/autotiler/internal/exporter"
+	"github.com/krylphi/autotiler/internal/unpack"
+)
+
+// Grids of tiles of a 2x3 tile set.
+const (
+	GridSquare = "square"
+	GridIso    = "iso"
+)
+
+// ErrUnknownGrid is returned when a grid name is not known.
+var ErrUnknownGrid = errors.New("unknown grid")
+
+// Tileset is a generated tile set image with its description for exporters.
+type Tileset struct {
+	Layout string
+	Image  *image.NRGBA
+	// Description describes the image, its image path is ImageName.
+	Description *exporter.Tileset
+}
+
+// ImageName returns the name of the image file of the tile set.
+func (t *Tileset) ImageName() string {
+	return t.Layout + ".png"
+}
+
+// FileName returns the name of the file of the exporter describing the tile set.
+// It is placed next to the image, so the relative image path resolves.
+func (t *Tileset) FileName(e exporter.Exporter) string {
+	return t.Layout + e.Ext()
+}
+
+// NewUnpacker creates an initialized unpacker of a 2x3 tile set.
+//
+// Parameters:
+// - img: The 2x3 tile set, or packs of random variants of it side by side if there are several weights.
+// - padding: The padding of every generated tile in px.
+// - grid: GridSquare or GridIso, an empty grid is square.
+// - weights: Weights of random variants, see unpack.Unpacker.SetRandomVariants; nil for a single pack.
+//
+// Returns:
+// - The initialized unpacker.
+// - An error wrapping ErrUnknownGrid, an error of the weights or an error of unpack.Unpacker.Init.
+func NewUnpacker(img image.Image, padding int, grid string, weights []float64) (*unpack.Unpacker, error) {
+	if grid != "" && grid != GridSquare && grid != GridIso {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownGrid, grid)
+	}
+	unpacker := unpack.NewUnpacker(img, 2, 3, padding)
+	unpacker.SetIsometric(grid == GridIso)
+	if err := unpacker.SetRandomVariants(weights); err != nil {
+		return nil, err
+	}
+	if err := unpacker.Init(2); err != nil {
+		return nil, err
+	}
+	return unpacker, nil
+}
+
+// Layouts returns the given layouts or unpack.DefaultExportLayouts if there are none.
+// It returns an error wrapping unpack.ErrUnknownLayout if a layout can't be generated from a 2x3 tile set.
+func Layouts(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return unpack.DefaultExportLayouts(), nil
+	}
+	for _, layout := range names {
+		if !slices.Contains(unpack.ExportLayouts(), layout) {
+			return nil, fmt.Errorf("%w: %s can't be generated from a 2x3 tile set", unpack.ErrUnknownLayout, layout)
+		}
+	}
+	return names, nil
+}
+
+// Generate generates tile sets of the given layouts. Descriptions follow the unpacker: they list random variants
+// with their weights and have isometric orientation for isometric tiles.
+//
+// Parameters:
+// - ctx: The context which cancels generation.
+// - unpacker: The initialized unpacker of a 2x3 tile set.
+// - layouts: Names of the layouts to generate.
+// - padding: The padding of every tile in px the unpacker was created with.
+//
+// Returns:
+// - Generated tile sets in the order of layouts.
+// - An error if any layout fails.
+func Generate(ctx context.Context, unpacker *unpack.Unpacker, layouts []string, padding int) ([]*Tileset, error) {
+	res := make([]*Tileset, 0, len(layouts))
+	for _, layout := range layouts {
+		img, err := unpacker.ExportContext(ctx, layout, nil)
+		if err != nil {
+			return nil, err
+		}
+		out := &Tileset{Layout: layout, Image: img}
+		out.Description, err = exporter.NewVariantTileset(
+			layout, out.ImageName(), img.Rect.Dx(), img.Rect.Dy(), padding, unpacker.RandomVariants())
+		if err != nil {
+			return nil, err
+		}
+		if unpacker.Isometric() {
+			out.Description.Orientation = exporter.OrientationIsometric
+		}
+		res = append(res, out)
+	}
+	return res, nil
+}
+
+// Exporters returns exporters with the given names without duplicates.
+func Exporters(names []string) ([]exporter.Exporter, error) {
+	var res []exporter.Exporter
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		e, err := exporter.New(name)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+// WriteZip writes the images of the tile sets and files of the exporters to a zip archive.
+func WriteZip(w io.Writer, tilesets []*Tileset, exporters []exporter.Exporter) error {
+	archive := zip.NewWriter(w)
+	if err := AddZipFiles(archive, "", tilesets, exporters); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// AddZipFiles adds the images of the tile sets and files of the exporters to the given directory of a zip archive.
+// The directory is the root of the archive if it is empty.
+func AddZipFiles(archive *zip.Writer, dir string, tilesets []*Tileset, exporters []exporter.Exporter) error {
+	for _, ts := range tilesets {
+		f, err := archive.Create(path.Join(dir, ts.ImageName()))
+		if err != nil {
+			return err
+		}
+		if err := png.Encode(f, ts.Image); err != nil {
+			return fmt.Errorf("%s: %w", ts.ImageName(), err)
+		}
+		for _, e := range exporters {
+			name := ts.FileName(e)
+			f, err := archive.Create(path.Join(dir, name))
+			if err != nil {
+				return err
+			}
+			if err := e.Export(f, ts.Description); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package output
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/krylphi/autotiler/internal/exporter"
+	"github.com/krylphi/autotiler/internal/unpack"
+)
+
+func loadUnpacker(t *testing.T, path, grid string, weights []float64) *unpack.Unpacker {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := unpack.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unpacker, err := NewUnpacker(img, 1, grid, weights)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return unpacker
+}
+
+func TestGenerate(t *testing.T) {
+	unpacker := loadUnpacker(t, "../../examples/2x3_packed.png", "", nil)
+	tilesets, err := Generate(context.Background(), unpacker, []string{unpack.Layout16Terrain1, unpack.Layout48Terrain1}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, layout := range []string{unpack.Layout16Terrain1, unpack.Layout48Terrain1} {
+		ts := tilesets[i]
+		if ts.Layout != layout || ts.ImageName() != layout+".png" || ts.Description.Image != ts.ImageName() {
+			t.Errorf("tile set %d: got layout %s with image %s", i, ts.Layout, ts.Description.Image)
+		}
+		if ts.Description.ImageWidth != ts.Image.Rect.Dx() || ts.Description.ImageHeight != ts.Image.Rect.Dy() ||
+			ts.Description.Padding != 1 || ts.Description.Orientation != "" {
+			t.Errorf("%s: description %+v doesn't match image %v", layout, ts.Description, ts.Image.Rect)
+		}
+	}
+}
+
+func TestGenerateIsometric(t *testing.T) {
+	unpacker := loadUnpacker(t, "../../examples/2x3_packed_iso.png", GridIso, nil)
+	tilesets, err := Generate(context.Background(), unpacker, []string{unpack.Layout48Terrain1}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts := tilesets[0].Description; ts.Orientation != exporter.OrientationIsometric || ts.TileWidth != 2*ts.TileHeight {
+		t.Errorf("unexpected %s tile set of %dx%d px tiles", ts.Orientation, ts.TileWidth, ts.TileHeight)
+	}
+}
+
+func TestGenerateRandomVariants(t *testing.T) {
+	unpacker := loadUnpacker(t, "../../examples/2x3_packed_variants.png", GridSquare, []float64{3, 1})
+	tilesets, err := Generate(context.Background(), unpacker, []string{unpack.Layout48Terrain1}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := tilesets[0].Description
+	if len(ts.Tiles) != 2*48 {
+		t.Fatalf("got %d tiles, want %d", len(ts.Tiles), 2*48)
+	}
+	if first, last := ts.Tiles[0], ts.Tiles[len(ts.Tiles)-1]; first.Probability != 3 || last.Probability != 1 ||
+		last.Variant != 1 {
+		t.Errorf("got tiles %+v and %+v", first, last)
+	}
+}
+
+func TestNewUnpackerErrors(t *testing.T) {
+	f, err := os.Open("../../examples/2x3_packed.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := unpack.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		grid    string
+		weights []float64
+		want    error
+	}{
+		{"unknown grid", "hex", nil, ErrUnknownGrid},
+		{"invalid weight", GridSquare, []float64{1, 0}, unpack.ErrInvalidWeight},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewUnpacker(img, 0, tt.grid, tt.weights); !errors.Is(err, tt.want) {
+				t.Errorf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLayouts(t *testing.T) {
+	got, err := Layouts(nil)
+	if err != nil || !slices.Equal(got, unpack.DefaultExportLayouts()) {
+		t.Errorf("got %v, %v", got, err)
+	}
+	if _, err := Layouts([]string{unpack.Layout16Terrain1, "7x7"}); !errors.Is(err, unpack.ErrUnknownLayout) {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestExporters(t *testing.T) {
+	got, err := Exporters([]string{"tiled", "godot", "tiled"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Name() != "tiled" || got[1].Name() != "godot" {
+		t.Errorf("got %d exporters", len(got))
+	}
+	if _, err := Exporters([]string{"unity"}); !errors.Is(err, exporter.ErrUnknownExporter) {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestWriteZip(t *testing.T) {
+	unpacker := loadUnpacker(t, "../../examples/2x3_packed.png", "", nil)
+	tilesets, err := Generate(context.Background(), unpacker, []string{unpack.Layout16Terrain1}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporters, err := Exporters([]string{"manifest", "tiled"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteZip(&buf, tilesets, exporters); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+	}
+	want := []string{
+		unpack.Layout16Terrain1 + ".png", tilesets[0].FileName(exporters[0]), unpack.Layout16Terrain1 + ".tsx",
+	}
+	if !slices.Equal(names, want) {
+		t.Errorf("got files %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,178 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/jpeg"
+	"testing"
+)
+
+// benchSourceTypes lists image types produced by image.Decode the benchmarks are run with.
+//
+//nolint:gochecknoglobals //static benchmark table
+var benchSourceTypes = []string{"nrgba", "rgba", "paletted", "ycbcr"}
+
+// benchSource returns the example 2x3 tile set scaled to the given tile size and converted to the given type.
+func benchSource(b *testing.B, sourceType string, tileSize int) image.Image {
+	b.Helper()
+	src := toNRGBA(loadImage(b, goldenSource))
+	scaled := image.NewNRGBA(image.Rect(0, 0, tileSize*2, tileSize*3))
+	srcTileSize := src.Bounds().Dx() / 2
+	for y := 0; y < scaled.Rect.Dy(); y++ {
+		for x := 0; x < scaled.Rect.Dx(); x++ {
+			scaled.SetNRGBA(x, y, src.NRGBAAt(x*srcTileSize/tileSize, y*srcTileSize/tileSize))
+		}
+	}
+	switch sourceType {
+	case "rgba":
+		res := image.NewRGBA(scaled.Rect)
+		draw.Draw(res, res.Rect, scaled, image.Point{}, draw.Src)
+		return res
+	case "paletted":
+		res := image.NewPaletted(scaled.Rect, palette.WebSafe)
+		draw.Draw(res, res.Rect, scaled, image.Point{}, draw.Src)
+		return res
+	case "ycbcr":
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, scaled, nil); err != nil {
+			b.Fatal(err)
+		}
+		res, err := jpeg.Decode(&buf)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return res
+	}
+	return scaled
+}
+
+// unpackAllExports creates an unpacker for src and runs every 2x3 export.
+func unpackAllExports(b *testing.B, src image.Image) {
+	b.Helper()
+	u := NewUnpacker(src, sixPackXTiles, sixPackYTiles, 1)
+	if err := u.Init(sixPackSegments); err != nil {
+		b.Fatal(err)
+	}
+	for name, export := range goldenExports(u) {
+		if _, err := export(); err != nil {
+			b.Fatalf("%s: %v", name, err)
+		}
+	}
+}
+
+func BenchmarkUnpackSourceTypes(b *testing.B) {
+	for _, sourceType := range benchSourceTypes {
+		for _, tileSize := range []int{64, 256} {
+			src := benchSource(b, sourceType, tileSize)
+			b.Run(fmt.Sprintf("%s/%dpx", sourceType, tileSize), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					unpackAllExports(b, src)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkUnpackBatch(b *testing.B) {
+	const batchSize = 32
+	for _, sourceType := range benchSourceTypes {
+		src := benchSource(b, sourceType, 32)
+		b.Run(sourceType, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for j := 0; j < batchSize; j++ {
+					unpackAllExports(b, src)
+				}
+			}
+		})
+	}
+}
+
+// benchTileSizes lists tile sizes in px the benchmarks of the pipeline stages are run with.
+//
+//nolint:gochecknoglobals //static benchmark table
+var benchTileSizes = []int{8, 16, 32, 64, 128, 256}
+
+func BenchmarkFrom6to(b *testing.B) {
+	for _, tileSize := range benchTileSizes {
+		u := NewUnpacker(benchSource(b, "nrgba", tileSize), sixPackXTiles, sixPackYTiles, 1)
+		if err := u.Init(sixPackSegments); err != nil {
+			b.Fatal(err)
+		}
+		for _, name := range []string{Layout16Terrain1, Layout16Terrain2, Layout28, Layout48Terrain1, Layout48Terrain2} {
+			export := goldenExports(u)[name]
+			b.Run(fmt.Sprintf("%s/%dpx", name, tileSize), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := export(); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkRotateLeft90(b *testing.B) {
+	for _, tileSize := range benchTileSizes {
+		src := toNRGBA(benchSource(b, "nrgba", tileSize))
+		tile := toNRGBA(src.SubImage(image.Rect(0, 0, tileSize, tileSize)))
+		b.Run(fmt.Sprintf("%dpx", tileSize), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := rotateLeft90(context.Background(), tile); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkParallel(b *testing.B) {
+	for _, tileSize := range benchTileSizes {
+		src := toNRGBA(benchSource(b, "nrgba", tileSize))
+		dst := image.NewNRGBA(src.Rect)
+		b.Run(fmt.Sprintf("%dpx", tileSize), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				err := parallel(context.Background(), 0, src.Rect.Dy(), func(ys <-chan int) {
+					for y := range ys {
+						copyArea(dst, image.Point{Y: y}, src, image.Rect(0, y, src.Rect.Dx(), y+1))
+					}
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
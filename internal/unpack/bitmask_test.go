@@ -0,0 +1,238 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"fmt"
+	"image"
+	"testing"
+)
+
+// terrainGrid holds terrain at corners, middles of edges and the center of a tile. True is terrain 1.
+type terrainGrid [3][3]bool
+
+// rotateLeft returns the grid rotated 90 degrees counter-clockwise.
+func (g terrainGrid) rotateLeft() terrainGrid {
+	var res terrainGrid
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 3; x++ {
+			res[y][x] = g[x][2-y]
+		}
+	}
+	return res
+}
+
+func (g terrainGrid) String() string {
+	res := ""
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 3; x++ {
+			if g[y][x] {
+				res += "1"
+			} else {
+				res += "2"
+			}
+		}
+		if y < 2 {
+			res += "/"
+		}
+	}
+	return res
+}
+
+// sourceQuarterCorners returns terrain at the corners (TL, TR, BL, BR) of a sub tile of the 2x3 tile set.
+// Terrain 1 is the inner part of the blob in the lower 2x2 tiles, terrain 2 fills the top left tile.
+// Corners define the kind of sub tile: fill (4 corners of one terrain), edge (2), outer or inner corner (1 or 3).
+func sourceQuarterCorners(x, y int) [4]bool {
+	kinds := [6][4][4]bool{
+		{{false, false, false, false}, {false, false, false, false}, {false, true, true, true}, {true, false, true, true}},
+		{{false, false, false, false}, {false, false, false, false}, {true, true, false, true}, {true, true, true, false}},
+		{{false, false, false, true}, {false, false, true, true}, {false, false, true, true}, {false, false, true, false}},
+		{{false, true, false, true}, {true, true, true, true}, {true, true, true, true}, {true, false, true, false}},
+		{{false, true, false, true}, {true, true, true, true}, {true, true, true, true}, {true, false, true, false}},
+		{{false, true, false, false}, {true, true, false, false}, {true, true, false, false}, {true, false, false, false}},
+	}
+	return kinds[y][x]
+}
+
+// composedGrid returns the terrain grid of a tile composed of the given sub tiles
+// and an error if adjacent sub tiles disagree about terrain at a shared point.
+func composedGrid(data quadTileData) (terrainGrid, error) {
+	var grid terrainGrid
+	var set [3][3]bool
+	for i, xy := range data {
+		corners := sourceQuarterCorners(xy[0], xy[1])
+		qx, qy := i%2, i>>1
+		for c, terrain := range corners {
+			x, y := qx+c%2, qy+c>>1
+			if set[y][x] && grid[y][x] != terrain {
+				return grid, fmt.Errorf("sub tile %v at position %d disagrees at point (%d, %d)", xy, i, x, y)
+			}
+			grid[y][x], set[y][x] = terrain, true
+		}
+	}
+	return grid, nil
+}
+
+// blobTile describes what a tile of a tile set should look like.
+type blobTile struct {
+	// terrain1 is true if terrain 1 is the inner part of the blob.
+	terrain1 bool
+	// background is true if tile is filled with terrain surrounding the blob.
+	background bool
+	mask       Mask
+}
+
+// expectedGrid returns the terrain grid implied by the mask. Sides are blob terrain if the neighbour is set,
+// corners are blob terrain if the corner neighbour and both adjacent ones are set.
+// The center is blob terrain unless the tile is an end piece (a single side set): ends don't reach the center.
+func (b blobTile) expectedGrid() terrainGrid {
+	var grid terrainGrid
+	inner, outer := b.terrain1, !b.terrain1
+	for y := range grid {
+		for x := range grid[y] {
+			grid[y][x] = outer
+		}
+	}
+	if b.background {
+		return grid
+	}
+	m := b.mask.Canonical()
+	set := func(bit Mask, x, y int) {
+		if m&bit != 0 {
+			grid[y][x] = inner
+		}
+	}
+	set(North, 1, 0)
+	set(NorthEast, 2, 0)
+	set(East, 2, 1)
+	set(SouthEast, 2, 2)
+	set(South, 1, 2)
+	set(SouthWest, 0, 2)
+	set(West, 0, 1)
+	set(NorthWest, 0, 0)
+	sides := 0
+	for _, bit := range []Mask{North, East, South, West} {
+		if m&bit != 0 {
+			sides++
+		}
+	}
+	if sides != 1 {
+		grid[1][1] = inner
+	}
+	return grid
+}
+
+// rotationOrbitTiles returns tiles of terrain half of 14x2 tile set: background and representatives
+// of every 47 blob tiles rotation orbit except isolated tile and filled one.
+func rotationOrbitTiles(terrain1 bool) []blobTile {
+	res := []blobTile{{terrain1: terrain1, background: true}}
+	for _, m := range []Mask{1, 5, 7, 17, 21, 23, 29, 31, 85, 87, 95, 119, 127} {
+		res = append(res, blobTile{terrain1: terrain1, mask: m})
+	}
+	return res
+}
+
+// sheetTiles returns the expected tile for every cell of the given layout produced from a 2x3 tile set.
+func sheetTiles(t *testing.T, layout string) map[image.Point]blobTile {
+	t.Helper()
+	var tiles []blobTile
+	switch layout {
+	case Layout28:
+		tiles = append(rotationOrbitTiles(false), rotationOrbitTiles(true)...)
+	case Layout16Terrain1, Layout4x4Terrain1:
+		tiles = append(rotationOrbitTiles(false), blobTile{terrain1: true, mask: North}, blobTile{mask: 255})
+	case Layout16Terrain2, Layout4x4Terrain2:
+		tiles = append(rotationOrbitTiles(true), blobTile{mask: North}, blobTile{terrain1: true, mask: 255})
+	case Layout48Terrain1, Layout48Terrain2:
+		blob, err := NewBlobLayout(BlobLayout12x4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := make(map[image.Point]blobTile)
+		for y := 0; y < blob.Rows; y++ {
+			for x := 0; x < blob.Cols; x++ {
+				m, ok := blob.Mask(x, y)
+				res[image.Point{X: x, Y: y}] = blobTile{terrain1: layout == Layout48Terrain2, mask: m, background: !ok}
+			}
+		}
+		return res
+	default:
+		t.Fatalf("unknown layout %s", layout)
+	}
+	sheet, err := newSixPackSheet(layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := make(map[image.Point]blobTile)
+	for i, tile := range tiles {
+		res[image.Point{X: i % sheet.cols, Y: i / sheet.cols}] = tile
+	}
+	return res
+}
+
+// TestTilesMatchBitmask checks that every sub tile of every tile generated from a 2x3 tile set
+// is taken from the region of the 2x3 tile set of the kind implied by the tile bitmask.
+func TestTilesMatchBitmask(t *testing.T) {
+	layouts := []string{
+		Layout16Terrain1, Layout16Terrain2, Layout4x4Terrain1, Layout4x4Terrain2,
+		Layout28, Layout48Terrain1, Layout48Terrain2,
+	}
+	for _, layout := range layouts {
+		layout := layout
+		t.Run(layout, func(t *testing.T) {
+			sheet, err := newSixPackSheet(layout)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tiles := sheetTiles(t, layout)
+			covered := 0
+			for i, data := range sheet.patterns {
+				grid, err := composedGrid(data)
+				if err != nil {
+					t.Errorf("pattern %d: %v", i, err)
+					continue
+				}
+				for rotation, cell := range sheet.cells(i) {
+					if rotation > 0 {
+						grid = grid.rotateLeft()
+					}
+					tile, ok := tiles[cell]
+					if !ok {
+						t.Errorf("tile %v is not expected in %s", cell, layout)
+						continue
+					}
+					covered++
+					if want := tile.expectedGrid(); grid != want {
+						t.Errorf("tile %v (pattern %d, rotation %d, mask %d): got %s, want %s",
+							cell, i, rotation, tile.mask, grid, want)
+					}
+				}
+			}
+			if covered != sheet.cols*sheet.rows {
+				t.Errorf("%d tiles generated, want %d", covered, sheet.cols*sheet.rows)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"fmt"
+	"image"
+)
+
+// SourceBlobMin5 is the minimal blob source: a strip of 5 tiles of terrain 1 over terrain 2 which are
+// fill, outer corner, edge, inner corner and isolated tile. The outer corner is the top-left corner of a blob,
+// the edge is the top edge and the inner corner has terrain 2 in the top-left corner.
+// A square tile of terrain 2 fill can optionally follow them.
+const SourceBlobMin5 = "5x1_blob_min"
+
+// blobMinPieces is the number of blob tiles of SourceBlobMin5, without the optional terrain 2 fill.
+const blobMinPieces = 5
+
+// flip is a combination of mirrorings applied to a segment.
+type flip uint8
+
+// Mirrorings of a segment, they can be combined.
+const (
+	flipH flip = 1 << iota
+	flipV
+	flipNone flip = 0
+)
+
+// blobMinSegment is a segment of the 5 tile strip, with coordinates like in quadTileData,
+// and the mirroring turning it into a segment of a 2x3 tile set.
+type blobMinSegment struct {
+	x, y int
+	flip flip
+}
+
+// blobMinTo6 maps every segment of a 2x3 tile set, row by row, to a segment of the strip.
+// The top left tile of the 2x3 tile set is the optional terrain 2 fill, the sixth tile of the strip.
+// Missing orientations of inner corners and edges are mirrored. Rotation is never needed: the outer corner tile
+// holds both a horizontal (top) and a vertical (left) edge segment, and every other segment of a 2x3 tile set
+// is one of the strip segments mirrored over the horizontal or vertical axis. Mirroring also keeps vertical edges
+// drawn as vertical ones, so their art isn't turned on its side.
+//
+//nolint:gochecknoglobals //static layout table
+var blobMinTo6 = [6][4]blobMinSegment{
+	// terrain 2 and inner corners
+	{{10, 0, flipNone}, {11, 0, flipNone}, {6, 0, flipNone}, {6, 0, flipH}},
+	{{10, 1, flipNone}, {11, 1, flipNone}, {6, 0, flipV}, {6, 0, flipH | flipV}},
+	// blob of terrain 1
+	{{2, 0, flipNone}, {4, 0, flipNone}, {5, 0, flipNone}, {9, 0, flipNone}},
+	{{2, 1, flipNone}, {1, 1, flipNone}, {0, 1, flipNone}, {2, 1, flipH}},
+	{{2, 1, flipNone}, {1, 0, flipNone}, {0, 0, flipNone}, {2, 1, flipH}},
+	{{8, 1, flipNone}, {4, 0, flipV}, {5, 0, flipV}, {9, 1, flipNone}},
+}
+
+// apply returns a mirrored copy of the image or the image itself if there is nothing to mirror.
+func (f flip) apply(img *image.NRGBA) *image.NRGBA {
+	if f&flipH != 0 {
+		img = flipHorizontal(img)
+	}
+	if f&flipV != 0 {
+		img = flipVertical(img)
+	}
+	return img
+}
+
+// From5BlobMinTo6 converts a strip of 5 minimal blob tiles into a 2x3 tile set, so every 2x3 export can be generated from it.
+// Segments missing on the strip are mirrored, see blobMinTo6. The blob tiles have no segment of terrain 2 alone,
+// so without the optional sixth tile of terrain 2 fill the top left tile of the 2x3 tile set is left transparent;
+// Unpacker.MissingTiles reports tiles of a layout affected by it.
+//
+// Parameters:
+// - src: The 5x1 strip of fill, outer corner, edge, inner corner and isolated tile, see SourceBlobMin5.
+// A strip of 6 square tiles is read as the 5 tiles followed by the terrain 2 fill.
+//
+// Returns:
+// - The 2x3 tile set with tiles of the same size, which can be passed to NewUnpacker.
+// - An error if the image can't be split into 5x1 (or 6x1) tiles of 2x2 segments.
+func From5BlobMinTo6(src image.Image) (*image.NRGBA, error) {
+	if src == nil {
+		return nil, fmt.Errorf("%s: %w: no image", SourceBlobMin5, ErrImageTooSmall)
+	}
+	img := asNRGBA(src)
+	size := img.Rect.Size()
+	pieces := blobMinPieces
+	if size.Y > 0 && size.X == (blobMinPieces+1)*size.Y {
+		pieces++
+	}
+	xSegments, ySegments := pieces*sixPackSegments, sixPackSegments
+	switch {
+	case size.X < xSegments || size.Y < ySegments:
+		return nil, fmt.Errorf("%s: %w: %dx%d px can't be split into %dx1 tiles of 2x2 segments",
+			SourceBlobMin5, ErrImageTooSmall, size.X, size.Y, pieces)
+	case size.X%xSegments != 0 || size.Y%ySegments != 0:
+		return nil, fmt.Errorf("%s: %w: %dx%d px can't be split into %dx1 tiles of 2x2 segments",
+			SourceBlobMin5, ErrTileSizeNotDivisible, size.X, size.Y, pieces)
+	}
+	segW, segH := size.X/xSegments, size.Y/ySegments
+	dst := image.NewNRGBA(image.Rect(0, 0, segW*sixPackXTiles*sixPackSegments, segH*sixPackYTiles*sixPackSegments))
+	segment := image.NewNRGBA(image.Rect(0, 0, segW, segH))
+	for y, row := range blobMinTo6 {
+		for x, from := range row {
+			if from.x >= xSegments {
+				// the terrain 2 fill isn't on the strip
+				continue
+			}
+			srcMin := image.Point{X: from.x * segW, Y: from.y * segH}
+			copyArea(segment, image.Point{}, img, image.Rectangle{Min: srcMin, Max: srcMin.Add(segment.Rect.Max)})
+			copyArea(dst, image.Point{X: x * segW, Y: y * segH}, from.flip.apply(segment), segment.Rect)
+		}
+	}
+	return dst, nil
+}
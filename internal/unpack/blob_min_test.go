@@ -0,0 +1,222 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+// blobMinStripCorners describes every segment of the strip like sixQuarterCorners.
+//
+//nolint:gochecknoglobals //static layout table
+var blobMinStripCorners = [2][12]corners{
+	{0b1111, 0b1111, 0b1000, 0b1100, 0b1100, 0b1100, 0b1110, 0b1111, 0b1000, 0b0100, 0, 0},
+	{0b1111, 0b1111, 0b1010, 0b1111, 0b1111, 0b1111, 0b1111, 0b1111, 0b0010, 0b0001, 0, 0},
+}
+
+// mirror returns corners of a mirrored segment.
+func (c corners) mirror(f flip) corners {
+	var res corners
+	for corner := 0; corner < 4; corner++ {
+		if !c.has(corner) {
+			continue
+		}
+		mirrored := corner
+		if f&flipH != 0 {
+			mirrored ^= 1
+		}
+		if f&flipV != 0 {
+			mirrored ^= 2
+		}
+		res |= 1 << mirrored
+	}
+	return res
+}
+
+func TestBlobMinTo6MatchesCorners(t *testing.T) {
+	for y, row := range blobMinTo6 {
+		for x, from := range row {
+			want := sixQuarterCorners[y][x]
+			if got := blobMinStripCorners[from.y][from.x].mirror(from.flip); got != want {
+				t.Errorf("segment %d,%d: strip segment %d,%d mirrored %d has corners %04b, want %04b",
+					x, y, from.x, from.y, from.flip, got, want)
+			}
+		}
+	}
+}
+
+func TestFlip(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 5, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 5; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(y), A: 255})
+		}
+	}
+	// sub images keep their bounds, so flips have to respect them
+	img := src.SubImage(image.Rect(1, 1, 4, 3)).(*image.NRGBA)
+	h, v := flipHorizontal(img), flipVertical(img)
+	for _, got := range []*image.NRGBA{h, v} {
+		if got.Rect != image.Rect(0, 0, 3, 2) {
+			t.Fatalf("got bounds %v, want 3x2", got.Rect)
+		}
+	}
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 3; x++ {
+			if got, want := h.NRGBAAt(x, y), img.NRGBAAt(3-x, y+1); got != want {
+				t.Errorf("horizontal %d,%d: got %v, want %v", x, y, got, want)
+			}
+			if got, want := v.NRGBAAt(x, y), img.NRGBAAt(x+1, 2-y); got != want {
+				t.Errorf("vertical %d,%d: got %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+// blobMinStrip returns a strip of the given number of tiles in which every pixel encodes its segment
+// and position in it, so mirroring can be checked.
+func blobMinStrip(pieces, segSize int) *image.NRGBA {
+	strip := image.NewNRGBA(image.Rect(0, 0, pieces*2*segSize, 2*segSize))
+	for y := 0; y < strip.Rect.Dy(); y++ {
+		for x := 0; x < strip.Rect.Dx(); x++ {
+			strip.SetNRGBA(x, y, color.NRGBA{R: uint8(y/segSize*12 + x/segSize), G: uint8(x % segSize), B: uint8(y % segSize), A: 255})
+		}
+	}
+	return strip
+}
+
+func TestFrom5BlobMinTo6(t *testing.T) {
+	const segSize = 4
+	for _, pieces := range []int{blobMinPieces, blobMinPieces + 1} {
+		t.Run(fmt.Sprintf("%dx1", pieces), func(t *testing.T) {
+			six, err := From5BlobMinTo6(blobMinStrip(pieces, segSize))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := image.Rect(0, 0, 4*segSize, 6*segSize); six.Rect != want {
+				t.Fatalf("got %v, want %v", six.Rect, want)
+			}
+			for y, row := range blobMinTo6 {
+				for x, from := range row {
+					for py := 0; py < segSize; py++ {
+						for px := 0; px < segSize; px++ {
+							sx, sy := px, py
+							if from.flip&flipH != 0 {
+								sx = segSize - px - 1
+							}
+							if from.flip&flipV != 0 {
+								sy = segSize - py - 1
+							}
+							want := color.NRGBA{R: uint8(from.y*12 + from.x), G: uint8(sx), B: uint8(sy), A: 255}
+							if from.x >= pieces*2 {
+								// without the terrain 2 fill its segments stay transparent
+								want = color.NRGBA{}
+							}
+							if got := six.NRGBAAt(x*segSize+px, y*segSize+py); got != want {
+								t.Fatalf("segment %d,%d pixel %d,%d: got %v, want %v", x, y, px, py, got, want)
+							}
+						}
+					}
+				}
+			}
+
+			u := NewUnpacker(six, 2, 3, 0)
+			if err := u.Init(2); err != nil {
+				t.Fatal(err)
+			}
+			for _, layout := range ExportLayouts() {
+				if _, err := u.ExportContext(context.Background(), layout, nil); err != nil {
+					t.Errorf("%s: %v", layout, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMissingTiles(t *testing.T) {
+	tests := []struct {
+		pieces  int
+		layout  string
+		missing []image.Point
+	}{
+		// every tile with a quarter of terrain 2 alone
+		{blobMinPieces, Layout16Terrain1, []image.Point{{3, 0}, {6, 0}, {7, 0}, {8, 0}, {10, 0}, {11, 0}, {12, 0}, {13, 0}, {14, 0}, {15, 0}}},
+		{blobMinPieces, Layout28, []image.Point{{3, 0}, {6, 0}, {7, 0}, {8, 0}, {10, 0}, {11, 0}, {12, 0}, {13, 0}, {0, 1}, {1, 1}}},
+		{blobMinPieces + 1, Layout16Terrain1, nil},
+		{blobMinPieces + 1, Layout48Terrain1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%dx1_%s", tt.pieces, tt.layout), func(t *testing.T) {
+			six, err := From5BlobMinTo6(blobMinStrip(tt.pieces, 4))
+			if err != nil {
+				t.Fatal(err)
+			}
+			u := NewUnpacker(six, 2, 3, 0)
+			if err := u.Init(2); err != nil {
+				t.Fatal(err)
+			}
+			got, err := u.MissingTiles(tt.layout)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.missing) {
+				t.Errorf("got %v, want %v", got, tt.missing)
+			}
+		})
+	}
+
+	u := NewUnpacker(packSource(), 2, 3, 0)
+	if err := u.Init(2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.MissingTiles("13x3"); !errors.Is(err, ErrUnknownLayout) {
+		t.Errorf("got %v, want %v", err, ErrUnknownLayout)
+	}
+}
+
+func TestFrom5BlobMinTo6Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  image.Image
+		want error
+	}{
+		{"no image", nil, ErrImageTooSmall},
+		{"too small", image.NewNRGBA(image.Rect(0, 0, 8, 2)), ErrImageTooSmall},
+		{"not divisible", image.NewNRGBA(image.Rect(0, 0, 320, 63)), ErrTileSizeNotDivisible},
+		{"terrain 2 fill not divisible", image.NewNRGBA(image.Rect(0, 0, 378, 63)), ErrTileSizeNotDivisible},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := From5BlobMinTo6(tt.src); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"context"
+	"fmt"
+	"image"
+)
+
+// ProgressFunc receives the number of processed tiles and the total number of tiles of a tile set.
+// It is called synchronously after every tile, so it must return quickly.
+type ProgressFunc func(done, total int)
+
+// report calls the function if it is not nil.
+func (p ProgressFunc) report(done, total int) {
+	if p != nil {
+		p(done, total)
+	}
+}
+
+// pack returns the function reporting progress of the i-th of the given number of packs
+// as a part of the progress of all packs.
+func (p ProgressFunc) pack(i, packs int) ProgressFunc {
+	if p == nil || packs == 1 {
+		return p
+	}
+	return func(done, total int) {
+		p(i*total+done, packs*total)
+	}
+}
+
+// ExportContext generates the tile set of the given layout like the matching From6to* method.
+// Tile sets of random variants (see SetRandomVariants) are stacked below it.
+// Generation is cancelled between tiles (and inside rotations of large tiles) once the context is done.
+//
+// Parameters:
+// - ctx: The context which cancels generation.
+// - layout: One of Layout16Terrain1, Layout16Terrain2, Layout28, Layout48Terrain1, Layout48Terrain2, LayoutDualGrid16.
+// - progress: The function receiving the number of generated tiles after every tile, may be nil.
+//
+// Returns:
+// - A pointer to the generated tile set.
+// - An error wrapping ErrUnknownLayout, the context error or an error of the matching From6to* method.
+func (u *Unpacker) ExportContext(ctx context.Context, layout string, progress ProgressFunc) (*image.NRGBA, error) {
+	packs := len(u.variants) + 1
+	img, err := u.exportLayout(ctx, layout, progress.pack(0, packs))
+	if err != nil || packs == 1 {
+		return img, err
+	}
+	images := []*image.NRGBA{img}
+	for i, variant := range u.variants {
+		img, err := variant.exportLayout(ctx, layout, progress.pack(i+1, packs))
+		if err != nil {
+			return nil, fmt.Errorf("variant %d: %w", i+1, err)
+		}
+		images = append(images, img)
+	}
+	return stackImages(images), nil
+}
+
+// ExportSize returns the size of the tile set ExportContext generates for the given layout, including tile sets
+// of random variants, so callers can limit it before generating anything. Init has to be called first.
+//
+// Parameters:
+// - layout: One of the layouts accepted by ExportContext.
+//
+// Returns:
+// - The width and height of the tile set in px.
+// - An error wrapping ErrUnknownLayout if the layout isn't generated from a 2x3 tile set.
+func (u *Unpacker) ExportSize(layout string) (image.Point, error) {
+	sheet, err := newSixPackSheet(layout)
+	if err != nil {
+		return image.Point{}, err
+	}
+	packs := len(u.variants) + 1
+	return image.Point{X: u.paddedTileWidth() * sheet.cols, Y: u.paddedTileHeight() * sheet.rows * packs}, nil
+}
+
+// exportLayout generates the tile set of the given layout from the first pack of the source.
+func (u *Unpacker) exportLayout(ctx context.Context, layout string, progress ProgressFunc) (*image.NRGBA, error) {
+	switch layout {
+	case Layout16Terrain1:
+		return u.from6to16Terrain(ctx, layout, export6to16Terrain1TileSet(), 16, progress)
+	case Layout16Terrain2:
+		return u.from6to16Terrain(ctx, layout, export6to16Terrain2TileSet(), 16, progress)
+	case LayoutDualGrid16:
+		return u.from6to16DualGrid(ctx, progress)
+	case Layout28:
+		return u.from6to28(ctx, progress)
+	case Layout48Terrain1:
+		return u.from6to48Terrain(ctx, layout, export6to48Terrain1TileSet(), u.variantMode(layout), progress)
+	case Layout48Terrain2:
+		return u.from6to48Terrain(ctx, layout, export6to48Terrain2TileSet(), u.variantMode(layout), progress)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownLayout, layout)
+}
+
+// ExportLayouts lists layouts which can be generated by ExportContext.
+func ExportLayouts() []string {
+	return []string{Layout16Terrain1, Layout16Terrain2, Layout28, Layout48Terrain1, Layout48Terrain2, LayoutDualGrid16}
+}
+
+// DefaultExportLayouts lists layouts generated when none are requested. LayoutDualGrid16 is left out,
+// as it needs a map drawn with the offset grid.
+func DefaultExportLayouts() []string {
+	return []string{Layout16Terrain1, Layout16Terrain2, Layout28, Layout48Terrain1, Layout48Terrain2}
+}
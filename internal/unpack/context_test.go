@@ -0,0 +1,158 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"context"
+	"errors"
+	"image"
+	"sync/atomic"
+	"testing"
+)
+
+func newGoldenUnpacker(t *testing.T) *Unpacker {
+	t.Helper()
+	u := NewUnpacker(loadImage(t, goldenSource), sixPackXTiles, sixPackYTiles, 1)
+	if err := u.Init(sixPackSegments); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestExportContextMatchesFrom6to(t *testing.T) {
+	u := newGoldenUnpacker(t)
+	exports := goldenExports(u)
+	for _, layout := range ExportLayouts() {
+		want, err := exports[layout]()
+		if err != nil {
+			t.Fatal(err)
+		}
+		lastDone, lastTotal := 0, 0
+		got, err := u.ExportContext(context.Background(), layout, func(done, total int) {
+			if done != lastDone+1 || (lastTotal != 0 && total != lastTotal) {
+				t.Errorf("%s: progress %d/%d after %d/%d", layout, done, total, lastDone, lastTotal)
+			}
+			lastDone, lastTotal = done, total
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lastDone == 0 || lastDone != lastTotal {
+			t.Errorf("%s: last progress %d/%d", layout, lastDone, lastTotal)
+		}
+		if _, count := diffImage(want, got); count != 0 {
+			t.Errorf("%s: %d pixels differ from the From6to* result", layout, count)
+		}
+	}
+	if _, err := u.ExportContext(context.Background(), "3x3", nil); !errors.Is(err, ErrUnknownLayout) {
+		t.Errorf("unknown layout: got %v", err)
+	}
+}
+
+func TestExportSize(t *testing.T) {
+	_, _, both := variantSource(t)
+	variants := NewUnpacker(both, sixPackXTiles, sixPackYTiles, 1)
+	if err := variants.SetRandomVariants([]float64{3, 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := variants.Init(sixPackSegments); err != nil {
+		t.Fatal(err)
+	}
+	for name, u := range map[string]*Unpacker{"single": newGoldenUnpacker(t), "variants": variants} {
+		for _, layout := range ExportLayouts() {
+			size, err := u.ExportSize(layout)
+			if err != nil {
+				t.Fatal(err)
+			}
+			img, err := u.ExportContext(context.Background(), layout, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := img.Rect.Size(); size != want {
+				t.Errorf("%s %s: got %v, want %v", name, layout, size, want)
+			}
+		}
+	}
+	if _, err := newGoldenUnpacker(t).ExportSize("3x3"); !errors.Is(err, ErrUnknownLayout) {
+		t.Errorf("unknown layout: got %v", err)
+	}
+}
+
+func TestExportContextCancel(t *testing.T) {
+	u := newGoldenUnpacker(t)
+	for _, layout := range ExportLayouts() {
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+		_, err := u.ExportContext(ctx, layout, func(done, _ int) {
+			calls++
+			if done == 3 {
+				cancel()
+			}
+		})
+		cancel()
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: got %v, want context.Canceled", layout, err)
+		}
+		if calls != 3 {
+			t.Errorf("%s: %d tiles generated after cancellation", layout, calls-3)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	src := image.NewNRGBA(image.Rect(0, 0, 768, 256))
+	if _, err := PackContext(ctx, src, Layout48Terrain1, 0, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("pack: got %v, want context.Canceled", err)
+	}
+}
+
+func TestParallelCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var processed atomic.Int32
+	err := parallel(ctx, 0, 1000, func(ys <-chan int) {
+		for range ys {
+			if processed.Add(1) == 10 {
+				cancel()
+			}
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	if n := processed.Load(); n >= 1000 {
+		t.Errorf("all %d indices processed after cancellation", n)
+	}
+
+	if _, err := rotateLeft90(ctx, image.NewNRGBA(image.Rect(0, 0, 512, 512))); !errors.Is(err, context.Canceled) {
+		t.Errorf("rotate: got %v, want context.Canceled", err)
+	}
+	if err := parallel(context.Background(), 0, 10, func(ys <-chan int) {
+		for range ys {
+			processed.Add(1)
+		}
+	}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,105 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"fmt"
+	"image"
+)
+
+// ConvertLayout remaps a blob tile set from one layout to another by bitmask.
+// Every tile of the resulting tile set is copied (with its padding) from the tile of the source tile set
+// having the same canonical mask. The background tile is copied if both layouts have it.
+// Cells without a tile are left transparent.
+//
+// Parameters:
+// - src: The source tile set image.
+// - from: The layout of the source tile set.
+// - to: The layout of the resulting tile set.
+// - padding: The padding of every tile in px. It is kept as is in the resulting tile set.
+//
+// Returns:
+// - A pointer to the converted tile set.
+// - An error if the source tile set is too small or lacks a tile required by the resulting layout.
+func ConvertLayout(src image.Image, from, to *BlobLayout, padding int) (*image.NRGBA, error) {
+	paddedTileWidth, paddedTileHeight, err := paddedTileSize(src, from, padding)
+	if err != nil {
+		return nil, err
+	}
+	source := newTileSet(toNRGBA(src), paddedTileWidth, paddedTileHeight)
+	canvas := image.NewNRGBA(image.Rect(0, 0, paddedTileWidth*to.Cols, paddedTileHeight*to.Rows))
+	tileset := newTileSet(canvas, paddedTileWidth, paddedTileHeight)
+	for y := 0; y < to.Rows; y++ {
+		for x := 0; x < to.Cols; x++ {
+			m, ok := to.Mask(x, y)
+			if !ok {
+				continue
+			}
+			cell, ok := from.Cell(m)
+			if !ok {
+				return nil, &TileError{
+					Layout: to.Name,
+					Tile:   image.Point{X: x, Y: y},
+					Err:    fmt.Errorf("%w: mask %d is not present in %s layout", ErrMissingTile, m, from.Name),
+				}
+			}
+			tileset.setTile(x, y, source.getTile(cell.X, cell.Y))
+		}
+	}
+	fromBackground, fromOk := from.Background()
+	toBackground, toOk := to.Background()
+	if fromOk && toOk {
+		tileset.setTile(toBackground.X, toBackground.Y, source.getTile(fromBackground.X, fromBackground.Y))
+	}
+	return tileset.getCanvas(), nil
+}
+
+// paddedTileSize returns the size of a tile with its padding in a tile set of the given layout.
+//
+// Parameters:
+// - src: The tile set image.
+// - layout: The layout of the tile set.
+// - padding: The padding of every tile in px.
+//
+// Returns:
+// - The width and the height of a padded tile.
+// - An error if the padding is invalid or the image can't be split into tiles of the layout.
+func paddedTileSize(src image.Image, layout *BlobLayout, padding int) (int, int, error) {
+	if padding < 0 {
+		return 0, 0, fmt.Errorf("%s: %w: %d px", layout.Name, ErrInvalidPadding, padding)
+	}
+	size := src.Bounds().Size()
+	if size.X%layout.Cols != 0 || size.Y%layout.Rows != 0 {
+		return 0, 0, fmt.Errorf("%s: %w: %dx%d px can't be split into %dx%d tiles",
+			layout.Name, ErrTileSizeNotDivisible, size.X, size.Y, layout.Cols, layout.Rows)
+	}
+	paddedTileWidth := size.X / layout.Cols
+	paddedTileHeight := size.Y / layout.Rows
+	if paddedTileWidth-padding*2 < 1 || paddedTileHeight-padding*2 < 1 {
+		return 0, 0, fmt.Errorf("%s: %w: %dx%d px tiles with %d px padding",
+			layout.Name, ErrImageTooSmall, paddedTileWidth, paddedTileHeight, padding)
+	}
+	return paddedTileWidth, paddedTileHeight, nil
+}
@@ -0,0 +1,153 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"fmt"
+	"image"
+)
+
+// SourceCornerWang16 is the 4x4 corner Wang source layout, see references/4x4_bitmask_reference_2x2.png.
+const SourceCornerWang16 = "4x4_corner"
+
+// corners is a set of corners covered by terrain 1. The i-th bit is set if the i-th corner is terrain 1,
+// corners are ordered top-left, top-right, bottom-left, bottom-right like segments of quadTileData.
+// XOR with 1 gives the horizontally adjacent corner, with 2 the vertically adjacent one and with 3 the opposite one.
+type corners uint8
+
+// cornerWang16Tiles lists corners of every tile of the 4x4 corner Wang source row by row.
+// Corners marked in references/4x4_bitmask_reference_2x2.png are terrain 1.
+//
+//nolint:gochecknoglobals //static layout table
+var cornerWang16Tiles = [16]corners{
+	0b0100, 0b1010, 0b1101, 0b1100,
+	0b1001, 0b1110, 0b1111, 0b0111,
+	0b0010, 0b0011, 0b1011, 0b0101,
+	0b0000, 0b1000, 0b0110, 0b0001,
+}
+
+// sixQuarterCorners describes every segment of a 2x3 tile set as corners of a tiny corner Wang tile, row by row.
+// Segments are indexed by quadTileData coordinates: the top left tile is terrain 2, the top right one
+// holds inner corners and the bottom 2x2 tiles are a blob of terrain 1 surrounded by terrain 2.
+//
+//nolint:gochecknoglobals //static layout table
+var sixQuarterCorners = [6][4]corners{
+	{0b0000, 0b0000, 0b1110, 0b1101},
+	{0b0000, 0b0000, 0b1011, 0b0111},
+	{0b1000, 0b1100, 0b1100, 0b0100},
+	{0b1010, 0b1111, 0b1111, 0b0101},
+	{0b1010, 0b1111, 0b1111, 0b0101},
+	{0b0010, 0b0011, 0b0011, 0b0001},
+}
+
+// has reports whether the corner is terrain 1.
+func (c corners) has(corner int) bool {
+	return c&(1<<corner) != 0
+}
+
+// segment returns corners of the given segment of a corner Wang tile.
+// The segment spans from the tile corner to the tile center. Terrain 1 draws the border,
+// so a point of the segment is terrain 1 only if every tile corner around it is terrain 1:
+// the tile corner itself, the middle of a tile side touches two corners and the center touches all four.
+func (c corners) segment(corner int) corners {
+	var res corners
+	if !c.has(corner) {
+		return res
+	}
+	res |= 1 << corner
+	if c.has(corner ^ 1) {
+		res |= 1 << (corner ^ 1)
+	}
+	if c.has(corner ^ 2) {
+		res |= 1 << (corner ^ 2)
+	}
+	if c == 0b1111 {
+		res |= 1 << (corner ^ 3)
+	}
+	return res
+}
+
+// cornerWangSegment returns the tile of the 4x4 corner Wang source and its segment which looks like
+// the given segment of a 2x3 tile set. It is a segment of the tile with the same corners, as other tiles
+// may have terrain 1 in corners which don't affect the segment but bleed into it. The segment at the same
+// position is preferred. An error wrapping ErrMissingSegment is returned if no tile has such a segment.
+func cornerWangSegment(want corners, preferred int) (tile, segment int, err error) {
+	for i, c := range cornerWang16Tiles {
+		if c != want {
+			continue
+		}
+		for _, seg := range []int{preferred, preferred ^ 1, preferred ^ 2, preferred ^ 3} {
+			if c.segment(seg) == want {
+				return i, seg, nil
+			}
+		}
+	}
+	return 0, 0, fmt.Errorf("%w: no corner Wang tile segment has corners %04b", ErrMissingSegment, want)
+}
+
+// From16CornerTo6 converts a 4x4 corner Wang tile set into a 2x3 tile set, so every 2x3 export can be generated from it.
+// Every segment of the 2x3 tile set is copied from the corner Wang tile having the same corners,
+// e.g. the inner corner segments come from the tiles with a single terrain 2 corner.
+// Tiles with terrain 2 in opposite corners are not needed.
+//
+// Parameters:
+// - src: The 4x4 corner Wang tile set laid out as references/4x4_bitmask_reference_2x2.png.
+//
+// Returns:
+// - The 2x3 tile set with tiles of the same size, which can be passed to NewUnpacker.
+// - An error if the image can't be split into 4x4 tiles of 2x2 segments.
+func From16CornerTo6(src image.Image) (*image.NRGBA, error) {
+	if src == nil {
+		return nil, fmt.Errorf("%s: %w: no image", SourceCornerWang16, ErrImageTooSmall)
+	}
+	img := asNRGBA(src)
+	size := img.Rect.Size()
+	const segments = 4 * sixPackSegments
+	switch {
+	case size.X < segments || size.Y < segments:
+		return nil, fmt.Errorf("%s: %w: %dx%d px can't be split into 4x4 tiles of 2x2 segments",
+			SourceCornerWang16, ErrImageTooSmall, size.X, size.Y)
+	case size.X%segments != 0 || size.Y%segments != 0:
+		return nil, fmt.Errorf("%s: %w: %dx%d px can't be split into 4x4 tiles of 2x2 segments",
+			SourceCornerWang16, ErrTileSizeNotDivisible, size.X, size.Y)
+	}
+	segW, segH := size.X/segments, size.Y/segments
+	dst := image.NewNRGBA(image.Rect(0, 0, segW*sixPackXTiles*sixPackSegments, segH*sixPackYTiles*sixPackSegments))
+	for y, row := range sixQuarterCorners {
+		for x, want := range row {
+			tile, seg, err := cornerWangSegment(want, x%2+y%2*2)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", SourceCornerWang16, err)
+			}
+			srcMin := img.Rect.Min.Add(image.Point{
+				X: (tile%4*2 + seg%2) * segW,
+				Y: (tile/4*2 + seg/2) * segH,
+			})
+			copyArea(dst, image.Point{X: x * segW, Y: y * segH}, img,
+				image.Rectangle{Min: srcMin, Max: srcMin.Add(image.Point{X: segW, Y: segH})})
+		}
+	}
+	return dst, nil
+}
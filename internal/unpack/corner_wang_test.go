@@ -0,0 +1,117 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// cornerWangIDSheet returns a 4x4 corner Wang tile set with segments of the given size,
+// every segment is filled with a color encoding its tile and segment.
+func cornerWangIDSheet(segSize int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 8*segSize, 8*segSize))
+	for y := 0; y < img.Rect.Dy(); y++ {
+		for x := 0; x < img.Rect.Dx(); x++ {
+			sx, sy := x/segSize, y/segSize
+			tile := sy/2*4 + sx/2
+			seg := sy%2*2 + sx%2
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(tile), G: uint8(seg), A: 255})
+		}
+	}
+	return img
+}
+
+func TestCornerWangSegmentsMatchCorners(t *testing.T) {
+	for y, row := range sixQuarterCorners {
+		for x, want := range row {
+			tile, seg, err := cornerWangSegment(want, x%2+y%2*2)
+			if err != nil {
+				t.Errorf("segment %d,%d: %v", x, y, err)
+				continue
+			}
+			if got := cornerWang16Tiles[tile]; got != want {
+				t.Errorf("segment %d,%d: taken from tile %04b, want tile %04b", x, y, got, want)
+			}
+			if got := cornerWang16Tiles[tile].segment(seg); got != want {
+				t.Errorf("segment %d,%d: segment %d of tile %04b has corners %04b, want %04b",
+					x, y, seg, cornerWang16Tiles[tile], got, want)
+			}
+		}
+	}
+}
+
+func TestFrom16CornerTo6(t *testing.T) {
+	const segSize = 4
+	six, err := From16CornerTo6(cornerWangIDSheet(segSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := image.Rect(0, 0, 4*segSize, 6*segSize); six.Rect != want {
+		t.Fatalf("got %v, want %v", six.Rect, want)
+	}
+	for y, row := range sixQuarterCorners {
+		for x, want := range row {
+			c := six.NRGBAAt(x*segSize+segSize/2, y*segSize+segSize/2)
+			tile, seg := int(c.R), int(c.G)
+			if got := cornerWang16Tiles[tile].segment(seg); got != want {
+				t.Errorf("segment %d,%d: copied from tile %d segment %d with corners %04b, want %04b",
+					x, y, tile, seg, got, want)
+			}
+		}
+	}
+
+	u := NewUnpacker(six, 2, 3, 0)
+	if err := u.Init(2); err != nil {
+		t.Fatal(err)
+	}
+	for _, layout := range ExportLayouts() {
+		if _, err := u.ExportContext(context.Background(), layout, nil); err != nil {
+			t.Errorf("%s: %v", layout, err)
+		}
+	}
+}
+
+func TestFrom16CornerTo6Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  image.Image
+		want error
+	}{
+		{"no image", nil, ErrImageTooSmall},
+		{"too small", image.NewNRGBA(image.Rect(0, 0, 4, 8)), ErrImageTooSmall},
+		{"not divisible", image.NewNRGBA(image.Rect(0, 0, 64, 60)), ErrTileSizeNotDivisible},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := From16CornerTo6(tt.src); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"fmt"
+	"image"
+)
+
+// Terrains of a 2x3 tile set.
+const (
+	// Terrain1 is the terrain inside the blob of the lower 2x2 tiles of a 2x3 tile set.
+	Terrain1 = 1
+	// Terrain2 is the terrain filling the top left tile of a 2x3 tile set.
+	Terrain2 = 2
+)
+
+// TileInfo describes a tile of a tile set generated from a 2x3 tile set.
+type TileInfo struct {
+	// Cell is the position of the tile in the tile set.
+	Cell image.Point
+	// Mask is the bitmask of the tile. A bit is set if the neighbour belongs to the same blob.
+	Mask Mask
+	// Terrain is the terrain of the blob (Terrain1 or Terrain2). The other terrain surrounds it.
+	Terrain int
+	// Background is true if the tile is completely filled with the terrain surrounding the blob.
+	Background bool
+	// Corners are terrains at the top-left, top-right, bottom-left and bottom-right corners
+	// of a LayoutDualGrid16 tile and zero for blob tiles.
+	Corners [4]int
+}
+
+// LayoutInfo describes tiles of a tile set layout generated from a 2x3 tile set.
+type LayoutInfo struct {
+	Name       string
+	Cols, Rows int
+	Tiles      []TileInfo
+}
+
+// rotationOrbitMasks lists masks of tiles of the terrain halves of 14x2 tile set after the background tile:
+// a representative of every 47 blob tiles rotation orbit except isolated tile and filled one.
+//
+//nolint:gochecknoglobals //static layout table
+var rotationOrbitMasks = []Mask{1, 5, 7, 17, 21, 23, 29, 31, 85, 87, 95, 119, 127}
+
+// orbitTileInfos returns tiles of the terrain half of 14x2 tile set for blobs of the given terrain.
+func orbitTileInfos(terrain int) []TileInfo {
+	res := []TileInfo{{Terrain: terrain, Background: true}}
+	for _, m := range rotationOrbitMasks {
+		res = append(res, TileInfo{Terrain: terrain, Mask: m})
+	}
+	return res
+}
+
+// DescribeLayout returns masks and terrains of tiles of a layout generated from a 2x3 tile set.
+// Exporters use it to write engine specific terrain data.
+//
+// Parameters:
+// - layout: The layout name (one of Layout* constants).
+//
+// Returns:
+// - A LayoutInfo with a TileInfo for every cell holding a tile.
+// - An error wrapping ErrUnknownLayout if the layout is not known.
+func DescribeLayout(layout string) (*LayoutInfo, error) {
+	sheet, err := newSixPackSheet(layout)
+	if err != nil {
+		return nil, err
+	}
+	res := &LayoutInfo{Name: layout, Cols: sheet.cols, Rows: sheet.rows}
+	var tiles []TileInfo
+	switch layout {
+	case Layout28:
+		tiles = append(orbitTileInfos(Terrain2), orbitTileInfos(Terrain1)...)
+	case Layout16Terrain1, Layout4x4Terrain1:
+		tiles = append(orbitTileInfos(Terrain2),
+			TileInfo{Terrain: Terrain1, Mask: North}, TileInfo{Terrain: Terrain2, Mask: 255})
+	case Layout16Terrain2, Layout4x4Terrain2:
+		tiles = append(orbitTileInfos(Terrain1),
+			TileInfo{Terrain: Terrain2, Mask: North}, TileInfo{Terrain: Terrain1, Mask: 255})
+	case LayoutDualGrid16:
+		tiles = dualGridTileInfos()
+	case Layout48Terrain1, Layout48Terrain2:
+		blob, err := NewBlobLayout(BlobLayout12x4)
+		if err != nil {
+			return nil, err
+		}
+		terrain := Terrain2
+		if layout == Layout48Terrain2 {
+			terrain = Terrain1
+		}
+		for y := 0; y < blob.Rows; y++ {
+			for x := 0; x < blob.Cols; x++ {
+				m, ok := blob.Mask(x, y)
+				res.Tiles = append(res.Tiles, TileInfo{Cell: image.Point{X: x, Y: y}, Mask: m, Terrain: terrain, Background: !ok})
+			}
+		}
+		return res, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownLayout, layout)
+	}
+	for i, tile := range tiles {
+		tile.Cell = image.Point{X: i % sheet.cols, Y: i / sheet.cols}
+		res.Tiles = append(res.Tiles, tile)
+	}
+	return res, nil
+}
+
+// TerrainGrid returns the terrain at the corners, middles of edges and the center of the tile (grid[y][x]).
+// Sides belong to the blob if the neighbour is set, corners if the corner neighbour and both adjacent ones are set.
+// The center belongs to the blob unless the tile is an end piece (a single side set).
+// Tiles of a dual grid follow CornerWangTile.TerrainGrid instead.
+func (t TileInfo) TerrainGrid() [3][3]int {
+	if t.Corners != [4]int{} {
+		return CornerWangTile{Corners: t.Corners}.TerrainGrid()
+	}
+	var grid [3][3]int
+	surrounding := Terrain1 + Terrain2 - t.Terrain
+	for y := range grid {
+		for x := range grid[y] {
+			grid[y][x] = surrounding
+		}
+	}
+	if t.Background {
+		return grid
+	}
+	m := t.Mask.Canonical()
+	sides := 0
+	for _, n := range gridNeighbours {
+		if m&n.bit == 0 {
+			continue
+		}
+		grid[n.y][n.x] = t.Terrain
+		if n.bit == North || n.bit == East || n.bit == South || n.bit == West {
+			sides++
+		}
+	}
+	if sides != 1 {
+		grid[1][1] = t.Terrain
+	}
+	return grid
+}
+
+// gridNeighbours maps mask bits to positions in a terrain grid in the order of bits.
+//
+//nolint:gochecknoglobals //static table
+var gridNeighbours = [8]struct {
+	bit  Mask
+	x, y int
+}{
+	{North, 1, 0}, {NorthEast, 2, 0}, {East, 2, 1}, {SouthEast, 2, 2},
+	{South, 1, 2}, {SouthWest, 0, 2}, {West, 0, 1}, {NorthWest, 0, 0},
+}
@@ -0,0 +1,66 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"testing"
+)
+
+// TestDescribeLayout checks that tile descriptions used by exporters match the tiles verified by TestTilesMatchBitmask.
+func TestDescribeLayout(t *testing.T) {
+	layouts := []string{
+		Layout16Terrain1, Layout16Terrain2, Layout4x4Terrain1, Layout4x4Terrain2,
+		Layout28, Layout48Terrain1, Layout48Terrain2,
+	}
+	for _, layout := range layouts {
+		info, err := DescribeLayout(layout)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := sheetTiles(t, layout)
+		if len(info.Tiles) != len(want) {
+			t.Errorf("%s: %d tiles, want %d", layout, len(info.Tiles), len(want))
+		}
+		for _, tile := range info.Tiles {
+			w, ok := want[tile.Cell]
+			if !ok {
+				t.Errorf("%s: unexpected tile %v", layout, tile.Cell)
+				continue
+			}
+			if tile.Background != w.background || (!w.background && tile.Mask != w.mask) ||
+				(tile.Terrain == Terrain1) != w.terrain1 {
+				t.Errorf("%s: tile %v is %+v, want %+v", layout, tile.Cell, tile, w)
+			}
+			grid, wantGrid := tile.TerrainGrid(), w.expectedGrid()
+			for y := range grid {
+				for x := range grid[y] {
+					if (grid[y][x] == Terrain1) != wantGrid[y][x] {
+						t.Errorf("%s: tile %v has terrain grid %v, want %v", layout, tile.Cell, grid, wantGrid)
+					}
+				}
+			}
+		}
+	}
+}
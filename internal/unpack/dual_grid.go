@@ -0,0 +1,174 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"context"
+	"fmt"
+	"image"
+)
+
+// LayoutDualGrid16 is the 4x4 tile set of a dual grid: tiles of the display grid, which is offset by half a tile
+// from the logic grid, so every display tile covers quarters of four logic cells and shows the terrains
+// of these cells at its corners. Tiles are ordered like references/4x4_bitmask_reference_2x2.png
+// (see SourceCornerWang16), so the tile set can be read back as a corner Wang source.
+const LayoutDualGrid16 = "4x4_dual"
+
+// dualGridCols is the number of columns and rows of LayoutDualGrid16.
+const dualGridCols = 4
+
+// From6to16DualGrid generates the 4x4 LayoutDualGrid16 tile set image from a 2x3 tile set.
+// Tiles are composed from segments like From6to16Terrain1, but cover all 16 combinations of corner terrains.
+//
+// Parameters:
+//
+//	none
+//
+// Returns:
+//
+//	*image.NRGBA - a pointer to the generated image
+//	error - an error if the pack type is invalid
+func (u *Unpacker) From6to16DualGrid() (*image.NRGBA, error) {
+	return u.from6to16DualGrid(context.Background(), nil)
+}
+
+// from6to16DualGrid is From6to16DualGrid which can be cancelled with the context and reports progress.
+func (u *Unpacker) from6to16DualGrid(ctx context.Context, progress ProgressFunc) (*image.NRGBA, error) {
+	quadMap, err := exportDualGridTileSet()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", LayoutDualGrid16, err)
+	}
+	return u.from6to16Terrain(ctx, LayoutDualGrid16, quadMap, dualGridCols, progress)
+}
+
+// exportDualGridTileSet generates a quad from a 2x3 tile set required to build the LayoutDualGrid16 tile set.
+// Every segment of a tile is the segment of the 2x3 tile set with the same corners, the one at the same position
+// in its tile is preferred.
+//
+// Returns:
+//
+//	[]quadTileData - a slice of 16 quadTileData in the order of cornerWang16Tiles
+//	error - an error wrapping ErrMissingSegment if no segment of the 2x3 tile set has the corners of a tile segment
+func exportDualGridTileSet() ([]quadTileData, error) {
+	var drawn [6][4]bool
+	for y := range drawn {
+		for x := range drawn[y] {
+			drawn[y][x] = true
+		}
+	}
+	res := make([]quadTileData, 0, len(cornerWang16Tiles))
+	for _, c := range cornerWang16Tiles {
+		var data [4][2]int
+		for seg := range data {
+			xy, err := sourceSegment(c.segment(seg), seg, drawn)
+			if err != nil {
+				return nil, err
+			}
+			data[seg] = xy
+		}
+		res = append(res, &data)
+	}
+	return res, nil
+}
+
+// dualGridTileInfos returns tiles of LayoutDualGrid16 in the order of cornerWang16Tiles.
+// The tile with terrain 2 at every corner is the background.
+func dualGridTileInfos() []TileInfo {
+	res := make([]TileInfo, 0, len(cornerWang16Tiles))
+	for _, c := range cornerWang16Tiles {
+		tile := TileInfo{Terrain: Terrain1, Background: c == 0}
+		for corner := range tile.Corners {
+			tile.Corners[corner] = Terrain2
+			if c.has(corner) {
+				tile.Corners[corner] = Terrain1
+			}
+		}
+		res = append(res, tile)
+	}
+	return res
+}
+
+// DualGridCell returns the position of the LayoutDualGrid16 tile with the given terrains at its corners.
+//
+// Parameters:
+// - topLeft, topRight, bottomLeft, bottomRight: Terrain1 or Terrain2.
+//
+// Returns:
+// - The column and row of the tile.
+// - An error wrapping ErrUnknownTerrain if a terrain is neither Terrain1 nor Terrain2.
+func DualGridCell(topLeft, topRight, bottomLeft, bottomRight int) (image.Point, error) {
+	var want corners
+	for corner, terrain := range [4]int{topLeft, topRight, bottomLeft, bottomRight} {
+		switch terrain {
+		case Terrain1:
+			want |= 1 << corner
+		case Terrain2:
+		default:
+			return image.Point{}, fmt.Errorf("%w: %d in %s corner", ErrUnknownTerrain, terrain, segmentNames[corner])
+		}
+	}
+	for i, c := range cornerWang16Tiles {
+		if c == want {
+			return image.Point{X: i % dualGridCols, Y: i / dualGridCols}, nil
+		}
+	}
+	return image.Point{}, fmt.Errorf("%w: no dual grid tile has corners %04b", ErrMissingTile, want)
+}
+
+// DualGrid maps a logic grid of terrains to the display grid of LayoutDualGrid16 tiles.
+// The display grid has a row and a column more than the logic grid and is drawn offset by half a tile
+// up and left: the display tile at x, y covers the logic cells x-1..x, y-1..y and shows their terrains
+// at its corners. Cells outside the logic grid, including the missing ends of shorter rows, are Terrain2.
+//
+// Parameters:
+// - logic: Terrains of the logic cells, logic[y][x] is Terrain1 or Terrain2.
+//
+// Returns:
+// - Positions of tiles in the LayoutDualGrid16 tile set for every display cell, display[y][x].
+// - An error wrapping ErrUnknownTerrain if a logic cell is neither Terrain1 nor Terrain2.
+func DualGrid(logic [][]int) ([][]image.Point, error) {
+	width := 0
+	for _, row := range logic {
+		width = max(width, len(row))
+	}
+	terrain := func(x, y int) int {
+		if y < 0 || y >= len(logic) || x < 0 || x >= len(logic[y]) {
+			return Terrain2
+		}
+		return logic[y][x]
+	}
+	display := make([][]image.Point, len(logic)+1)
+	for y := range display {
+		display[y] = make([]image.Point, width+1)
+		for x := range display[y] {
+			cell, err := DualGridCell(terrain(x-1, y-1), terrain(x, y-1), terrain(x-1, y), terrain(x, y))
+			if err != nil {
+				return nil, fmt.Errorf("display cell %d,%d: %w", x, y, err)
+			}
+			display[y][x] = cell
+		}
+	}
+	return display, nil
+}
@@ -0,0 +1,116 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"errors"
+	"image"
+	"testing"
+)
+
+func TestDualGridCell(t *testing.T) {
+	seen := make(map[image.Point]bool)
+	for _, tile := range dualGridTileInfos() {
+		c := tile.Corners
+		cell, err := DualGridCell(c[0], c[1], c[2], c[3])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[cell] {
+			t.Errorf("corners %v: cell %v is used twice", c, cell)
+		}
+		seen[cell] = true
+	}
+	// references/4x4_bitmask_reference_2x2.png: terrain 1 at every corner is the 3rd tile of the 2nd row
+	if cell, err := DualGridCell(Terrain1, Terrain1, Terrain1, Terrain1); err != nil || cell != (image.Point{X: 2, Y: 1}) {
+		t.Errorf("filled tile at %v: %v", cell, err)
+	}
+	if _, err := DualGridCell(Terrain1, 3, Terrain1, Terrain1); !errors.Is(err, ErrUnknownTerrain) {
+		t.Errorf("unknown terrain: got %v", err)
+	}
+}
+
+func TestDualGrid(t *testing.T) {
+	logic := [][]int{
+		{Terrain1, Terrain2},
+		{Terrain1},
+	}
+	display, err := DualGrid(logic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(display) != 3 || len(display[0]) != 3 {
+		t.Fatalf("got %dx%d display cells, want 3x3", len(display[0]), len(display))
+	}
+	cell := func(tl, tr, bl, br int) image.Point {
+		t.Helper()
+		p, err := DualGridCell(tl, tr, bl, br)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	t1, t2 := Terrain1, Terrain2
+	want := [3][3]image.Point{
+		{cell(t2, t2, t2, t1), cell(t2, t2, t1, t2), cell(t2, t2, t2, t2)},
+		{cell(t2, t1, t2, t1), cell(t1, t2, t1, t2), cell(t2, t2, t2, t2)},
+		{cell(t2, t1, t2, t2), cell(t1, t2, t2, t2), cell(t2, t2, t2, t2)},
+	}
+	for y, row := range want {
+		for x, p := range row {
+			if display[y][x] != p {
+				t.Errorf("display cell %d,%d: got %v, want %v", x, y, display[y][x], p)
+			}
+		}
+	}
+	if _, err := DualGrid([][]int{{Terrain1, 0}}); !errors.Is(err, ErrUnknownTerrain) {
+		t.Errorf("unknown terrain: got %v", err)
+	}
+}
+
+func TestDualGridReadsBackAsCornerWang(t *testing.T) {
+	u := NewUnpacker(loadImage(t, goldenSource), sixPackXTiles, sixPackYTiles, 0)
+	if err := u.Init(sixPackSegments); err != nil {
+		t.Fatal(err)
+	}
+	dual, err := u.From6to16DualGrid()
+	if err != nil {
+		t.Fatal(err)
+	}
+	six, err := From16CornerTo6(dual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, count := diffImage(u.src, six); count != 0 {
+		t.Errorf("%d pixels of the 2x3 tile set read back from the dual grid tile set differ", count)
+	}
+	res, err := Pack(dual, LayoutDualGrid16, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Conflicts) != 0 {
+		t.Errorf("pack: %d conflicts", len(res.Conflicts))
+	}
+}
@@ -0,0 +1,155 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"context"
+	"fmt"
+	"image"
+)
+
+// SourceEdgeStrip is a strip of square edge Wang pieces: straight, corner, T, cross, end and optionally
+// a piece without connections, see edgeStripPieces for their orientation.
+const SourceEdgeStrip = "6x1_edge"
+
+// LayoutEdgeWang16 is the 4x4 edge Wang tile set generated by FromEdgeStrip. The index of a tile row by row
+// is its Edges, so the tile 0 has no connections and the tile 15 connects all sides.
+const LayoutEdgeWang16 = "4x4_edge"
+
+// Edges is a set of sides of an edge Wang tile connected to the neighbour, e.g. by a road or a river.
+type Edges uint8
+
+// Sides of an edge Wang tile.
+const (
+	EdgeNorth Edges = 1 << iota
+	EdgeEast
+	EdgeSouth
+	EdgeWest
+)
+
+const (
+	// edgeWangCols is the number of columns and rows of LayoutEdgeWang16.
+	edgeWangCols = 4
+	// edgeWangTiles is the number of tiles of LayoutEdgeWang16, a tile for every Edges.
+	edgeWangTiles = edgeWangCols * edgeWangCols
+	// minEdgeStripPieces is the number of pieces of SourceEdgeStrip without the one without connections.
+	minEdgeStripPieces = 5
+)
+
+// edgeStripPieces lists sides connected by pieces of SourceEdgeStrip in the order of the strip:
+// a vertical straight piece, a corner from north to east, a T opened to the south, a cross, an end
+// going north and a tile without connections. Other tiles are rotations of them.
+//
+//nolint:gochecknoglobals //static layout table
+var edgeStripPieces = [...]Edges{
+	EdgeNorth | EdgeSouth,
+	EdgeNorth | EdgeEast,
+	EdgeEast | EdgeSouth | EdgeWest,
+	EdgeNorth | EdgeEast | EdgeSouth | EdgeWest,
+	EdgeNorth,
+	0,
+}
+
+// rotatedLeft returns sides of the tile rotated 90 degrees to the left: north becomes west, east becomes north.
+func (e Edges) rotatedLeft() Edges {
+	return (e>>1 | e<<3) & (EdgeNorth | EdgeEast | EdgeSouth | EdgeWest)
+}
+
+// Mask returns the blob bitmask with neighbours at the connected sides set.
+func (e Edges) Mask() Mask {
+	var res Mask
+	for i, side := range []Mask{North, East, South, West} {
+		if e&(1<<i) != 0 {
+			res |= side
+		}
+	}
+	return res
+}
+
+// edgePiece returns the piece of SourceEdgeStrip and the number of turns to the left giving the tile with the sides.
+// An error wrapping ErrMissingTile is returned if no piece turns into the tile.
+func edgePiece(e Edges) (piece, turns int, err error) {
+	for piece, sides := range edgeStripPieces {
+		for turns := 0; turns < 4; turns++ {
+			if sides == e {
+				return piece, turns, nil
+			}
+			sides = sides.rotatedLeft()
+		}
+	}
+	return 0, 0, fmt.Errorf("%w: no edge strip piece turns into sides %04b", ErrMissingTile, e)
+}
+
+// FromEdgeStrip generates the LayoutEdgeWang16 tile set from a SourceEdgeStrip strip.
+// Every tile is a piece of the strip rotated to connect its sides. A strip of 5 pieces lacks the piece
+// without connections, so the tile 0 is left transparent.
+//
+// Parameters:
+// - ctx: The context which cancels generation between tiles.
+// - src: The strip of 5 or 6 square tiles.
+// - padding: The padding of every generated tile in px.
+// - progress: The function receiving the number of generated tiles, may be nil.
+//
+// Returns:
+// - A pointer to the generated image.
+// - An error if the image isn't a strip of 5 or 6 square tiles or the padding is negative.
+func FromEdgeStrip(ctx context.Context, src image.Image, padding int, progress ProgressFunc) (*image.NRGBA, error) {
+	if src == nil {
+		return nil, fmt.Errorf("%s: %w: no image", SourceEdgeStrip, ErrImageTooSmall)
+	}
+	if padding < 0 {
+		return nil, fmt.Errorf("%s: %w: %d px", LayoutEdgeWang16, ErrInvalidPadding, padding)
+	}
+	img := asNRGBA(src)
+	size := img.Rect.Size()
+	tileSize := size.Y
+	if tileSize == 0 || size.X != tileSize*minEdgeStripPieces && size.X != tileSize*len(edgeStripPieces) {
+		return nil, fmt.Errorf("%s: %w: %dx%d px isn't a strip of %d or %d square tiles",
+			SourceEdgeStrip, ErrInvalidTileSize, size.X, size.Y, minEdgeStripPieces, len(edgeStripPieces))
+	}
+	pieces := size.X / tileSize
+	paddedSize := tileSize + padding*2
+	tiles := newTileSet(image.NewNRGBA(image.Rect(0, 0, paddedSize*edgeWangCols, paddedSize*edgeWangCols)), paddedSize, paddedSize)
+	for i := 0; i < edgeWangTiles; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("%s: %w", LayoutEdgeWang16, err)
+		}
+		piece, turns, err := edgePiece(Edges(i))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", LayoutEdgeWang16, err)
+		}
+		if piece < pieces {
+			tile := image.NewNRGBA(image.Rect(0, 0, paddedSize, paddedSize))
+			pieceMin := img.Rect.Min.Add(image.Point{X: piece * tileSize})
+			copyArea(tile, image.Point{X: padding, Y: padding}, img,
+				image.Rectangle{Min: pieceMin, Max: pieceMin.Add(image.Point{X: tileSize, Y: tileSize})})
+			if _, err := tiles.setTileWithRotation(ctx, i%edgeWangCols, i/edgeWangCols, tile, turns); err != nil {
+				return nil, fmt.Errorf("%s: %w", LayoutEdgeWang16, err)
+			}
+		}
+		progress.report(i+1, edgeWangTiles)
+	}
+	return tiles.getCanvas(), nil
+}
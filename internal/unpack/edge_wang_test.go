@@ -0,0 +1,149 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// edgeSideMiddles lists middles of sides of a 5 px tile in the order of Edges bits.
+//
+//nolint:gochecknoglobals //static table
+var edgeSideMiddles = [4]image.Point{{X: 2, Y: 0}, {X: 4, Y: 2}, {X: 2, Y: 4}, {X: 0, Y: 2}}
+
+// edgeStrip returns a strip of the given number of 5 px pieces marking middles of connected sides red.
+func edgeStrip(pieces int) *image.NRGBA {
+	const size = 5
+	strip := image.NewNRGBA(image.Rect(0, 0, pieces*size, size))
+	for piece := 0; piece < pieces; piece++ {
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				strip.SetNRGBA(piece*size+x, y, color.NRGBA{G: 128, A: 255})
+			}
+		}
+		for i, p := range edgeSideMiddles {
+			if edgeStripPieces[piece]&(1<<i) != 0 {
+				strip.SetNRGBA(piece*size+p.X, p.Y, color.NRGBA{R: 255, A: 255})
+			}
+		}
+	}
+	return strip
+}
+
+func TestEdgesRotatedLeft(t *testing.T) {
+	if got := (EdgeNorth | EdgeEast).rotatedLeft(); got != EdgeWest|EdgeNorth {
+		t.Errorf("got %04b", got)
+	}
+	if got := (EdgeNorth | EdgeWest).Mask(); got != North|West {
+		t.Errorf("mask %08b", got)
+	}
+	for e := Edges(0); e < edgeWangTiles; e++ {
+		if got := e.rotatedLeft().rotatedLeft().rotatedLeft().rotatedLeft(); got != e {
+			t.Errorf("%04b turned 4 times is %04b", e, got)
+		}
+	}
+}
+
+func TestEdgePieces(t *testing.T) {
+	for e := Edges(0); e < edgeWangTiles; e++ {
+		piece, turns, err := edgePiece(e)
+		if err != nil {
+			t.Errorf("%04b: %v", e, err)
+			continue
+		}
+		sides := edgeStripPieces[piece]
+		for i := 0; i < turns; i++ {
+			sides = sides.rotatedLeft()
+		}
+		if sides != e {
+			t.Errorf("%04b: piece %d turned %d times has sides %04b", e, piece, turns, sides)
+		}
+	}
+}
+
+func TestFromEdgeStrip(t *testing.T) {
+	const size, padding = 5, 1
+	var reported int
+	img, err := FromEdgeStrip(context.Background(), edgeStrip(len(edgeStripPieces)), padding,
+		func(done, total int) { reported = done })
+	if err != nil {
+		t.Fatal(err)
+	}
+	const padded = size + 2*padding
+	if img.Rect != image.Rect(0, 0, 4*padded, 4*padded) || reported != edgeWangTiles {
+		t.Fatalf("got %v after %d tiles", img.Rect, reported)
+	}
+	for e := Edges(0); e < edgeWangTiles; e++ {
+		tileMin := image.Point{X: int(e)%4*padded + padding, Y: int(e)/4*padded + padding}
+		for i, p := range edgeSideMiddles {
+			connected := img.NRGBAAt(tileMin.X+p.X, tileMin.Y+p.Y).R == 255
+			if want := e&(1<<i) != 0; connected != want {
+				t.Errorf("tile %04b: side %d connected %v, want %v", e, i, connected, want)
+			}
+		}
+		if got := img.NRGBAAt(tileMin.X-padding, tileMin.Y-padding); got.A != 0 {
+			t.Errorf("tile %04b: padding is drawn %v", e, got)
+		}
+	}
+
+	// without the last piece the tile without connections is transparent
+	img, err = FromEdgeStrip(context.Background(), edgeStrip(minEdgeStripPieces), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, other := img.NRGBAAt(2, 2), img.NRGBAAt(size+2, 2); got.A != 0 || other.A == 0 {
+		t.Errorf("got tile 0 pixel %v, tile 1 pixel %v", got, other)
+	}
+}
+
+func TestFromEdgeStripErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     image.Image
+		padding int
+		want    error
+	}{
+		{"no image", nil, 0, ErrImageTooSmall},
+		{"empty", image.NewNRGBA(image.Rect(0, 0, 0, 0)), 0, ErrInvalidTileSize},
+		{"not a strip", image.NewNRGBA(image.Rect(0, 0, 40, 10)), 0, ErrInvalidTileSize},
+		{"negative padding", edgeStrip(6), -1, ErrInvalidPadding},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := FromEdgeStrip(context.Background(), tt.src, tt.padding, nil); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := FromEdgeStrip(ctx, edgeStrip(6), 0, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled: got %v", err)
+	}
+}
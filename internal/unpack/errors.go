@@ -0,0 +1,94 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"errors"
+	"fmt"
+	"image"
+)
+
+// Errors returned by the package. They are wrapped with the context (layout, tile set size, tile coordinates),
+// so they have to be checked with errors.Is.
+var (
+	// ErrUnsupportedPackType is returned when a tile set is generated from a packed tile set of a different type.
+	ErrUnsupportedPackType = errors.New("unsupported pack type")
+	// ErrInvalidTileCount is returned when the number of tiles or tile segments of a packed tile set is not positive.
+	ErrInvalidTileCount = errors.New("invalid number of tiles")
+	// ErrImageTooSmall is returned when tiles of an image can't be split into the required segments.
+	ErrImageTooSmall = errors.New("image is too small")
+	// ErrTileSizeNotDivisible is returned when an image can't be split into equal tiles and tile segments.
+	ErrTileSizeNotDivisible = errors.New("tile size is not divisible")
+	// ErrInvalidTileSize is returned when tiles of a tile set don't have the size required by the layout.
+	ErrInvalidTileSize = errors.New("invalid tile size")
+	// ErrInvalidPadding is returned when the padding is negative or doesn't fit into a tile.
+	ErrInvalidPadding = errors.New("invalid padding")
+	// ErrUnknownLayout is returned when a layout name is not known.
+	ErrUnknownLayout = errors.New("unknown layout")
+	// ErrNotInitialized is returned when a tile set is generated before Unpacker.Init succeeded.
+	ErrNotInitialized = errors.New("unpacker is not initialized")
+	// ErrMissingTile is returned when a source tile set lacks a tile required by the resulting layout.
+	ErrMissingTile = errors.New("missing tile")
+	// ErrMissingSegment is returned when a segment of a packed tile set required by a tile is not drawn.
+	ErrMissingSegment = errors.New("missing segment")
+	// ErrInvalidTerrains is returned when terrains of a multi-terrain tile set are not distinct names
+	// or terrains of a packed tile set don't follow their order.
+	ErrInvalidTerrains = errors.New("invalid terrains")
+	// ErrUnknownTerrain is returned when a cell of a dual grid is neither Terrain1 nor Terrain2.
+	ErrUnknownTerrain = errors.New("unknown terrain")
+	// ErrInvalidWeight is returned when a weight of a random variant is not a positive number.
+	ErrInvalidWeight = errors.New("invalid weight")
+	// ErrMissingPack is returned when a multi-terrain tile set lacks the packed tile set of a pair of terrains.
+	ErrMissingPack = errors.New("missing packed tile set")
+	// ErrApproximateTiles is returned when a multi-terrain tile set has tiles where three terrains meet in a segment,
+	// which packed tile sets of pairs of terrains can only approximate, and approximation isn't allowed.
+	ErrApproximateTiles = errors.New("tiles can only be approximated")
+	// ErrUnknownVariantMode is returned when a variant mode name is not known.
+	ErrUnknownVariantMode = errors.New("unknown variant mode")
+	// ErrUnknownOrientation is returned when a hex tile orientation is not known.
+	ErrUnknownOrientation = errors.New("unknown orientation")
+	// ErrDecode is returned when an image can't be decoded.
+	ErrDecode = errors.New("failed to decode image")
+)
+
+// TileError describes an error related to a single tile of a tile set.
+type TileError struct {
+	// Layout is the name of the layout of the tile set.
+	Layout string
+	// Tile is the position of the tile in the tile set.
+	Tile image.Point
+	// Err is the cause of the error.
+	Err error
+}
+
+// Error implements error.
+func (e *TileError) Error() string {
+	return fmt.Sprintf("%s layout, tile %d,%d: %v", e.Layout, e.Tile.X, e.Tile.Y, e.Err)
+}
+
+// Unwrap returns the cause of the error.
+func (e *TileError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,118 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"testing"
+)
+
+func TestInitErrors(t *testing.T) {
+	tests := []struct {
+		name              string
+		width, height     int
+		xTiles, yTiles    int
+		padding, segments int
+		want              error
+		wantInMessage     string
+	}{
+		{"no tiles", 128, 192, 0, 3, 0, 2, ErrInvalidTileCount, "0x3 tiles"},
+		{"no segments", 128, 192, 2, 3, 0, 0, ErrInvalidTileCount, "0 segments"},
+		{"too small", 2, 3, 2, 3, 0, 2, ErrImageTooSmall, "2x3 px"},
+		{"not divisible by tiles", 129, 192, 2, 3, 0, 2, ErrTileSizeNotDivisible, "129x192 px"},
+		{"not divisible by segments", 126, 189, 2, 3, 0, 2, ErrTileSizeNotDivisible, "63x63 px tile"},
+		{"negative padding", 128, 192, 2, 3, -1, 2, ErrInvalidPadding, "-1 px"},
+		{"padding too large", 128, 192, 2, 3, 65, 2, ErrInvalidPadding, "65 px"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := image.NewNRGBA(image.Rect(0, 0, tt.width, tt.height))
+			err := NewUnpacker(src, tt.xTiles, tt.yTiles, tt.padding).Init(tt.segments)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			if !bytes.Contains([]byte(err.Error()), []byte(tt.wantInMessage)) {
+				t.Errorf("error %q does not mention %q", err, tt.wantInMessage)
+			}
+		})
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 128, 192))
+	u := NewUnpacker(src, 2, 3, 0)
+	if _, err := u.From6to28(); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("not initialized: got %v", err)
+	}
+	u = NewUnpacker(src, 4, 3, 0)
+	if err := u.Init(2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.From6to48Terrain1(); !errors.Is(err, ErrUnsupportedPackType) {
+		t.Errorf("pack type: got %v", err)
+	}
+	u = NewUnpacker(nil, 2, 3, 0)
+	if err := u.Init(2); !errors.Is(err, ErrImageTooSmall) {
+		t.Errorf("no image: got %v", err)
+	}
+}
+
+func TestLayoutErrors(t *testing.T) {
+	if _, err := NewBlobLayout("3x3"); !errors.Is(err, ErrUnknownLayout) {
+		t.Errorf("blob layout: got %v", err)
+	}
+	if _, err := Pack(image.NewNRGBA(image.Rect(0, 0, 64, 64)), "3x3", 0); !errors.Is(err, ErrUnknownLayout) {
+		t.Errorf("pack: got %v", err)
+	}
+	if _, err := Pack(image.NewNRGBA(image.Rect(0, 0, 770, 256)), Layout48Terrain1, 0); !errors.Is(err, ErrTileSizeNotDivisible) {
+		t.Errorf("pack: got %v", err)
+	}
+
+	to, err := NewBlobLayout(BlobLayout7x7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := &BlobLayout{Name: "2x1", Cols: 2, Rows: 1, masks: []int{0, 255}}
+	if _, err := ConvertLayout(image.NewNRGBA(image.Rect(0, 0, 20, 10)), from, to, 0); !errors.Is(err, ErrMissingTile) {
+		t.Errorf("convert: got %v", err)
+	} else {
+		var tileErr *TileError
+		if !errors.As(err, &tileErr) || tileErr.Layout != BlobLayout7x7 || tileErr.Tile != (image.Point{X: 1}) {
+			t.Errorf("convert: got %#v", err)
+		}
+	}
+	if _, err := ConvertLayout(image.NewNRGBA(image.Rect(0, 0, 20, 10)), from, to, 5); !errors.Is(err, ErrImageTooSmall) {
+		t.Errorf("convert padding: got %v", err)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	_, err := Decode(bytes.NewReader([]byte("not an image")))
+	if !errors.Is(err, ErrDecode) || !errors.Is(err, image.ErrFormat) {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"testing"
+)
+
+// maxFuzzPixels limits the size of images created by fuzz tests.
+const maxFuzzPixels = 1 << 16
+
+// unpackAll runs every 2x3 export and checks results of the successful ones.
+func unpackAll(t *testing.T, u *Unpacker) {
+	t.Helper()
+	exports := goldenExports(u)
+	for name, export := range exports {
+		img, err := export()
+		if err != nil {
+			if !errors.Is(err, ErrNotInitialized) && !errors.Is(err, ErrUnsupportedPackType) &&
+				!errors.Is(err, ErrInvalidTileSize) && !errors.Is(err, ErrMissingSegment) {
+				t.Errorf("%s: unexpected error %v", name, err)
+			}
+			continue
+		}
+		if img == nil || img.Bounds().Empty() {
+			t.Errorf("%s: empty image without error", name)
+		}
+	}
+}
+
+// isGeometryError reports whether err is caused by a tile set size or padding not matching the layout.
+func isGeometryError(err error) bool {
+	return errors.Is(err, ErrInvalidTileSize) || errors.Is(err, ErrInvalidPadding) ||
+		errors.Is(err, ErrImageTooSmall) || errors.Is(err, ErrTileSizeNotDivisible)
+}
+
+func FuzzUnpacker(f *testing.F) {
+	f.Add(128, 192, 2, 3, 0, 2)
+	f.Add(128, 192, 2, 3, 1, 2)
+	f.Add(0, 0, 2, 3, 0, 2)
+	f.Add(3, 5, 2, 3, 0, 2)
+	f.Add(64, 64, 0, 0, 0, 0)
+	f.Add(120, 192, 3, 2, 0, 2)
+	f.Add(128, 192, 2, 3, -1, 2)
+	f.Add(128, 192, 2, 3, 1000, 2)
+	f.Add(128, 190, 2, 3, 0, 1)
+	f.Fuzz(func(t *testing.T, width, height, xTiles, yTiles, padding, segments int) {
+		if width < 0 || height < 0 || width*height > maxFuzzPixels || width > maxFuzzPixels || height > maxFuzzPixels {
+			t.Skip()
+		}
+		src := image.NewNRGBA(image.Rect(0, 0, width, height))
+		u := NewUnpacker(src, xTiles, yTiles, padding)
+		if err := u.Init(segments); err != nil {
+			if !isGeometryError(err) && !errors.Is(err, ErrInvalidTileCount) {
+				t.Errorf("init: unexpected error %v", err)
+			}
+			unpackAll(t, u) // must fail gracefully as well
+			return
+		}
+		unpackAll(t, u)
+	})
+}
+
+func FuzzDecodeAndUnpack(f *testing.F) {
+	// the example source is too large for the fuzzing engine to mutate efficiently,
+	// so a small 2x3 tile set made of 4px tiles is used instead
+	small := image.NewNRGBA(image.Rect(0, 0, 8, 12))
+	for i := range small.Pix {
+		small.Pix[i] = uint8(i * 7)
+	}
+	for _, img := range []image.Image{small, image.NewNRGBA(image.Rect(0, 0, 1, 1))} {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buf.Bytes(), 0)
+		f.Add(buf.Bytes(), 1)
+	}
+	f.Add([]byte("not an image"), 0)
+	f.Fuzz(func(t *testing.T, data []byte, padding int) {
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil || cfg.Width > maxFuzzPixels || cfg.Height > maxFuzzPixels || cfg.Width*cfg.Height > maxFuzzPixels {
+			t.Skip()
+		}
+		src, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		u := NewUnpacker(src, sixPackXTiles, sixPackYTiles, padding)
+		if err := u.Init(sixPackSegments); err != nil {
+			return
+		}
+		unpackAll(t, u)
+	})
+}
+
+func FuzzTileSetGeometry(f *testing.F) {
+	f.Add(768, 256, 0, uint8(0))
+	f.Add(816, 272, 1, uint8(1))
+	f.Add(1, 1, 0, uint8(2))
+	f.Add(24, 4, -3, uint8(3))
+	f.Add(0, 0, 0, uint8(4))
+	layouts := []string{
+		Layout16Terrain1, Layout16Terrain2, Layout4x4Terrain1, Layout4x4Terrain2,
+		Layout28, Layout48Terrain1, Layout48Terrain2,
+	}
+	blobLayouts := []string{BlobLayout12x4, BlobLayout7x7, BlobLayout24x11, BlobLayout16x16}
+	f.Fuzz(func(t *testing.T, width, height, padding int, layout uint8) {
+		if width < 0 || height < 0 || width*height > maxFuzzPixels || width > maxFuzzPixels || height > maxFuzzPixels {
+			t.Skip()
+		}
+		src := image.NewNRGBA(image.Rect(0, 0, width, height))
+		if _, err := Pack(src, layouts[int(layout)%len(layouts)], padding); err != nil &&
+			!isGeometryError(err) {
+			t.Errorf("pack: unexpected error %v", err)
+		}
+		from, err := NewBlobLayout(blobLayouts[int(layout)%len(blobLayouts)])
+		if err != nil {
+			t.Fatal(err)
+		}
+		to, err := NewBlobLayout(blobLayouts[int(layout/4)%len(blobLayouts)])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ConvertLayout(src, from, to, padding); err != nil &&
+			!isGeometryError(err) {
+			t.Errorf("convert: unexpected error %v", err)
+		}
+		opts := DefaultSeamOptions()
+		opts.Padding = padding
+		if _, err := ValidateSeams(src, from, opts); err != nil &&
+			!isGeometryError(err) {
+			t.Errorf("validate: unexpected error %v", err)
+		}
+	})
+}
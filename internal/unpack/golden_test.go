@@ -0,0 +1,151 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "regenerate golden images in testdata/golden")
+
+const (
+	goldenSource = "../../examples/2x3_packed.png"
+	goldenDir    = "testdata/golden"
+	goldenDiff   = "testdata/golden/diff"
+)
+
+func loadImage(t testing.TB, path string) image.Image {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func saveImage(t testing.TB, path string, img image.Image) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// goldenExports returns all From6to* methods of the unpacker and 12x4 exports with mirrored
+// and unturned variants by the name of the golden image.
+func goldenExports(u *Unpacker) map[string]func() (*image.NRGBA, error) {
+	variants := func(layout string, quadMap [16]quadTileData, mode VariantMode) func() (*image.NRGBA, error) {
+		return func() (*image.NRGBA, error) {
+			return u.from6to48Terrain(context.Background(), layout, quadMap, mode, nil)
+		}
+	}
+	return map[string]func() (*image.NRGBA, error){
+		Layout16Terrain1:             u.From6to16Terrain1,
+		Layout16Terrain2:             u.From6to16Terrain2,
+		Layout28:                     u.From6to28,
+		Layout48Terrain1:             u.From6to48Terrain1,
+		Layout48Terrain2:             u.From6to48Terrain2,
+		LayoutDualGrid16:             u.From6to16DualGrid,
+		Layout48Terrain1 + "_flip":   variants(Layout48Terrain1, export6to48Terrain1TileSet(), VariantsFlip),
+		Layout48Terrain2 + "_flip":   variants(Layout48Terrain2, export6to48Terrain2TileSet(), VariantsFlip),
+		Layout48Terrain1 + "_source": variants(Layout48Terrain1, export6to48Terrain1TileSet(), VariantsSource),
+		Layout48Terrain2 + "_source": variants(Layout48Terrain2, export6to48Terrain2TileSet(), VariantsSource),
+	}
+}
+
+// diffImage returns a dimmed copy of want with pixels differing from got highlighted
+// and the number of such pixels.
+func diffImage(want, got *image.NRGBA) (*image.NRGBA, int) {
+	diff := image.NewNRGBA(want.Bounds().Union(got.Bounds()))
+	count := 0
+	for y := diff.Rect.Min.Y; y < diff.Rect.Max.Y; y++ {
+		for x := diff.Rect.Min.X; x < diff.Rect.Max.X; x++ {
+			p := image.Point{X: x, Y: y}
+			w, g := want.NRGBAAt(x, y), got.NRGBAAt(x, y)
+			if w != g || !p.In(want.Rect) || !p.In(got.Rect) {
+				diff.SetNRGBA(x, y, seamHighlight())
+				count++
+				continue
+			}
+			gray := uint8((uint16(w.R) + uint16(w.G) + uint16(w.B)) / 3)
+			diff.SetNRGBA(x, y, color.NRGBA{R: gray, G: gray, B: gray, A: w.A / 4})
+		}
+	}
+	return diff, count
+}
+
+func TestGolden(t *testing.T) {
+	src := loadImage(t, goldenSource)
+	for _, padding := range []int{0, 1} {
+		u := NewUnpacker(src, 2, 3, padding)
+		if err := u.Init(2); err != nil {
+			t.Fatal(err)
+		}
+		for name, export := range goldenExports(u) {
+			name := fmt.Sprintf("%s_p%d", name, padding)
+			export := export
+			t.Run(name, func(t *testing.T) {
+				got, err := export()
+				if err != nil {
+					t.Fatal(err)
+				}
+				goldenPath := filepath.Join(goldenDir, name+".png")
+				if *update {
+					saveImage(t, goldenPath, got)
+					return
+				}
+				want := toNRGBA(loadImage(t, goldenPath))
+				diff, count := diffImage(want, got)
+				if count == 0 {
+					return
+				}
+				diffPath := filepath.Join(goldenDiff, name+"_diff.png")
+				saveImage(t, diffPath, diff)
+				t.Errorf("%d pixels differ from %s (want %v, got %v), see %s",
+					count, goldenPath, want.Bounds(), got.Bounds(), diffPath)
+			})
+		}
+	}
+}
@@ -0,0 +1,191 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"context"
+	"fmt"
+	"image"
+)
+
+// SourceHexStrip is a strip of two hex tiles of terrain 1: a filled one and an island with transitions
+// to terrain 2 at all six sides.
+const SourceHexStrip = "2x1_hex"
+
+// LayoutHex64 is the 8x8 tile set generated by FromHexStrip. The index of a tile row by row is its HexEdges,
+// so the tile 0 is the filled one and the tile 63 the island.
+const LayoutHex64 = "8x8_hex"
+
+// HexOrientation is the orientation of hex tiles.
+type HexOrientation string
+
+// Orientations of hex tiles.
+const (
+	// HexPointy tiles have a vertex at the top, rows of a map are staggered.
+	HexPointy HexOrientation = "pointy"
+	// HexFlat tiles have a side at the top, columns of a map are staggered.
+	HexFlat HexOrientation = "flat"
+)
+
+// HexEdges is a set of sides of a hex tile of terrain 1 with a neighbour of terrain 2.
+// Bits are sides in the order of HexSides, clockwise from the top.
+type HexEdges uint8
+
+const (
+	// hexSides is the number of sides of a hex tile.
+	hexSides = 6
+	// hexCols is the number of columns and rows of LayoutHex64.
+	hexCols = 8
+	// hexTiles is the number of tiles of LayoutHex64, a tile for every HexEdges.
+	hexTiles = 1 << hexSides
+	// hexStripPieces is the number of tiles of SourceHexStrip.
+	hexStripPieces = 2
+)
+
+// hexSideNames lists names of sides of pointy and flat hex tiles in the order of HexEdges bits.
+//
+//nolint:gochecknoglobals //static table
+var hexSideNames = map[HexOrientation][hexSides]string{
+	HexPointy: {"NE", "E", "SE", "SW", "W", "NW"},
+	HexFlat:   {"N", "NE", "SE", "S", "SW", "NW"},
+}
+
+// HexSides returns names of sides of hex tiles of the orientation in the order of HexEdges bits.
+//
+// Parameters:
+// - orientation: HexPointy or HexFlat.
+//
+// Returns:
+// - Names of the sides, e.g. NE for the north-east one.
+// - An error wrapping ErrUnknownOrientation if the orientation is not known.
+func HexSides(orientation HexOrientation) ([hexSides]string, error) {
+	names, ok := hexSideNames[orientation]
+	if !ok {
+		return names, fmt.Errorf("%w: %s", ErrUnknownOrientation, orientation)
+	}
+	return names, nil
+}
+
+// hexVertices returns vertices of the hex inscribed into a w by h px tile clockwise, so the i-th side
+// runs from the i-th vertex to the next one. Pointy hexes touch the middles of the top and the bottom side
+// of the tile and have vertical sides, flat ones touch the middles of the left and the right side.
+func hexVertices(w, h float64, orientation HexOrientation) [hexSides][2]float64 {
+	if orientation == HexFlat {
+		return [hexSides][2]float64{{w / 4, 0}, {w * 3 / 4, 0}, {w, h / 2}, {w * 3 / 4, h}, {w / 4, h}, {0, h / 2}}
+	}
+	return [hexSides][2]float64{{w / 2, 0}, {w, h / 4}, {w, h * 3 / 4}, {w / 2, h}, {0, h * 3 / 4}, {0, h / 4}}
+}
+
+// cross returns the z component of the cross product of vectors from o to a and from o to b.
+// It is positive if b is clockwise from a on screen, where y grows downwards.
+func cross(o, a, b [2]float64) float64 {
+	return (a[0]-o[0])*(b[1]-o[1]) - (a[1]-o[1])*(b[0]-o[0])
+}
+
+// hexSector returns the side of the hex of a w by h px tile whose triangle spanned with the center
+// holds the pixel or -1 if the pixel is outside the hex. Triangles are half-open, so every pixel inside
+// belongs to a single one.
+func hexSector(px, py, w, h int, orientation HexOrientation) int {
+	vertices := hexVertices(float64(w), float64(h), orientation)
+	center := [2]float64{float64(w) / 2, float64(h) / 2}
+	p := [2]float64{float64(px) + 0.5, float64(py) + 0.5}
+	for side, from := range vertices {
+		to := vertices[(side+1)%hexSides]
+		if cross(center, from, p) >= 0 && cross(center, p, to) > 0 {
+			if cross(from, to, p) > 0 {
+				return side
+			}
+			return -1
+		}
+	}
+	return -1
+}
+
+// FromHexStrip generates the LayoutHex64 tile set from a SourceHexStrip strip. The hex of every tile is split
+// into six triangles from its center to its sides: the triangle of a side with a neighbour of terrain 2
+// is copied from the island, the others from the filled tile, so pieces are never turned. Pixels outside
+// the hex are transparent.
+//
+// Parameters:
+// - ctx: The context which cancels generation between tiles.
+// - src: The strip of two hex tiles of the same size.
+// - orientation: HexPointy or HexFlat.
+// - padding: The padding of every generated tile in px.
+// - progress: The function receiving the number of generated tiles, may be nil.
+//
+// Returns:
+// - A pointer to the generated image.
+// - An error if the orientation is unknown, the image isn't a strip of two tiles or the padding is negative.
+func FromHexStrip(
+	ctx context.Context, src image.Image, orientation HexOrientation, padding int, progress ProgressFunc,
+) (*image.NRGBA, error) {
+	if _, err := HexSides(orientation); err != nil {
+		return nil, err
+	}
+	if src == nil {
+		return nil, fmt.Errorf("%s: %w: no image", SourceHexStrip, ErrImageTooSmall)
+	}
+	if padding < 0 {
+		return nil, fmt.Errorf("%s: %w: %d px", LayoutHex64, ErrInvalidPadding, padding)
+	}
+	img := asNRGBA(src)
+	size := img.Rect.Size()
+	w, h := size.X/hexStripPieces, size.Y
+	if w == 0 || h == 0 || size.X%hexStripPieces != 0 {
+		return nil, fmt.Errorf("%s: %w: %dx%d px isn't a strip of %d tiles",
+			SourceHexStrip, ErrInvalidTileSize, size.X, size.Y, hexStripPieces)
+	}
+	sectors := make([]int, w*h)
+	for py := 0; py < h; py++ {
+		for px := 0; px < w; px++ {
+			sectors[py*w+px] = hexSector(px, py, w, h, orientation)
+		}
+	}
+	paddedW, paddedH := w+padding*2, h+padding*2
+	canvas := image.NewNRGBA(image.Rect(0, 0, paddedW*hexCols, paddedH*hexCols))
+	for i := 0; i < hexTiles; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("%s: %w", LayoutHex64, err)
+		}
+		dstMin := image.Point{X: i%hexCols*paddedW + padding, Y: i/hexCols*paddedH + padding}
+		for py := 0; py < h; py++ {
+			for px := 0; px < w; px++ {
+				side := sectors[py*w+px]
+				if side < 0 {
+					continue
+				}
+				piece := 0
+				if HexEdges(i)&(1<<side) != 0 {
+					piece = 1
+				}
+				s := img.PixOffset(img.Rect.Min.X+piece*w+px, img.Rect.Min.Y+py)
+				d := canvas.PixOffset(dstMin.X+px, dstMin.Y+py)
+				copy(canvas.Pix[d:d+4], img.Pix[s:s+4])
+			}
+		}
+		progress.report(i+1, hexTiles)
+	}
+	return canvas, nil
+}
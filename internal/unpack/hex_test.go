@@ -0,0 +1,140 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// hexStrip returns a strip of a filled green and an island red w by h px tile.
+func hexStrip(w, h int) *image.NRGBA {
+	strip := image.NewNRGBA(image.Rect(0, 0, 2*w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			strip.SetNRGBA(x, y, color.NRGBA{G: 255, A: 255})
+			strip.SetNRGBA(w+x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+	return strip
+}
+
+func TestHexSector(t *testing.T) {
+	const w, h = 28, 32
+	tests := []struct {
+		orientation HexOrientation
+		x, y        int
+		want        int
+	}{
+		{HexPointy, 20, 6, 0},
+		{HexPointy, 26, 16, 1},
+		{HexPointy, 20, 26, 2},
+		{HexPointy, 8, 26, 3},
+		{HexPointy, 1, 16, 4},
+		{HexPointy, 8, 6, 5},
+		{HexPointy, 0, 0, -1},
+		{HexPointy, 27, 31, -1},
+		{HexFlat, 14, 1, 0},
+		{HexFlat, 24, 10, 1},
+		{HexFlat, 24, 22, 2},
+		{HexFlat, 14, 30, 3},
+		{HexFlat, 3, 22, 4},
+		{HexFlat, 3, 10, 5},
+		{HexFlat, 0, 0, -1},
+		{HexFlat, 27, 31, -1},
+	}
+	for _, tt := range tests {
+		if got := hexSector(tt.x, tt.y, w, h, tt.orientation); got != tt.want {
+			t.Errorf("%s %d,%d: got %d, want %d", tt.orientation, tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestFromHexStrip(t *testing.T) {
+	const w, h, padding = 28, 32, 1
+	const paddedW, paddedH = w + 2*padding, h + 2*padding
+	for _, orientation := range []HexOrientation{HexPointy, HexFlat} {
+		t.Run(string(orientation), func(t *testing.T) {
+			var reported int
+			img, err := FromHexStrip(context.Background(), hexStrip(w, h), orientation, padding,
+				func(done, total int) { reported = done })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if img.Rect != image.Rect(0, 0, hexCols*paddedW, hexCols*paddedH) || reported != hexTiles {
+				t.Fatalf("got %v after %d tiles", img.Rect, reported)
+			}
+			for i := 0; i < hexTiles; i++ {
+				tileMin := image.Point{X: i%hexCols*paddedW + padding, Y: i/hexCols*paddedH + padding}
+				for y := 0; y < h; y++ {
+					for x := 0; x < w; x++ {
+						want := color.NRGBA{}
+						if side := hexSector(x, y, w, h, orientation); side >= 0 {
+							want = color.NRGBA{G: 255, A: 255}
+							if HexEdges(i)&(1<<side) != 0 {
+								want = color.NRGBA{R: 255, A: 255}
+							}
+						}
+						if got := img.NRGBAAt(tileMin.X+x, tileMin.Y+y); got != want {
+							t.Fatalf("tile %06b pixel %d,%d: got %v, want %v", i, x, y, got, want)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFromHexStripErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		src         image.Image
+		orientation HexOrientation
+		padding     int
+		want        error
+	}{
+		{"no image", nil, HexPointy, 0, ErrImageTooSmall},
+		{"empty", image.NewNRGBA(image.Rect(0, 0, 0, 0)), HexPointy, 0, ErrInvalidTileSize},
+		{"odd width", image.NewNRGBA(image.Rect(0, 0, 57, 32)), HexFlat, 0, ErrInvalidTileSize},
+		{"negative padding", hexStrip(28, 32), HexPointy, -1, ErrInvalidPadding},
+		{"unknown orientation", hexStrip(28, 32), "round", 0, ErrUnknownOrientation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := FromHexStrip(context.Background(), tt.src, tt.orientation, tt.padding, nil)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := FromHexStrip(ctx, hexStrip(28, 32), HexPointy, 0, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled: got %v", err)
+	}
+}
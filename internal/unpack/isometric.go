@@ -0,0 +1,143 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"fmt"
+	"image"
+)
+
+// isoQuadrantDivisor is the number tile sides have to be divisible by in isometric mode,
+// so quadrants of a diamond are shifted by whole pixels.
+const isoQuadrantDivisor = 4
+
+// SetIsometric switches the unpacker to isometric tiles: every tile of the packed tile set holds a diamond
+// touching the middles of the tile sides. Segments are quadrants of the diamond instead of quarters
+// of the tile - the top, right, left and bottom quadrant take the place of the top-left, top-right,
+// bottom-left and bottom-right quarter, as the diamond is a square turned by 45 degrees and squashed.
+// Pixels outside the diamond are left transparent.
+//
+// Rotating a diamond on screen doesn't rotate it on the map, so 12x4 tile sets are always generated
+// like with VariantsSource. Tile sides have to be divisible by 4.
+func (u *Unpacker) SetIsometric(isometric bool) {
+	u.isometric = isometric
+	for _, variant := range u.variants {
+		variant.SetIsometric(isometric)
+	}
+}
+
+// Isometric reports whether the unpacker is in isometric mode, see SetIsometric.
+func (u *Unpacker) Isometric() bool {
+	return u.isometric
+}
+
+// checkIsometric returns an error if quadrants of isometric tiles can't be shifted by whole pixels.
+func (u *Unpacker) checkIsometric() error {
+	if u.isometric && (u.tileWidth%isoQuadrantDivisor != 0 || u.tileHeight%isoQuadrantDivisor != 0) {
+		return fmt.Errorf("%w: isometric %dx%d px tiles, sides have to be divisible by %d",
+			ErrTileSizeNotDivisible, u.tileWidth, u.tileHeight, isoQuadrantDivisor)
+	}
+	return nil
+}
+
+// isoQuadrant returns the quadrant of the diamond of a w by h px tile the pixel belongs to
+// in the order of quadTileData segments (top, right, left, bottom) or -1 if the pixel is outside the diamond.
+// The pixel center is turned back into the square the diamond is made of: u runs from the top corner
+// to the right one and v from the top corner to the left one, both scaled by 2*w*h and centered on the tile.
+// Quadrants are half-open, so shifting one by a quarter of the tile gives exactly the pixels of its neighbour.
+func isoQuadrant(px, py, w, h int) int {
+	x := (2*px+1)*h - w*h
+	y := (2*py+1)*w - w*h
+	u, v := x+y, y-x
+	if u < -w*h || u >= w*h || v < -w*h || v >= w*h {
+		return -1
+	}
+	res := 0
+	if u >= 0 {
+		res |= 1
+	}
+	if v >= 0 {
+		res |= 2
+	}
+	return res
+}
+
+// isoQuadrantCenter returns the center of the quadrant of the diamond of a w by h px tile.
+func isoQuadrantCenter(quadrant, w, h int) image.Point {
+	switch quadrant {
+	case 0:
+		return image.Point{X: w / 2, Y: h / 4}
+	case 1:
+		return image.Point{X: w * 3 / 4, Y: h / 2}
+	case 2:
+		return image.Point{X: w / 4, Y: h / 2}
+	}
+	return image.Point{X: w / 2, Y: h * 3 / 4}
+}
+
+// isoSegmentSource returns the top left corner of the source tile holding the segment
+// at the given quadTileData coordinates and its quadrant.
+func (u *Unpacker) isoSegmentSource(xy [2]int) (image.Point, int) {
+	return u.anchors[xy[0]&^1][xy[1]&^1], xy[0]%2 + xy[1]%2*2
+}
+
+// drawIsoSegment draws the quadrant of the diamond at the given quadTileData coordinates
+// as the i-th quadrant of the idx-th tile of the canvas.
+func (u *Unpacker) drawIsoSegment(canvas *image.NRGBA, i int, xy [2]int, idx, outXTiles int) {
+	w, h := u.tileWidth, u.tileHeight
+	dstMin := image.Point{
+		X: idx%outXTiles*u.paddedTileWidth() + u.padding,
+		Y: idx/outXTiles*u.paddedTileHeight() + u.padding,
+	}
+	srcMin, quadrant := u.isoSegmentSource(xy)
+	srcMin = srcMin.Add(isoQuadrantCenter(quadrant, w, h)).Sub(isoQuadrantCenter(i, w, h))
+	center := isoQuadrantCenter(i, w, h)
+	for py := center.Y - h/4; py < center.Y+h/4; py++ {
+		for px := center.X - w/4; px < center.X+w/4; px++ {
+			if isoQuadrant(px, py, w, h) != i {
+				continue
+			}
+			src := u.src.PixOffset(srcMin.X+px, srcMin.Y+py)
+			dst := canvas.PixOffset(dstMin.X+px, dstMin.Y+py)
+			copy(canvas.Pix[dst:dst+4], u.src.Pix[src:src+4])
+		}
+	}
+}
+
+// isoSegmentDrawn reports whether the quadrant of the diamond at the given quadTileData coordinates
+// has any pixel which is not fully transparent.
+func (u *Unpacker) isoSegmentDrawn(xy [2]int) bool {
+	w, h := u.tileWidth, u.tileHeight
+	srcMin, quadrant := u.isoSegmentSource(xy)
+	center := isoQuadrantCenter(quadrant, w, h)
+	for py := center.Y - h/4; py < center.Y+h/4; py++ {
+		for px := center.X - w/4; px < center.X+w/4; px++ {
+			if isoQuadrant(px, py, w, h) == quadrant && u.src.Pix[u.src.PixOffset(srcMin.X+px, srcMin.Y+py)+3] != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
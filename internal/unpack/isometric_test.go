@@ -0,0 +1,130 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"context"
+	"errors"
+	"image"
+	"testing"
+)
+
+// isoProject turns every square tile of the tile set into a diamond of a tile twice as wide,
+// sampling the square with nearest neighbour. Tiles are padded like tiles of generated tile sets.
+func isoProject(src *image.NRGBA, size, padding, cols, rows int) *image.NRGBA {
+	w, h := 2*size, size
+	dst := image.NewNRGBA(image.Rect(0, 0, cols*(w+2*padding), rows*(h+2*padding)))
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			srcMin := image.Point{X: col*(size+2*padding) + padding, Y: row*(size+2*padding) + padding}
+			dstMin := image.Point{X: col*(w+2*padding) + padding, Y: row*(h+2*padding) + padding}
+			for py := 0; py < h; py++ {
+				for px := 0; px < w; px++ {
+					if isoQuadrant(px, py, w, h) < 0 {
+						continue
+					}
+					x := (2*px+1)*h - w*h
+					y := (2*py+1)*w - w*h
+					sx, sy := (x+y+w*h)*size/(2*w*h), (y-x+w*h)*size/(2*w*h)
+					dst.SetNRGBA(dstMin.X+px, dstMin.Y+py, src.NRGBAAt(srcMin.X+sx, srcMin.Y+sy))
+				}
+			}
+		}
+	}
+	return dst
+}
+
+func TestIsoQuadrant(t *testing.T) {
+	const w, h = 8, 4
+	want := [h]string{
+		"...00...",
+		".220011.",
+		".223311.",
+		"...33...",
+	}
+	for py := 0; py < h; py++ {
+		for px := 0; px < w; px++ {
+			got := byte('.')
+			if q := isoQuadrant(px, py, w, h); q >= 0 {
+				got = byte('0' + q)
+			}
+			if got != want[py][px] {
+				t.Errorf("pixel %d,%d: got %c, want %c", px, py, got, want[py][px])
+			}
+		}
+	}
+}
+
+func TestIsometricMatchesProjection(t *testing.T) {
+	src := toNRGBA(loadImage(t, goldenSource))
+	const size = 64
+	isoSrc := isoProject(src, size, 0, 2, 3)
+	for _, padding := range []int{0, 1} {
+		square := NewUnpacker(src, 2, 3, padding)
+		iso := NewUnpacker(isoSrc, 2, 3, padding)
+		iso.SetIsometric(true)
+		for _, u := range []*Unpacker{square, iso} {
+			if err := u.Init(2); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, layout := range ExportLayouts() {
+			// the square tile set has to be assembled the same way
+			if err := square.SetVariantMode(layout, VariantsSource); err != nil {
+				t.Fatal(err)
+			}
+			want, err := square.ExportContext(context.Background(), layout, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := iso.ExportContext(context.Background(), layout, nil)
+			if err != nil {
+				t.Fatalf("%s: %v", layout, err)
+			}
+			cols, rows := want.Rect.Dx()/(size+2*padding), want.Rect.Dy()/(size+2*padding)
+			projected := isoProject(want, size, padding, cols, rows)
+			if got.Rect != projected.Rect {
+				t.Fatalf("%s p%d: got %v, want %v", layout, padding, got.Rect, projected.Rect)
+			}
+			if _, count := diffImage(projected, got); count != 0 {
+				t.Errorf("%s p%d: %d pixels differ from the projection of square tiles", layout, padding, count)
+			}
+		}
+	}
+}
+
+func TestIsometricErrors(t *testing.T) {
+	u := NewUnpacker(image.NewNRGBA(image.Rect(0, 0, 2*30, 3*30)), 2, 3, 0)
+	u.SetIsometric(true)
+	if err := u.Init(2); err != nil {
+		t.Fatal(err)
+	}
+	if !u.Isometric() {
+		t.Error("not isometric")
+	}
+	if _, err := u.ExportContext(context.Background(), Layout28, nil); !errors.Is(err, ErrTileSizeNotDivisible) {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,225 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"fmt"
+	"image"
+)
+
+// Mask is a bitmask of 8 neighbours of a blob tile. A bit is set if the neighbour has the same terrain.
+// Bits follow cr31 convention: clockwise starting from north.
+type Mask uint8
+
+const (
+	North Mask = 1 << iota
+	NorthEast
+	East
+	SouthEast
+	South
+	SouthWest
+	West
+	NorthWest
+)
+
+// Names of the known blob layouts.
+const (
+	// BlobLayout12x4 is the layout of From6to48Terrain* tile sets (Godot 3 3x3 minimal).
+	BlobLayout12x4 = "12x4"
+	// BlobLayout7x7 is the 7x7 blob tile set of cr31 with empty top right and bottom left cells.
+	// Tiles are arranged so that all touching edges match.
+	BlobLayout7x7 = "7x7"
+	// BlobLayout24x11 holds all 256 masks, see references/24x11_bitmask_reference_3x3_full.png.
+	BlobLayout24x11 = "24x11"
+	// BlobLayout16x16 holds all 256 masks, see references/16x16_bitmask_reference_3x3_full.png.
+	BlobLayout16x16 = "16x16"
+)
+
+// Canonical returns the mask with corner bits dropped if they don't affect the tile.
+// Corner neighbour matters only if both neighbours adjacent to it are set.
+// There are 47 canonical masks.
+func (m Mask) Canonical() Mask {
+	if m&North == 0 || m&East == 0 {
+		m &^= NorthEast
+	}
+	if m&East == 0 || m&South == 0 {
+		m &^= SouthEast
+	}
+	if m&South == 0 || m&West == 0 {
+		m &^= SouthWest
+	}
+	if m&West == 0 || m&North == 0 {
+		m &^= NorthWest
+	}
+	return m
+}
+
+// rotatedLeft returns the mask of the tile rotated 90 degrees to the left, e.g. the north neighbour becomes the west one.
+func (m Mask) rotatedLeft() Mask {
+	return m>>2 | m<<6
+}
+
+// mirrored returns the mask of the mirrored tile.
+func (m Mask) mirrored(f flip) Mask {
+	var res Mask
+	for i := 0; i < 8; i++ {
+		if m&(1<<i) == 0 {
+			continue
+		}
+		bit := i
+		if f&flipH != 0 {
+			// east and west neighbours swap, north and south stay
+			bit = (8 - bit) % 8
+		}
+		if f&flipV != 0 {
+			// north and south neighbours swap, east and west stay
+			bit = (12 - bit) % 8
+		}
+		res |= 1 << bit
+	}
+	return res
+}
+
+// canonicalMasks returns all 47 canonical masks in ascending order.
+func canonicalMasks() []Mask {
+	res := make([]Mask, 0, 47)
+	for m := 0; m < 256; m++ {
+		if Mask(m).Canonical() == Mask(m) {
+			res = append(res, Mask(m))
+		}
+	}
+	return res
+}
+
+// remapMask converts a mask from a convention where i-th bit means neighbour bits[i] to cr31 convention.
+func remapMask(value int, bits [8]Mask) Mask {
+	var m Mask
+	for i, bit := range bits {
+		if value&(1<<i) != 0 {
+			m |= bit
+		}
+	}
+	return m
+}
+
+const (
+	// emptyCell marks a cell of a layout without a tile.
+	emptyCell = -1
+	// backgroundCell marks a cell of a layout filled with the terrain surrounding blobs.
+	backgroundCell = -2
+)
+
+// BlobLayout describes an arrangement of blob tiles on a tile set.
+type BlobLayout struct {
+	Name       string
+	Cols, Rows int
+	// masks holds the mask of every cell row by row or one of emptyCell and backgroundCell.
+	masks []int
+}
+
+// NewBlobLayout returns one of the known blob layouts by its name.
+func NewBlobLayout(name string) (*BlobLayout, error) {
+	switch name {
+	case BlobLayout12x4:
+		return &BlobLayout{Name: name, Cols: 12, Rows: 4, masks: []int{
+			16, 20, 84, 80, 213, 92, 116, 87, 28, 125, 124, 112,
+			17, 21, 85, 81, 29, 127, 253, 113, 31, 119, backgroundCell, 241,
+			1, 5, 69, 65, 23, 223, 247, 209, 95, 255, 221, 245,
+			0, 4, 68, 64, 117, 71, 197, 93, 7, 199, 215, 193,
+		}}, nil
+	case BlobLayout7x7:
+		return &BlobLayout{Name: name, Cols: 7, Rows: 7, masks: []int{
+			0, 4, 92, 124, 116, 80, emptyCell,
+			16, 20, 87, 223, 241, 21, 64,
+			29, 117, 85, 71, 221, 125, 112,
+			31, 253, 113, 28, 127, 247, 209,
+			23, 199, 213, 95, 255, 245, 81,
+			5, 84, 93, 119, 215, 193, 17,
+			emptyCell, 1, 7, 197, 69, 68, 65,
+		}}, nil
+	case BlobLayout24x11:
+		return newFullBlobLayout(name, 24, 11,
+			[8]Mask{SouthEast, East, NorthEast, South, North, SouthWest, West, NorthWest}), nil
+	case BlobLayout16x16:
+		return newFullBlobLayout(name, 16, 16,
+			[8]Mask{NorthWest, North, NorthEast, West, East, SouthWest, South, SouthEast}), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownLayout, name)
+}
+
+// newFullBlobLayout returns a layout of all 256 masks where the index of a cell is its mask
+// in a convention described by bits (see remapMask).
+func newFullBlobLayout(name string, cols, rows int, bits [8]Mask) *BlobLayout {
+	masks := make([]int, cols*rows)
+	for i := range masks {
+		masks[i] = emptyCell
+		if i < 256 {
+			masks[i] = int(remapMask(i, bits))
+		}
+	}
+	return &BlobLayout{Name: name, Cols: cols, Rows: rows, masks: masks}
+}
+
+// Mask returns the mask of the tile at the given position and false if there is no tile.
+func (l *BlobLayout) Mask(x, y int) (Mask, bool) {
+	if x < 0 || y < 0 || x >= l.Cols || y >= l.Rows {
+		return 0, false
+	}
+	m := l.masks[y*l.Cols+x]
+	if m < 0 {
+		return 0, false
+	}
+	return Mask(m), true
+}
+
+// Background returns the position of the cell filled with the terrain surrounding blobs
+// and false if the layout has no such cell.
+func (l *BlobLayout) Background() (image.Point, bool) {
+	for i, cellMask := range l.masks {
+		if cellMask == backgroundCell {
+			return image.Point{X: i % l.Cols, Y: i / l.Cols}, true
+		}
+	}
+	return image.Point{}, false
+}
+
+// Cell returns the position of the tile for the given mask and false if there is none.
+// A tile whose mask is equal to the canonical form of m is preferred.
+func (l *BlobLayout) Cell(m Mask) (image.Point, bool) {
+	canonical := m.Canonical()
+	res, found := image.Point{}, false
+	for i, cellMask := range l.masks {
+		if cellMask < 0 || Mask(cellMask).Canonical() != canonical {
+			continue
+		}
+		if Mask(cellMask) == canonical {
+			return image.Point{X: i % l.Cols, Y: i / l.Cols}, true
+		}
+		if !found {
+			res, found = image.Point{X: i % l.Cols, Y: i / l.Cols}, true
+		}
+	}
+	return res, found
+}
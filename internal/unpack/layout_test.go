@@ -0,0 +1,314 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestBlobLayoutMasks(t *testing.T) {
+	tests := []struct {
+		name string
+		// full is true for layouts containing all 256 masks, otherwise every canonical mask is expected once
+		full bool
+	}{
+		{name: BlobLayout12x4},
+		{name: BlobLayout7x7},
+		{name: BlobLayout24x11, full: true},
+		{name: BlobLayout16x16, full: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			layout, err := NewBlobLayout(tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			seen := make(map[Mask]image.Point)
+			for y := 0; y < layout.Rows; y++ {
+				for x := 0; x < layout.Cols; x++ {
+					m, ok := layout.Mask(x, y)
+					if !ok {
+						continue
+					}
+					if !tt.full && m != m.Canonical() {
+						t.Errorf("tile (%d, %d): mask %d is not canonical", x, y, m)
+					}
+					if prev, ok := seen[m]; ok {
+						t.Errorf("tile (%d, %d): mask %d is already used by tile %v", x, y, m, prev)
+					}
+					seen[m] = image.Point{X: x, Y: y}
+				}
+			}
+			want := len(canonicalMasks())
+			if tt.full {
+				want = 256
+			}
+			if len(seen) != want {
+				t.Errorf("%d masks, want %d", len(seen), want)
+			}
+			for _, m := range canonicalMasks() {
+				cell, ok := layout.Cell(m)
+				if !ok {
+					t.Errorf("canonical mask %d is missing", m)
+					continue
+				}
+				if got, _ := layout.Mask(cell.X, cell.Y); got != m {
+					t.Errorf("cell for mask %d has mask %d", m, got)
+				}
+			}
+		})
+	}
+}
+
+// TestBlobLayout7x7IsSeamless checks that touching edges of all tiles of the cr31 layout match:
+// the side of a tile, and its corners if the side is set, have to be equal to the opposite side and corners
+// of the neighbour. Empty cells have no terrain at all.
+func TestBlobLayout7x7IsSeamless(t *testing.T) {
+	layout, err := NewBlobLayout(BlobLayout7x7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edge := func(x, y int, side, corner1, corner2 Mask) [3]bool {
+		m, _ := layout.Mask(x, y)
+		return [3]bool{m&side != 0, m&corner1 != 0, m&corner2 != 0}
+	}
+	for y := 0; y < layout.Rows; y++ {
+		for x := 0; x < layout.Cols; x++ {
+			if x+1 < layout.Cols && edge(x, y, East, NorthEast, SouthEast) != edge(x+1, y, West, NorthWest, SouthWest) {
+				t.Errorf("tiles (%d, %d) and (%d, %d) don't match", x, y, x+1, y)
+			}
+			if y+1 < layout.Rows && edge(x, y, South, SouthWest, SouthEast) != edge(x, y+1, North, NorthWest, NorthEast) {
+				t.Errorf("tiles (%d, %d) and (%d, %d) don't match", x, y, x, y+1)
+			}
+		}
+	}
+}
+
+func TestCanonicalMasks(t *testing.T) {
+	if got := len(canonicalMasks()); got != 47 {
+		t.Fatalf("%d canonical masks, want 47", got)
+	}
+	for m := 0; m < 256; m++ {
+		canonical := Mask(m).Canonical()
+		if canonical.Canonical() != canonical {
+			t.Errorf("mask %d: canonical form %d is not stable", m, canonical)
+		}
+		if canonical&^Mask(m) != 0 {
+			t.Errorf("mask %d: canonical form %d sets new bits", m, canonical)
+		}
+	}
+}
+
+// terrainSource returns a 2x3 tile set of 4x4 px tiles where every pixel of a 2x2 px sub tile is white
+// if the corner of the sub tile it is in is terrain 1 and black otherwise (see sourceQuarterCorners).
+func terrainSource() *image.NRGBA {
+	src := image.NewNRGBA(image.Rect(0, 0, 8, 12))
+	for y := 0; y < 12; y++ {
+		for x := 0; x < 8; x++ {
+			c := color.NRGBA{A: 255}
+			if sourceQuarterCorners(x/2, y/2)[y%2*2+x%2] {
+				c = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+			}
+			src.SetNRGBA(x, y, c)
+		}
+	}
+	return src
+}
+
+// pixelGrid reads the terrain grid of a 4x4 px tile drawn from terrainSource starting at the given point.
+// It returns false if the tile is transparent or its pixels are not a terrain grid.
+func pixelGrid(img *image.NRGBA, at image.Point) (terrainGrid, bool) {
+	var grid terrainGrid
+	cell := [4]int{0, 1, 1, 2}
+	if img.NRGBAAt(at.X, at.Y).A == 0 {
+		return grid, false
+	}
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			white := img.NRGBAAt(at.X+x, at.Y+y) == color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+			if x != 2 && y != 2 {
+				grid[cell[y]][cell[x]] = white
+			}
+			if grid[cell[y]][cell[x]] != white {
+				return grid, false
+			}
+		}
+	}
+	return grid, true
+}
+
+// referenceGrids reads tiles of a 3x3 bitmask reference image: red cells are the blob terrain.
+// Tiles are size px squares every pitch px, the first cell of the first tile is at offset;
+// nil is returned for cells without a tile.
+func referenceGrids(t *testing.T, path string, cols, rows, pitch, size int, offset image.Point) []*terrainGrid {
+	t.Helper()
+	ref := loadImage(t, path)
+	red := color.NRGBA{R: 255, A: 255}
+	var res []*terrainGrid
+	for ty := 0; ty < rows; ty++ {
+		for tx := 0; tx < cols; tx++ {
+			var grid terrainGrid
+			empty := true
+			for y := 0; y < 3; y++ {
+				for x := 0; x < 3; x++ {
+					p := offset.Add(image.Pt(tx*pitch+x*size/3+size/6, ty*pitch+y*size/3+size/6))
+					c := color.NRGBAModel.Convert(ref.At(p.X, p.Y)).(color.NRGBA)
+					grid[y][x] = c == red
+					empty = empty && c != red && c != color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+				}
+			}
+			if empty {
+				res = append(res, nil)
+				continue
+			}
+			res = append(res, &grid)
+		}
+	}
+	return res
+}
+
+// blobGrid returns the terrain grid of a blob tile with the given neighbours: sides are set as neighbours are,
+// corners only if both adjacent sides are set and the center unless the tile is an end piece.
+func blobGrid(neighbours terrainGrid) terrainGrid {
+	grid := neighbours
+	grid[0][0] = neighbours[0][0] && neighbours[0][1] && neighbours[1][0]
+	grid[0][2] = neighbours[0][2] && neighbours[0][1] && neighbours[1][2]
+	grid[2][0] = neighbours[2][0] && neighbours[2][1] && neighbours[1][0]
+	grid[2][2] = neighbours[2][2] && neighbours[2][1] && neighbours[1][2]
+	sides := 0
+	for _, side := range []bool{neighbours[0][1], neighbours[1][0], neighbours[1][2], neighbours[2][1]} {
+		if side {
+			sides++
+		}
+	}
+	grid[1][1] = sides != 1
+	return grid
+}
+
+// TestConvertLayoutTilesMatchBitmask checks pixels of every tile converted from a 12x4 tile set
+// against terrain grids read from the reference images of 256 tiles layouts.
+// The 7x7 layout has no reference, so its tiles have to be 47 distinct blob tiles with matching edges.
+func TestConvertLayoutTilesMatchBitmask(t *testing.T) {
+	const padding = 1
+	u := NewUnpacker(terrainSource(), 2, 3, padding)
+	if err := u.Init(2); err != nil {
+		t.Fatal(err)
+	}
+	src, err := u.From6to48Terrain2()
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, err := NewBlobLayout(BlobLayout12x4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		refs []*terrainGrid
+	}{
+		{BlobLayout24x11, referenceGrids(t, "../../references/24x11_bitmask_reference_3x3_full.png", 24, 11, 80, 60, image.Pt(4, 5))},
+		{BlobLayout16x16, referenceGrids(t, "../../references/16x16_bitmask_reference_3x3_full.png", 16, 16, 12, 12, image.Point{})},
+		{BlobLayout7x7, nil},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			to, err := NewBlobLayout(tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ConvertLayout(src, from, to, padding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			grids := make(map[image.Point]terrainGrid)
+			for y := 0; y < to.Rows; y++ {
+				for x := 0; x < to.Cols; x++ {
+					if grid, ok := pixelGrid(got, image.Pt(x*(4+padding*2)+padding, y*(4+padding*2)+padding)); ok {
+						grids[image.Pt(x, y)] = grid
+					}
+				}
+			}
+			if tt.refs == nil {
+				checkSeamlessGrids(t, grids, to.Cols, to.Rows)
+				return
+			}
+			tiles := 0
+			for i, ref := range tt.refs {
+				cell := image.Pt(i%to.Cols, i/to.Cols)
+				grid, ok := grids[cell]
+				if ref == nil {
+					if ok {
+						t.Errorf("tile %v: got %s, want no tile", cell, grid)
+					}
+					continue
+				}
+				tiles++
+				if want := blobGrid(*ref); !ok || grid != want {
+					t.Errorf("tile %v with neighbours %s: got %s, want %s", cell, *ref, grid, want)
+				}
+			}
+			if tiles != 256 {
+				t.Errorf("reference has %d tiles, want 256", tiles)
+			}
+		})
+	}
+}
+
+// checkSeamlessGrids checks that there are 47 distinct blob tiles in the grids and that the sides of touching tiles
+// have the same terrain. Cells without a tile have no blob terrain.
+func checkSeamlessGrids(t *testing.T, grids map[image.Point]terrainGrid, cols, rows int) {
+	t.Helper()
+	seen := make(map[terrainGrid]image.Point)
+	for cell, grid := range grids {
+		if grid[1][1] && grid != blobGrid(grid) {
+			t.Errorf("tile %v: %s is not a blob tile", cell, grid)
+		}
+		if prev, ok := seen[grid]; ok {
+			t.Errorf("tiles %v and %v are both %s", prev, cell, grid)
+		}
+		seen[grid] = cell
+	}
+	if len(seen) != 47 {
+		t.Errorf("%d distinct tiles, want 47", len(seen))
+	}
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			a := grids[image.Pt(x, y)]
+			right, below := grids[image.Pt(x+1, y)], grids[image.Pt(x, y+1)]
+			for i := 0; i < 3; i++ {
+				if x+1 < cols && a[i][2] != right[i][0] {
+					t.Errorf("tiles (%d, %d) and (%d, %d) don't match", x, y, x+1, y)
+				}
+				if y+1 < rows && a[2][i] != below[0][i] {
+					t.Errorf("tiles (%d, %d) and (%d, %d) don't match", x, y, x, y+1)
+				}
+			}
+		}
+	}
+}
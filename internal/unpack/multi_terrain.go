@@ -0,0 +1,298 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"context"
+	"fmt"
+	"image"
+)
+
+// LayoutCornerWangTerrains is the corner Wang tile set of several terrains generated by MultiTerrain.
+const LayoutCornerWangTerrains = "corner_wang_terrains"
+
+// minTerrains is the minimal number of terrains of a multi-terrain tile set.
+const minTerrains = 2
+
+// CornerWangTile describes a tile of a corner Wang tile set of several terrains.
+type CornerWangTile struct {
+	// Cell is the position of the tile in the tile set.
+	Cell image.Point
+	// Corners are terrains at the top-left, top-right, bottom-left and bottom-right corners of the tile.
+	// Terrains are numbered from 1 in the order of MultiTerrain terrains, so the first two are Terrain1 and Terrain2.
+	Corners [4]int
+}
+
+// DescribeCornerWang returns tiles of the corner Wang tile set of n terrains: every combination of corner terrains
+// once, n^4 tiles in n^2 columns. The index of a tile row by row is the number with digits of its corner terrains
+// minus one in base n, the top-left corner is the most significant digit.
+//
+// Parameters:
+// - n: The number of terrains.
+//
+// Returns:
+// - The number of columns and rows of the tile set.
+// - Tiles of the tile set in the order of their indexes.
+func DescribeCornerWang(n int) (cols, rows int, tiles []CornerWangTile) {
+	if n < 1 {
+		return 0, 0, nil
+	}
+	cols, rows = n*n, n*n
+	tiles = make([]CornerWangTile, 0, cols*rows)
+	for idx := 0; idx < cols*rows; idx++ {
+		tile := CornerWangTile{Cell: image.Point{X: idx % cols, Y: idx / cols}}
+		for corner, rest := 3, idx; corner >= 0; corner, rest = corner-1, rest/n {
+			tile.Corners[corner] = rest%n + 1
+		}
+		tiles = append(tiles, tile)
+	}
+	return cols, rows, tiles
+}
+
+// TerrainGrid returns terrains of the corners, middles of sides and the center of the tile, row by row.
+// Terrains with higher numbers draw over lower ones like Terrain2 over Terrain1 in a 2x3 tile set:
+// the middle of a side gets the higher terrain of its corners and the center the highest one.
+func (t CornerWangTile) TerrainGrid() [3][3]int {
+	tl, tr, bl, br := t.Corners[0], t.Corners[1], t.Corners[2], t.Corners[3]
+	return [3][3]int{
+		{tl, max(tl, tr), tr},
+		{max(tl, bl), max(tl, tr, bl, br), max(tr, br)},
+		{bl, max(bl, br), br},
+	}
+}
+
+// segmentPack returns terrains of the packed tile set the seg-th segment of the tile is drawn from
+// and corners of the segment covered by the terrain 1 of that tile set.
+// The segment spans terrains of its tile corner, two side middles and the tile center, so it may show
+// three terrains where they meet. A packed tile set has two, so the segment is drawn from the one of
+// its corner terrain and the highest terrain of the side middles, which keeps sides of tiles matching
+// whenever the corner touches at most one other terrain; the center is approximated otherwise, see Approximate.
+func (t CornerWangTile) segmentPack(seg, n int) ([2]int, corners) {
+	grid := t.TerrainGrid()
+	var terrains [4]int
+	for corner := range terrains {
+		terrains[corner] = grid[seg/2+corner/2][seg%2+corner%2]
+	}
+	low := t.Corners[seg]
+	high := max(terrains[seg^1], terrains[seg^2])
+	if high == low {
+		high = terrains[seg^3]
+	}
+	if high == low {
+		// a segment of a single terrain is the fill of any packed tile set of the terrain
+		if low < n {
+			return [2]int{low, low + 1}, 0b1111
+		}
+		return [2]int{low - 1, low}, 0
+	}
+	var want corners
+	for corner, terrain := range terrains {
+		if terrain == low {
+			want |= 1 << corner
+		}
+	}
+	return [2]int{low, high}, want
+}
+
+// approximateSegment reports whether three or more terrains meet in the seg-th segment of the tile.
+func (t CornerWangTile) approximateSegment(seg int) bool {
+	grid := t.TerrainGrid()
+	terrains := make(map[int]bool, 4)
+	for corner := 0; corner < 4; corner++ {
+		terrains[grid[seg/2+corner/2][seg%2+corner%2]] = true
+	}
+	return len(terrains) > 2
+}
+
+// Approximate reports whether three terrains meet in a segment of the tile. A packed tile set shows two terrains,
+// so such a segment shows one of its side middles as the wrong terrain, and the half of the tile side
+// through that middle may not match the neighbouring tile. Only tiles with three or more distinct corner terrains
+// can be approximate.
+func (t CornerWangTile) Approximate() bool {
+	for seg := 0; seg < 4; seg++ {
+		if t.approximateSegment(seg) {
+			return true
+		}
+	}
+	return false
+}
+
+// MultiTerrain generates a corner Wang tile set of several terrains from 2x3 tile sets of pairs of them.
+type MultiTerrain struct {
+	terrains []string
+	padding  int
+	// approximate allows generating tiles which are only approximated, see CornerWangTile.Approximate.
+	approximate bool
+	// packs holds unpackers of 2x3 tile sets by numbers of their terrain 1 and terrain 2.
+	packs map[[2]int]*Unpacker
+}
+
+// NewMultiTerrain creates a generator of the corner Wang tile set of the given terrains.
+// Terrains listed later draw over earlier ones, see CornerWangTile.TerrainGrid.
+// Arguments are validated by AddPack and Generate.
+func NewMultiTerrain(terrains []string, padding int) *MultiTerrain {
+	return &MultiTerrain{
+		terrains: append([]string(nil), terrains...),
+		padding:  padding,
+		packs:    make(map[[2]int]*Unpacker),
+	}
+}
+
+// Terrains returns names of the terrains in the order of their numbers.
+func (m *MultiTerrain) Terrains() []string {
+	return append([]string(nil), m.terrains...)
+}
+
+// SetApproximate allows Generate to draw tiles where three terrains meet in a segment (see CornerWangTile.Approximate)
+// from the packed tile sets of pairs of them. Without it Generate rejects three or more terrains which have such tiles.
+// Approximate tiles show the wrong terrain at halves of some sides, so they shouldn't be placed by automatic terrain tools.
+func (m *MultiTerrain) SetApproximate(approximate bool) {
+	m.approximate = approximate
+}
+
+// validateTerrains checks that there are at least two terrains with distinct names.
+func (m *MultiTerrain) validateTerrains() error {
+	if len(m.terrains) < minTerrains {
+		return fmt.Errorf("%w: got %d terrains, want at least %d", ErrInvalidTerrains, len(m.terrains), minTerrains)
+	}
+	seen := make(map[string]bool)
+	for _, name := range m.terrains {
+		if name == "" || seen[name] {
+			return fmt.Errorf("%w: terrain names have to be distinct and not empty, got %q", ErrInvalidTerrains, name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// terrain returns the number of the terrain with the given name.
+func (m *MultiTerrain) terrain(name string) (int, error) {
+	for i, terrain := range m.terrains {
+		if terrain == name {
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: unknown terrain %q", ErrInvalidTerrains, name)
+}
+
+// AddPack adds the 2x3 tile set of a pair of terrains.
+//
+// Parameters:
+// - terrain1: The name of the terrain inside the blob of the 2x3 tile set, it has to be listed before terrain2.
+// - terrain2: The name of the terrain filling the top left tile of the 2x3 tile set.
+// - src: The 2x3 tile set, its tiles have to have the size of tiles of packs added before.
+//
+// Returns:
+// - An error if the terrains are unknown or out of order or the tile set can't be unpacked.
+func (m *MultiTerrain) AddPack(terrain1, terrain2 string, src image.Image) error {
+	if err := m.validateTerrains(); err != nil {
+		return err
+	}
+	t1, err := m.terrain(terrain1)
+	if err != nil {
+		return err
+	}
+	t2, err := m.terrain(terrain2)
+	if err != nil {
+		return err
+	}
+	if t1 >= t2 {
+		return fmt.Errorf("%w: %s has to be listed before %s, terrain 2 of a 2x3 tile set draws over terrain 1",
+			ErrInvalidTerrains, terrain1, terrain2)
+	}
+	u := NewUnpacker(src, sixPackXTiles, sixPackYTiles, m.padding)
+	if err := u.Init(sixPackSegments); err != nil {
+		return fmt.Errorf("%s,%s: %w", terrain1, terrain2, err)
+	}
+	for pair, other := range m.packs {
+		if other.tileWidth != u.tileWidth || other.tileHeight != u.tileHeight {
+			return fmt.Errorf("%s,%s: %w: %dx%d px tiles, %s,%s has %dx%d px tiles", terrain1, terrain2,
+				ErrInvalidTileSize, u.tileWidth, u.tileHeight,
+				m.terrains[pair[0]-1], m.terrains[pair[1]-1], other.tileWidth, other.tileHeight)
+		}
+	}
+	m.packs[[2]int{t1, t2}] = u
+	return nil
+}
+
+// Generate generates the corner Wang tile set described by DescribeCornerWang for the number of terrains.
+// Every segment of a tile is copied unturned from the packed tile set returned by CornerWangTile.segmentPack.
+// Three or more terrains give tiles which can only be approximated, they are generated only if SetApproximate allows it.
+//
+// Parameters:
+// - ctx: The context which cancels generation between tiles.
+// - progress: The function receiving the number of generated tiles, may be nil.
+//
+// Returns:
+// - A pointer to the generated image.
+// - An error if a pair of terrains has no packed tile set or a tile requires a transparent segment,
+// or an error wrapping ErrApproximateTiles if there are approximate tiles and approximation isn't allowed.
+func (m *MultiTerrain) Generate(ctx context.Context, progress ProgressFunc) (*image.NRGBA, error) {
+	if err := m.validateTerrains(); err != nil {
+		return nil, err
+	}
+	n := len(m.terrains)
+	drawn := make(map[[2]int][6][4]bool, len(m.packs))
+	for low := 1; low <= n; low++ {
+		for high := low + 1; high <= n; high++ {
+			u, ok := m.packs[[2]int{low, high}]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s,%s", ErrMissingPack, m.terrains[low-1], m.terrains[high-1])
+			}
+			drawn[[2]int{low, high}] = u.drawnSegments()
+		}
+	}
+	first := m.packs[[2]int{1, 2}]
+	cols, rows, tiles := DescribeCornerWang(n)
+	if !m.approximate {
+		approximate := 0
+		for _, tile := range tiles {
+			if tile.Approximate() {
+				approximate++
+			}
+		}
+		if approximate > 0 {
+			return nil, fmt.Errorf("%s: %w: three terrains meet in %d of %d tiles, which 2x3 tile sets of pairs can't draw exactly",
+				LayoutCornerWangTerrains, ErrApproximateTiles, approximate, len(tiles))
+		}
+	}
+	canvas := image.NewNRGBA(image.Rect(0, 0, first.paddedTileWidth()*cols, first.paddedTileHeight()*rows))
+	for i, tile := range tiles {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("%s: %w", LayoutCornerWangTerrains, err)
+		}
+		for seg := 0; seg < 4; seg++ {
+			pair, want := tile.segmentPack(seg, n)
+			xy, err := sourceSegment(want, seg, drawn[pair])
+			if err != nil {
+				return nil, &TileError{Layout: LayoutCornerWangTerrains, Tile: tile.Cell, Err: fmt.Errorf("%s,%s: %w",
+					m.terrains[pair[0]-1], m.terrains[pair[1]-1], err)}
+			}
+			m.packs[pair].drawSegment(canvas, seg, xy, i, cols)
+		}
+		progress.report(i+1, len(tiles))
+	}
+	return canvas, nil
+}
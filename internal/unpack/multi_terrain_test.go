@@ -0,0 +1,294 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDescribeCornerWang(t *testing.T) {
+	cols, rows, tiles := DescribeCornerWang(3)
+	if cols != 9 || rows != 9 || len(tiles) != 81 {
+		t.Fatalf("got %dx%d with %d tiles, want 9x9 with 81", cols, rows, len(tiles))
+	}
+	seen := make(map[[4]int]bool)
+	for i, tile := range tiles {
+		if tile.Cell != (image.Point{X: i % cols, Y: i / cols}) {
+			t.Errorf("tile %d is at %v", i, tile.Cell)
+		}
+		if seen[tile.Corners] {
+			t.Errorf("corners %v are repeated", tile.Corners)
+		}
+		seen[tile.Corners] = true
+	}
+	if got, want := tiles[5].Corners, [4]int{1, 1, 2, 3}; got != want {
+		t.Errorf("tile 5: got corners %v, want %v", got, want)
+	}
+	if grid := (CornerWangTile{Corners: [4]int{1, 3, 2, 1}}).TerrainGrid(); grid != [3][3]int{{1, 3, 3}, {2, 3, 3}, {2, 2, 1}} {
+		t.Errorf("got grid %v", grid)
+	}
+}
+
+// multiTerrainSource returns a 2x3 tile set like segmentIDSource with the id of the pack in the alpha channel.
+func multiTerrainSource(segSize int, pack uint8) *image.NRGBA {
+	src := segmentIDSource(segSize, segSize)
+	for i := 3; i < len(src.Pix); i += 4 {
+		src.Pix[i] = 255 - pack
+	}
+	return src
+}
+
+func TestMultiTerrainGenerate(t *testing.T) {
+	const segSize = 2
+	terrains := []string{"grass", "dirt", "water"}
+	pairs := [][2]int{{1, 2}, {1, 3}, {2, 3}}
+	m := NewMultiTerrain(terrains, 1)
+	m.SetApproximate(true)
+	for i, pair := range pairs {
+		if err := m.AddPack(terrains[pair[0]-1], terrains[pair[1]-1], multiTerrainSource(segSize, uint8(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var reported int
+	img, err := m.Generate(context.Background(), func(done, total int) { reported = done })
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols, rows, tiles := DescribeCornerWang(len(terrains))
+	const padded = 2*segSize + 2
+	if img.Rect != image.Rect(0, 0, cols*padded, rows*padded) || reported != len(tiles) {
+		t.Fatalf("got %v after %d tiles", img.Rect, reported)
+	}
+	for _, tile := range tiles {
+		grid := tile.TerrainGrid()
+		for seg := 0; seg < 4; seg++ {
+			pix := img.NRGBAAt(tile.Cell.X*padded+1+seg%2*segSize, tile.Cell.Y*padded+1+seg/2*segSize)
+			pair, c := pairs[255-pix.A], sixQuarterCorners[pix.R/4][pix.R%4]
+			var want [4]int
+			distinct := make(map[int]bool)
+			for corner := range want {
+				want[corner] = grid[seg/2+corner/2][seg%2+corner%2]
+				distinct[want[corner]] = true
+			}
+			for corner := range want {
+				got := pair[1]
+				if c.has(corner) {
+					got = pair[0]
+				}
+				// where three terrains meet the segment can't show all of them, but its tile corner is exact
+				if got != want[corner] && (corner == seg || len(distinct) <= 2) {
+					t.Errorf("tile %v %s segment: corner %s is terrain %d, want %d",
+						tile.Corners, segmentNames[seg], segmentNames[corner], got, want[corner])
+				}
+			}
+		}
+	}
+}
+
+func TestMultiTerrainTwoTerrainsMatchCornerWang(t *testing.T) {
+	const segSize = 2
+	m := NewMultiTerrain([]string{"grass", "water"}, 0)
+	if err := m.AddPack("grass", "water", segmentIDSource(segSize, segSize)); err != nil {
+		t.Fatal(err)
+	}
+	img, err := m.Generate(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, tiles := DescribeCornerWang(2)
+	for _, tile := range tiles {
+		var c corners
+		for corner, terrain := range tile.Corners {
+			if terrain == Terrain1 {
+				c |= 1 << corner
+			}
+		}
+		for seg := 0; seg < 4; seg++ {
+			id := img.NRGBAAt(tile.Cell.X*2*segSize+seg%2*segSize, tile.Cell.Y*2*segSize+seg/2*segSize).R
+			if got, want := sixQuarterCorners[id/4][id%4], c.segment(seg); got != want {
+				t.Errorf("tile %04b %s segment: got corners %04b, want %04b", c, segmentNames[seg], got, want)
+			}
+		}
+	}
+}
+
+func TestMultiTerrainErrors(t *testing.T) {
+	src := func(size int) image.Image {
+		img := image.NewNRGBA(image.Rect(0, 0, 2*size, 3*size))
+		for i := range img.Pix {
+			img.Pix[i] = 255
+		}
+		return img
+	}
+	tests := []struct {
+		name     string
+		terrains []string
+		packs    [][2]string
+		sizes    []int
+		want     error
+	}{
+		{"single terrain", []string{"grass"}, nil, nil, ErrInvalidTerrains},
+		{"repeated terrain", []string{"grass", "grass"}, nil, nil, ErrInvalidTerrains},
+		{"unknown terrain", []string{"grass", "water"}, [][2]string{{"grass", "lava"}}, []int{4}, ErrInvalidTerrains},
+		{"out of order", []string{"grass", "water"}, [][2]string{{"water", "grass"}}, []int{4}, ErrInvalidTerrains},
+		{"invalid pack", []string{"grass", "water"}, [][2]string{{"grass", "water"}}, []int{1}, ErrImageTooSmall},
+		{"different tile sizes", []string{"grass", "dirt", "water"},
+			[][2]string{{"grass", "dirt"}, {"dirt", "water"}}, []int{4, 8}, ErrInvalidTileSize},
+		{"missing pack", []string{"grass", "dirt", "water"},
+			[][2]string{{"grass", "dirt"}, {"dirt", "water"}}, []int{4, 4}, ErrMissingPack},
+		{"approximation not allowed", []string{"grass", "dirt", "water"},
+			[][2]string{{"grass", "dirt"}, {"dirt", "water"}, {"grass", "water"}}, []int{4, 4, 4}, ErrApproximateTiles},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMultiTerrain(tt.terrains, 0)
+			var err error
+			for i, pack := range tt.packs {
+				if err = m.AddPack(pack[0], pack[1], src(tt.sizes[i])); err != nil {
+					break
+				}
+			}
+			if err == nil {
+				_, err = m.Generate(context.Background(), nil)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// a transparent pack lacks every segment
+	m := NewMultiTerrain([]string{"grass", "water"}, 0)
+	if err := m.AddPack("grass", "water", image.NewNRGBA(image.Rect(0, 0, 8, 12))); err != nil {
+		t.Fatal(err)
+	}
+	_, err := m.Generate(context.Background(), nil)
+	var tileErr *TileError
+	if !errors.As(err, &tileErr) || !errors.Is(err, ErrMissingSegment) {
+		t.Errorf("got %v, want a tile error of a missing segment", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := m.Generate(ctx, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled: got %v", err)
+	}
+}
+
+// terrainPackSource returns a 2x3 tile set of 2x2 px segments where every pixel is a corner of its segment
+// (see sourceQuarterCorners) with the number of its terrain in the red channel.
+func terrainPackSource(terrain1, terrain2 int) *image.NRGBA {
+	src := image.NewNRGBA(image.Rect(0, 0, 8, 12))
+	for y := 0; y < 12; y++ {
+		for x := 0; x < 8; x++ {
+			terrain := terrain2
+			if sourceQuarterCorners(x/2, y/2)[y%2*2+x%2] {
+				terrain = terrain1
+			}
+			src.SetNRGBA(x, y, color.NRGBA{R: uint8(terrain), A: 255})
+		}
+	}
+	return src
+}
+
+// TestMultiTerrainSeams checks touching sides of every pair of tiles which may be neighbours, i.e. share
+// the terrains of the corners on the touching side. Halves of sides match unless a segment along them
+// shows three terrains, which only approximate tiles have.
+func TestMultiTerrainSeams(t *testing.T) {
+	terrains := []string{"grass", "dirt", "water"}
+	m := NewMultiTerrain(terrains, 0)
+	m.SetApproximate(true)
+	for low := 1; low <= len(terrains); low++ {
+		for high := low + 1; high <= len(terrains); high++ {
+			if err := m.AddPack(terrains[low-1], terrains[high-1], terrainPackSource(low, high)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	img, err := m.Generate(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, tiles := DescribeCornerWang(len(terrains))
+	// i-th pixel of the side of the tile, with the segment the pixel is in
+	right := func(tile CornerWangTile, i int) (color.NRGBA, int) {
+		return img.NRGBAAt(tile.Cell.X*4+3, tile.Cell.Y*4+i), i/2*2 + 1
+	}
+	left := func(tile CornerWangTile, i int) (color.NRGBA, int) {
+		return img.NRGBAAt(tile.Cell.X*4, tile.Cell.Y*4+i), i / 2 * 2
+	}
+	bottom := func(tile CornerWangTile, i int) (color.NRGBA, int) {
+		return img.NRGBAAt(tile.Cell.X*4+i, tile.Cell.Y*4+3), 2 + i/2
+	}
+	top := func(tile CornerWangTile, i int) (color.NRGBA, int) {
+		return img.NRGBAAt(tile.Cell.X*4+i, tile.Cell.Y*4), i / 2
+	}
+	threeTerrains, approximate := 0, 0
+	for _, a := range tiles {
+		distinct := map[int]bool{a.Corners[0]: true, a.Corners[1]: true, a.Corners[2]: true, a.Corners[3]: true}
+		if len(distinct) == 3 {
+			threeTerrains++
+		}
+		if a.Approximate() {
+			approximate++
+			if len(distinct) < 3 {
+				t.Errorf("tile %v with two corner terrains is approximate", a.Corners)
+			}
+		}
+		for _, b := range tiles {
+			sides := []struct {
+				name      string
+				neighbour bool
+				a, b      func(CornerWangTile, int) (color.NRGBA, int)
+			}{
+				{"right", a.Corners[1] == b.Corners[0] && a.Corners[3] == b.Corners[2], right, left},
+				{"bottom", a.Corners[2] == b.Corners[0] && a.Corners[3] == b.Corners[1], bottom, top},
+			}
+			for _, side := range sides {
+				if !side.neighbour {
+					continue
+				}
+				for i := 0; i < 4; i++ {
+					pixA, segA := side.a(a, i)
+					pixB, segB := side.b(b, i)
+					if pixA == pixB {
+						continue
+					}
+					if !a.approximateSegment(segA) && !b.approximateSegment(segB) {
+						t.Errorf("tile %v %s side pixel %d: terrain %d, neighbour %v has %d",
+							a.Corners, side.name, i, pixA.R, b.Corners, pixB.R)
+					}
+				}
+			}
+		}
+	}
+	// tiles with the highest terrain in opposite corners show all their terrains exactly
+	if threeTerrains != 36 || approximate != 32 {
+		t.Errorf("%d tiles with three corner terrains, %d approximate tiles", threeTerrains, approximate)
+	}
+}
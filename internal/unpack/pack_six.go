@@ -0,0 +1,282 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// Names of the tile set layouts produced from a 2x3 tile set which can be packed back.
+// They match the prefixes of the files written by the CLI.
+const (
+	Layout16Terrain1  = "16x1_terrain1"
+	Layout16Terrain2  = "16x1_terrain2"
+	Layout4x4Terrain1 = "4x4_terrain1"
+	Layout4x4Terrain2 = "4x4_terrain2"
+	Layout28          = "14x2"
+	Layout48Terrain1  = "12x4_terrain1"
+	Layout48Terrain2  = "12x4_terrain2"
+)
+
+// QuarterConflict describes two tiles of a tile set which provide different pixels for the same quarter
+// of the 2x3 tile set.
+type QuarterConflict struct {
+	// Quarter is a coordinate of a sub tile in the 2x3 tile set (see quadTileData).
+	Quarter image.Point
+	// Tile is a position of the tile in the tile set the quarter was taken from.
+	Tile image.Point
+	// Other is a position of the tile in the tile set which disagrees with Tile.
+	Other image.Point
+}
+
+// PackResult is a result of packing a tile set back to the 2x3 tile set.
+type PackResult struct {
+	// Image is the restored 2x3 tile set.
+	Image *image.NRGBA
+	// Conflicts lists quarters for which tiles of the tile set disagree. The first tile always wins.
+	Conflicts []QuarterConflict
+	// Missing lists quarters not used by the layout. They are left transparent.
+	Missing []image.Point
+}
+
+// sixPackSheet describes how tiles generated from a 2x3 tile set are placed on a tile set.
+type sixPackSheet struct {
+	cols, rows int
+	patterns   []quadTileData
+	// placements has the same meaning as from6to48Placements.
+	// If it is nil, patterns are placed one by one row by row without rotation.
+	placements [][]image.Point
+}
+
+// newSixPackSheet returns the description of the given layout.
+func newSixPackSheet(layout string) (*sixPackSheet, error) {
+	switch layout {
+	case Layout16Terrain1:
+		return &sixPackSheet{cols: 16, rows: 1, patterns: export6to16Terrain1TileSet()}, nil
+	case Layout16Terrain2:
+		return &sixPackSheet{cols: 16, rows: 1, patterns: export6to16Terrain2TileSet()}, nil
+	case Layout4x4Terrain1:
+		return &sixPackSheet{cols: 4, rows: 4, patterns: export6to16Terrain1TileSet()}, nil
+	case Layout4x4Terrain2:
+		return &sixPackSheet{cols: 4, rows: 4, patterns: export6to16Terrain2TileSet()}, nil
+	case Layout28:
+		quadMap := export6to28TileSet()
+		return &sixPackSheet{cols: 14, rows: 2, patterns: quadMap[:]}, nil
+	case LayoutDualGrid16:
+		quadMap, err := exportDualGridTileSet()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", layout, err)
+		}
+		return &sixPackSheet{cols: dualGridCols, rows: dualGridCols, patterns: quadMap}, nil
+	case Layout48Terrain1:
+		quadMap := export6to48Terrain1TileSet()
+		return &sixPackSheet{cols: 12, rows: 4, patterns: quadMap[:], placements: from6to48Placements[:]}, nil
+	case Layout48Terrain2:
+		quadMap := export6to48Terrain2TileSet()
+		return &sixPackSheet{cols: 12, rows: 4, patterns: quadMap[:], placements: from6to48Placements[:]}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownLayout, layout)
+}
+
+// cells returns the tile set positions of the given pattern. The index of a position is the number
+// of 90 degrees left rotations applied to the pattern.
+func (s *sixPackSheet) cells(pattern int) []image.Point {
+	if s.placements != nil {
+		return s.placements[pattern]
+	}
+	return []image.Point{{X: pattern % s.cols, Y: pattern / s.cols}}
+}
+
+// Pack is a reverse operation to From6to* functions. It takes a tile set in one of the supported layouts
+// and restores the 2x3 tile set it could be generated from.
+// Every quarter of the 2x3 tile set is taken from the first tile using it; other tiles using the same quarter
+// are compared with it and reported as conflicts if they differ.
+//
+// Parameters:
+// - src: The tile set image.
+// - layout: The layout of the tile set (one of Layout* constants).
+// - padding: The padding of every tile in the tile set in px.
+//
+// Returns:
+// - A PackResult with restored 2x3 tile set, conflicts and missing quarters.
+// - An error if the layout is unknown or the tile set size does not match the layout.
+func Pack(src image.Image, layout string, padding int) (*PackResult, error) {
+	return PackContext(context.Background(), src, layout, padding, nil)
+}
+
+// PackContext is Pack which can be cancelled with the context between tiles and reports the number of
+// processed tiles to progress (which may be nil).
+//
+// Returns:
+// - A PackResult with restored 2x3 tile set, conflicts and missing quarters.
+// - An error if the layout is unknown, the tile set size does not match the layout or the context is done.
+func PackContext(ctx context.Context, src image.Image, layout string, padding int, progress ProgressFunc) (*PackResult, error) {
+	sheet, err := newSixPackSheet(layout)
+	if err != nil {
+		return nil, err
+	}
+	if padding < 0 {
+		return nil, fmt.Errorf("%s: %w: %d px", layout, ErrInvalidPadding, padding)
+	}
+	size := src.Bounds().Size()
+	if size.X%sheet.cols != 0 || size.Y%sheet.rows != 0 {
+		return nil, fmt.Errorf("%s: %w: %dx%d px can't be split into %dx%d tiles",
+			layout, ErrTileSizeNotDivisible, size.X, size.Y, sheet.cols, sheet.rows)
+	}
+	tileWidth := size.X/sheet.cols - padding*2
+	tileHeight := size.Y/sheet.rows - padding*2
+	if tileWidth < 2 || tileHeight < 2 {
+		return nil, fmt.Errorf("%s: %w: %dx%d px tiles without padding", layout, ErrImageTooSmall, tileWidth, tileHeight)
+	}
+	if sheet.placements != nil && tileWidth != tileHeight {
+		// rotated tiles have to be square
+		return nil, fmt.Errorf("%s: %w: %dx%d px tiles are not square", layout, ErrInvalidTileSize, tileWidth, tileHeight)
+	}
+
+	p := &packer{
+		src:        toNRGBA(src),
+		dst:        image.NewNRGBA(image.Rect(0, 0, tileWidth*2, tileHeight*3)),
+		tileWidth:  tileWidth,
+		tileHeight: tileHeight,
+		padding:    padding,
+		owners:     make(map[image.Point]image.Point),
+	}
+	total := 0
+	for i := range sheet.patterns {
+		total += len(sheet.cells(i))
+	}
+	done := 0
+	for i, data := range sheet.patterns {
+		for rotation, cell := range sheet.cells(i) {
+			if err := p.packTile(ctx, cell, data, rotation); err != nil {
+				return nil, fmt.Errorf("%s: %w", layout, err)
+			}
+			done++
+			progress.report(done, total)
+		}
+	}
+
+	res := &PackResult{
+		Image:     p.dst,
+		Conflicts: p.conflicts,
+	}
+	for y := 0; y < 6; y++ {
+		for x := 0; x < 4; x++ {
+			quarter := image.Point{X: x, Y: y}
+			if _, ok := p.owners[quarter]; !ok {
+				res.Missing = append(res.Missing, quarter)
+			}
+		}
+	}
+	return res, nil
+}
+
+// packer holds the state of a single Pack call.
+type packer struct {
+	src, dst              *image.NRGBA
+	tileWidth, tileHeight int
+	padding               int
+	// owners maps a quarter of the 2x3 tile set to the position of the tile it was taken from.
+	owners    map[image.Point]image.Point
+	conflicts []QuarterConflict
+}
+
+// packTile copies quarters of the tile at the given position to the 2x3 tile set.
+//
+// Parameters:
+// - ctx: The context which cancels packing.
+// - cell: The position of the tile in the tile set.
+// - data: The quadTileData the tile was composed of.
+// - rotation: The number of 90 degrees left rotations applied to the tile after composition.
+//
+// Returns:
+// - The context error if the context is done.
+func (p *packer) packTile(ctx context.Context, cell image.Point, data quadTileData, rotation int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if data == nil {
+		return nil
+	}
+	tile := image.NewNRGBA(image.Rect(0, 0, p.tileWidth, p.tileHeight))
+	tileMin := image.Point{
+		X: cell.X*(p.tileWidth+p.padding*2) + p.padding,
+		Y: cell.Y*(p.tileHeight+p.padding*2) + p.padding,
+	}
+	draw.Draw(tile, tile.Bounds(), p.src, tileMin, draw.Src)
+	// rotating left the remaining number of times restores the original orientation
+	for r := (4 - rotation%4) % 4; r > 0; r-- {
+		var err error
+		if tile, err = rotateLeft90(ctx, tile); err != nil {
+			return err
+		}
+	}
+
+	for i, xy := range data {
+		quarter := image.Point{X: xy[0], Y: xy[1]}
+		tileArea := p.quarterRect(i%2, i>>1)
+		if owner, ok := p.owners[quarter]; ok {
+			if !sameArea(p.dst, p.quarterRect(quarter.X, quarter.Y), tile, tileArea.Min) {
+				p.conflicts = append(p.conflicts, QuarterConflict{Quarter: quarter, Tile: owner, Other: cell})
+			}
+			continue
+		}
+		p.owners[quarter] = cell
+		draw.Draw(p.dst, p.quarterRect(quarter.X, quarter.Y), tile, tileArea.Min, draw.Src)
+	}
+	return nil
+}
+
+// quarterRect returns the area of a quarter with the given coordinates.
+func (p *packer) quarterRect(x, y int) image.Rectangle {
+	minPoint := image.Point{
+		X: x * p.tileWidth / 2,
+		Y: y * p.tileHeight / 2,
+	}
+	return image.Rectangle{
+		Min: minPoint,
+		Max: image.Point{
+			X: minPoint.X + p.tileWidth/2,
+			Y: minPoint.Y + p.tileHeight/2,
+		},
+	}
+}
+
+// sameArea reports whether the area of a is equal to the area of the same size of b starting at bMin.
+func sameArea(a *image.NRGBA, area image.Rectangle, b *image.NRGBA, bMin image.Point) bool {
+	rowSize := area.Dx() * 4
+	for y := 0; y < area.Dy(); y++ {
+		aOffset := a.PixOffset(area.Min.X, area.Min.Y+y)
+		bOffset := b.PixOffset(bMin.X, bMin.Y+y)
+		if !bytes.Equal(a.Pix[aOffset:aOffset+rowSize], b.Pix[bOffset:bOffset+rowSize]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,195 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"reflect"
+	"testing"
+)
+
+// packSegmentSize is the size of a quarter of the packSource tiles in px.
+const packSegmentSize = 4
+
+// packSource returns a 2x3 tile set of 8x8 tiles in which every pixel is unique.
+// The red channel is the index of the quarter, green and blue are the offset within it.
+func packSource() *image.NRGBA {
+	src := image.NewNRGBA(image.Rect(0, 0, 4*packSegmentSize, 6*packSegmentSize))
+	for y := 0; y < src.Rect.Dy(); y++ {
+		for x := 0; x < src.Rect.Dx(); x++ {
+			src.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(y/packSegmentSize*4 + x/packSegmentSize),
+				G: uint8(x % packSegmentSize),
+				B: uint8(y % packSegmentSize),
+				A: 255,
+			})
+		}
+	}
+	return src
+}
+
+// stripToSquare rearranges a strip of 16 tiles to a 4x4 grid row by row.
+func stripToSquare(strip *image.NRGBA) *image.NRGBA {
+	tileWidth := strip.Rect.Dx() / 16
+	tileHeight := strip.Rect.Dy()
+	res := image.NewNRGBA(image.Rect(0, 0, tileWidth*4, tileHeight*4))
+	for i := 0; i < 16; i++ {
+		dst := image.Rect(0, 0, tileWidth, tileHeight).Add(image.Pt(i%4*tileWidth, i/4*tileHeight))
+		draw.Draw(res, dst, strip, image.Pt(i*tileWidth, 0), draw.Src)
+	}
+	return res
+}
+
+func TestPackRestoresSource(t *testing.T) {
+	square := func(export func(u *Unpacker) (*image.NRGBA, error)) func(u *Unpacker) (*image.NRGBA, error) {
+		return func(u *Unpacker) (*image.NRGBA, error) {
+			img, err := export(u)
+			if err != nil {
+				return nil, err
+			}
+			return stripToSquare(img), nil
+		}
+	}
+	tests := []struct {
+		layout  string
+		export  func(u *Unpacker) (*image.NRGBA, error)
+		missing []image.Point
+	}{
+		{
+			Layout16Terrain1, (*Unpacker).From6to16Terrain1,
+			[]image.Point{{2, 1}, {3, 1}, {1, 2}, {1, 5}, {2, 5}},
+		},
+		{
+			Layout16Terrain2, (*Unpacker).From6to16Terrain2,
+			[]image.Point{{0, 2}, {1, 2}, {2, 2}, {3, 2}, {2, 5}},
+		},
+		{
+			Layout4x4Terrain1, square((*Unpacker).From6to16Terrain1),
+			[]image.Point{{2, 1}, {3, 1}, {1, 2}, {1, 5}, {2, 5}},
+		},
+		{
+			Layout4x4Terrain2, square((*Unpacker).From6to16Terrain2),
+			[]image.Point{{0, 2}, {1, 2}, {2, 2}, {3, 2}, {2, 5}},
+		},
+		{Layout28, (*Unpacker).From6to28, []image.Point{{1, 2}, {2, 5}}},
+		{LayoutDualGrid16, (*Unpacker).From6to16DualGrid, []image.Point{{2, 2}, {0, 4}, {3, 4}, {2, 5}}},
+		{Layout48Terrain1, (*Unpacker).From6to48Terrain1, []image.Point{{1, 2}}},
+		{Layout48Terrain2, (*Unpacker).From6to48Terrain2, []image.Point{{2, 5}}},
+	}
+	src := packSource()
+	for _, padding := range []int{0, 1} {
+		u := NewUnpacker(src, 2, 3, padding)
+		if err := u.Init(2); err != nil {
+			t.Fatal(err)
+		}
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s_p%d", tt.layout, padding), func(t *testing.T) {
+				img, err := tt.export(u)
+				if err != nil {
+					t.Fatal(err)
+				}
+				res, err := Pack(img, tt.layout, padding)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(res.Conflicts) != 0 {
+					t.Errorf("unexpected conflicts %v", res.Conflicts)
+				}
+				if !reflect.DeepEqual(res.Missing, tt.missing) {
+					t.Errorf("got missing quarters %v, want %v", res.Missing, tt.missing)
+				}
+				if res.Image.Rect != src.Rect {
+					t.Fatalf("got %v, want %v", res.Image.Rect, src.Rect)
+				}
+				p := &packer{tileWidth: 2 * packSegmentSize, tileHeight: 2 * packSegmentSize}
+				for y := 0; y < 6; y++ {
+					for x := 0; x < 4; x++ {
+						quarter := image.Point{X: x, Y: y}
+						if contains(tt.missing, quarter) {
+							continue
+						}
+						area := p.quarterRect(x, y)
+						if !sameArea(res.Image, area, src, area.Min) {
+							t.Errorf("quarter %v differs from the source", quarter)
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func contains(points []image.Point, p image.Point) bool {
+	for _, point := range points {
+		if point == p {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPackConflicts(t *testing.T) {
+	u := NewUnpacker(packSource(), 2, 3, 0)
+	if err := u.Init(2); err != nil {
+		t.Fatal(err)
+	}
+	img, err := u.From6to28()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the top-left quarter of the last tile
+	last := image.Point{X: 13, Y: 1}
+	img.SetNRGBA(last.X*8, last.Y*8, color.NRGBA{A: 255})
+
+	res, err := Pack(img, Layout28, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the quarter was first taken from the top-right quarter of the second tile
+	want := []QuarterConflict{{Quarter: image.Point{X: 2, Y: 0}, Tile: image.Point{X: 1, Y: 0}, Other: last}}
+	if !reflect.DeepEqual(res.Conflicts, want) {
+		t.Errorf("got conflicts %+v, want %+v", res.Conflicts, want)
+	}
+	if area := (&packer{tileWidth: 8, tileHeight: 8}).quarterRect(2, 0); !sameArea(res.Image, area, packSource(), area.Min) {
+		t.Error("the first tile using the quarter has to win")
+	}
+}
+
+func TestPackErrors(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 12*8, 4*6))
+	if _, err := Pack(img, "13x3", 0); err == nil {
+		t.Error("expected an error for an unknown layout")
+	}
+	// rotated 12x4 tiles have to be square
+	if _, err := Pack(img, Layout48Terrain1, 0); err == nil {
+		t.Error("expected an error for non square tiles")
+	}
+	if _, err := Pack(img, Layout28, 3); err == nil {
+		t.Error("expected an error for tiles smaller than 2 px")
+	}
+}
@@ -0,0 +1,153 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"fmt"
+	"image"
+	"maps"
+	"math"
+	"sort"
+)
+
+// SetRandomVariants sets weights of random variants of tiles. The packed tile set holds a pack for every weight
+// side by side, the first one is the base and the others are variants of it, e.g. with different fills.
+// ExportContext generates the layout from every pack and stacks the tile sets below each other in this order,
+// so editors can mix tiles with the same mask randomly by their weights. It has to be called before Init.
+//
+// Parameters:
+// - weights: Positive weights of the packs, a single one or none for a tile set without variants.
+//
+// Returns:
+// - An error wrapping ErrInvalidWeight if a weight is not a positive number.
+func (u *Unpacker) SetRandomVariants(weights []float64) error {
+	for i, w := range weights {
+		if !(w > 0) || math.IsInf(w, 1) {
+			return fmt.Errorf("%w: %v of pack %d", ErrInvalidWeight, w, i)
+		}
+	}
+	u.weights = append([]float64(nil), weights...)
+	return nil
+}
+
+// RandomVariants returns weights of the packs set with SetRandomVariants or nil if there are no variants.
+func (u *Unpacker) RandomVariants() []float64 {
+	if len(u.weights) < 2 {
+		return nil
+	}
+	return append([]float64(nil), u.weights...)
+}
+
+// splitVariants splits the source into packs of equal width, keeps the first one as the source
+// and creates an unpacker with the same settings for every other one.
+func (u *Unpacker) splitVariants() error {
+	size := u.src.Rect.Size()
+	packs := len(u.weights)
+	if size.X%packs != 0 {
+		return fmt.Errorf("%w: %dx%d px can't be split into %d packs", ErrTileSizeNotDivisible, size.X, size.Y, packs)
+	}
+	packWidth := size.X / packs
+	images := make([]*image.NRGBA, packs)
+	for i := range images {
+		images[i] = image.NewNRGBA(image.Rect(0, 0, packWidth, size.Y))
+		packMin := u.src.Rect.Min.Add(image.Point{X: i * packWidth})
+		copyArea(images[i], image.Point{}, u.src, image.Rectangle{Min: packMin, Max: packMin.Add(images[i].Rect.Size())})
+	}
+	for _, pack := range images[1:] {
+		variant := NewUnpacker(pack, u.xTiles, u.yTiles, u.padding)
+		variant.isometric = u.isometric
+		variant.variantModes = maps.Clone(u.variantModes)
+		u.variants = append(u.variants, variant)
+	}
+	u.src = images[0]
+	u.tileWidth = packWidth / u.xTiles
+	return nil
+}
+
+// stackImages returns an image of the images placed below each other.
+// Images are of the same width, as they are tile sets of the same layout.
+func stackImages(images []*image.NRGBA) *image.NRGBA {
+	height := 0
+	for _, img := range images {
+		height += img.Rect.Dy()
+	}
+	res := image.NewNRGBA(image.Rect(0, 0, images[0].Rect.Dx(), height))
+	y := 0
+	for _, img := range images {
+		copyArea(res, image.Point{Y: y}, img, img.Rect)
+		y += img.Rect.Dy()
+	}
+	return res
+}
+
+// VariantChooser picks random variants of tiles of a map by their weights.
+// The choice depends on the seed and the map cell only, so a map looks the same every time it is tiled
+// and editing a cell doesn't reshuffle the others.
+type VariantChooser struct {
+	seed uint64
+	// cumulative holds sums of weights up to every variant.
+	cumulative []float64
+}
+
+// NewVariantChooser creates a chooser of variants with the given weights, e.g. RandomVariants of an unpacker.
+//
+// Parameters:
+// - seed: The seed of the random choice.
+// - weights: Positive weights of the variants.
+//
+// Returns:
+// - A pointer to the chooser.
+// - An error wrapping ErrInvalidWeight if there are no weights or a weight is not a positive number.
+func NewVariantChooser(seed int64, weights []float64) (*VariantChooser, error) {
+	if len(weights) == 0 {
+		return nil, fmt.Errorf("%w: no weights", ErrInvalidWeight)
+	}
+	c := &VariantChooser{seed: uint64(seed)}
+	sum := 0.0
+	for i, w := range weights {
+		if !(w > 0) || math.IsInf(w, 1) {
+			return nil, fmt.Errorf("%w: %v of variant %d", ErrInvalidWeight, w, i)
+		}
+		sum += w
+		c.cumulative = append(c.cumulative, sum)
+	}
+	return c, nil
+}
+
+// Choose returns the index of the variant of the tile at the given map cell.
+func (c *VariantChooser) Choose(x, y int) int {
+	h := splitMix64(c.seed ^ splitMix64(uint64(int64(x))^splitMix64(uint64(int64(y)))))
+	// 53 random bits give a uniform float64 in [0, 1)
+	r := float64(h>>11) / (1 << 53) * c.cumulative[len(c.cumulative)-1]
+	return sort.Search(len(c.cumulative)-1, func(i int) bool { return r < c.cumulative[i] })
+}
+
+// splitMix64 is the finalizer of the SplitMix64 generator, which mixes all bits of the value.
+func splitMix64(v uint64) uint64 {
+	v += 0x9e3779b97f4a7c15
+	v = (v ^ v>>30) * 0xbf58476d1ce4e5b9
+	v = (v ^ v>>27) * 0x94d049bb133111eb
+	return v ^ v>>31
+}
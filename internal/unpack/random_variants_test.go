@@ -0,0 +1,166 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"context"
+	"errors"
+	"image"
+	"math"
+	"testing"
+)
+
+// variantSource returns the golden source followed by a copy with inverted colors.
+func variantSource(t *testing.T) (base, inverted, both *image.NRGBA) {
+	t.Helper()
+	base = asNRGBA(loadImage(t, goldenSource))
+	size := base.Rect.Size()
+	inverted = image.NewNRGBA(image.Rect(0, 0, size.X, size.Y))
+	both = image.NewNRGBA(image.Rect(0, 0, 2*size.X, size.Y))
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			c := base.NRGBAAt(base.Rect.Min.X+x, base.Rect.Min.Y+y)
+			c.R, c.G, c.B = 255-c.R, 255-c.G, 255-c.B
+			inverted.SetNRGBA(x, y, c)
+			both.SetNRGBA(x, y, base.NRGBAAt(base.Rect.Min.X+x, base.Rect.Min.Y+y))
+			both.SetNRGBA(size.X+x, y, c)
+		}
+	}
+	return base, inverted, both
+}
+
+func TestRandomVariants(t *testing.T) {
+	base, inverted, both := variantSource(t)
+	newInit := func(src image.Image, weights []float64) *Unpacker {
+		t.Helper()
+		u := NewUnpacker(src, sixPackXTiles, sixPackYTiles, 1)
+		if err := u.SetRandomVariants(weights); err != nil {
+			t.Fatal(err)
+		}
+		if err := u.Init(sixPackSegments); err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	u := newInit(both, []float64{3, 1})
+	if got := u.RandomVariants(); len(got) != 2 || got[0] != 3 {
+		t.Errorf("got weights %v", got)
+	}
+	baseU, invertedU := newInit(base, nil), newInit(inverted, nil)
+	if baseU.RandomVariants() != nil {
+		t.Error("a single pack has variants")
+	}
+	for _, layout := range ExportLayouts() {
+		lastDone, lastTotal := 0, 0
+		got, err := u.ExportContext(context.Background(), layout, func(done, total int) {
+			if done != lastDone+1 {
+				t.Errorf("%s: progress %d/%d after %d/%d", layout, done, total, lastDone, lastTotal)
+			}
+			lastDone, lastTotal = done, total
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lastDone != lastTotal {
+			t.Errorf("%s: last progress %d/%d", layout, lastDone, lastTotal)
+		}
+		for i, pack := range []*Unpacker{baseU, invertedU} {
+			want, err := pack.ExportContext(context.Background(), layout, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			block := got.SubImage(want.Rect.Add(image.Point{Y: i * want.Rect.Dy()})).(*image.NRGBA)
+			if got.Rect.Dy() != 2*want.Rect.Dy() {
+				t.Fatalf("%s: got %v, want two blocks of %v", layout, got.Rect, want.Rect)
+			}
+			// stacking a single image moves it to the origin like want
+			if _, count := diffImage(want, stackImages([]*image.NRGBA{block})); count != 0 {
+				t.Errorf("%s: %d pixels of pack %d differ", layout, count, i)
+			}
+		}
+	}
+}
+
+func TestRandomVariantsErrors(t *testing.T) {
+	_, _, both := variantSource(t)
+	u := NewUnpacker(both, sixPackXTiles, sixPackYTiles, 0)
+	for _, weights := range [][]float64{{1, 0}, {-1, 1}, {math.NaN(), 1}, {math.Inf(1), 1}} {
+		if err := u.SetRandomVariants(weights); !errors.Is(err, ErrInvalidWeight) {
+			t.Errorf("%v: got %v", weights, err)
+		}
+	}
+	if err := u.SetRandomVariants([]float64{1, 1, 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Init(sixPackSegments); !errors.Is(err, ErrTileSizeNotDivisible) {
+		t.Errorf("3 packs: got %v", err)
+	}
+}
+
+func TestVariantChooser(t *testing.T) {
+	weights := []float64{3, 1}
+	c, err := NewVariantChooser(42, weights)
+	if err != nil {
+		t.Fatal(err)
+	}
+	same, err := NewVariantChooser(42, weights)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewVariantChooser(43, weights)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const size = 100
+	var counts [2]int
+	differ := 0
+	for y := -size / 2; y < size/2; y++ {
+		for x := -size / 2; x < size/2; x++ {
+			v := c.Choose(x, y)
+			counts[v]++
+			if same.Choose(x, y) != v {
+				t.Fatalf("cell %d,%d: the same seed chose another variant", x, y)
+			}
+			if other.Choose(x, y) != v {
+				differ++
+			}
+		}
+	}
+	// 3:1 weights give 7500 of 10000 cells to the first variant, 150 is over 4 standard deviations
+	if math.Abs(float64(counts[0])-size*size*0.75) > 150 {
+		t.Errorf("got %v cells per variant for weights %v", counts, weights)
+	}
+	if differ == 0 {
+		t.Error("another seed chose the same variants")
+	}
+	if single, err := NewVariantChooser(1, []float64{2}); err != nil || single.Choose(3, 4) != 0 {
+		t.Errorf("single variant: %v", err)
+	}
+	for _, weights := range [][]float64{nil, {1, 0}} {
+		if _, err := NewVariantChooser(1, weights); !errors.Is(err, ErrInvalidWeight) {
+			t.Errorf("%v: got %v", weights, err)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+)
+
+// segmentNames names segments of a tile in the order of quadTileData.
+//
+//nolint:gochecknoglobals //static table
+var segmentNames = [4]string{"top-left", "top-right", "bottom-left", "bottom-right"}
+
+// String describes the corners, e.g. "terrain 1 in top-left, top-right corners".
+func (c corners) String() string {
+	var names []string
+	for corner, name := range segmentNames {
+		if c.has(corner) {
+			names = append(names, name)
+		}
+	}
+	switch len(names) {
+	case 0:
+		return "terrain 2 only"
+	case 4:
+		return "terrain 1 only"
+	}
+	return fmt.Sprintf("terrain 1 in %s corners", strings.Join(names, ", "))
+}
+
+// from6to48Source generates a 12x4 tile set drawing every tile from segments of the 2x3 tile set
+// at their original orientation, see VariantsSource. Segments are matched by terrains in their corners
+// taken from TileInfo.TerrainGrid, so tiles don't have to be square.
+//
+// Parameters:
+//
+//	ctx context.Context - the context which cancels generation between tiles
+//	layout string - Layout48Terrain1 or Layout48Terrain2
+//	progress ProgressFunc - the function receiving the number of generated tiles, may be nil
+//
+// Returns:
+//
+//	*image.NRGBA - a pointer to the generated image
+//	error - a *TileError wrapping ErrMissingSegment if a segment required by a tile is transparent in the 2x3 tile set
+func (u *Unpacker) from6to48Source(ctx context.Context, layout string, progress ProgressFunc) (*image.NRGBA, error) {
+	info, err := DescribeLayout(layout)
+	if err != nil {
+		return nil, err
+	}
+	drawn := u.drawnSegments()
+	canvas := image.NewNRGBA(image.Rect(0, 0, u.paddedTileWidth()*info.Cols, u.paddedTileHeight()*info.Rows))
+	for i, tile := range info.Tiles {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("%s: %w", layout, err)
+		}
+		data, err := sourceQuadTileData(tile, drawn)
+		if err != nil {
+			return nil, &TileError{Layout: layout, Tile: tile.Cell, Err: err}
+		}
+		u.drawFullTile(canvas, data, tile.Cell.Y*info.Cols+tile.Cell.X, info.Cols)
+		progress.report(i+1, len(info.Tiles))
+	}
+	return canvas, nil
+}
+
+// drawnSegments reports for every segment of the 2x3 tile set, indexed like sixQuarterCorners,
+// whether it has any pixel which is not fully transparent.
+func (u *Unpacker) drawnSegments() [6][4]bool {
+	var res [6][4]bool
+	segW, segH := u.tileWidth/2, u.tileHeight/2
+	for y := range res {
+		for x := range res[y] {
+			if u.isometric {
+				res[y][x] = u.isoSegmentDrawn([2]int{x, y})
+				continue
+			}
+			anchor := u.anchors[x][y]
+		scan:
+			for py := anchor.Y; py < anchor.Y+segH; py++ {
+				for px := anchor.X; px < anchor.X+segW; px++ {
+					if u.src.Pix[u.src.PixOffset(px, py)+3] != 0 {
+						res[y][x] = true
+						break scan
+					}
+				}
+			}
+		}
+	}
+	return res
+}
+
+// MissingTiles lists tiles of the layout which are drawn from a transparent segment of the 2x3 tile set, like the terrain 2
+// fill of a SourceBlobMin5 strip without its sixth tile. They are the layout counterpart of PackResult.Missing.
+// Init has to be called first.
+//
+// Parameters:
+// - layout: The layout generated from the 2x3 tile set (one of Layout* constants).
+//
+// Returns:
+// - Positions of the tiles in the tile set row by row, nil if every tile is fully drawn.
+// - An error wrapping ErrUnknownLayout if the layout isn't generated from a 2x3 tile set.
+func (u *Unpacker) MissingTiles(layout string) ([]image.Point, error) {
+	sheet, err := newSixPackSheet(layout)
+	if err != nil {
+		return nil, err
+	}
+	drawn := u.drawnSegments()
+	var res []image.Point
+	for i, data := range sheet.patterns {
+		if data == nil {
+			continue
+		}
+		for _, xy := range data {
+			if !drawn[xy[1]][xy[0]] {
+				res = append(res, sheet.cells(i)...)
+				break
+			}
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Y != res[j].Y {
+			return res[i].Y < res[j].Y
+		}
+		return res[i].X < res[j].X
+	})
+	return res, nil
+}
+
+// sourceQuadTileData returns segments of the 2x3 tile set which make up the tile at their original orientation.
+func sourceQuadTileData(tile TileInfo, drawn [6][4]bool) (quadTileData, error) {
+	grid := tile.TerrainGrid()
+	var data [4][2]int
+	for seg := range data {
+		segX, segY := seg%2, seg/2
+		var want corners
+		for corner := 0; corner < 4; corner++ {
+			if grid[segY+corner/2][segX+corner%2] == Terrain1 {
+				want |= 1 << corner
+			}
+		}
+		xy, err := sourceSegment(want, seg, drawn)
+		if err != nil {
+			return nil, err
+		}
+		data[seg] = xy
+	}
+	return &data, nil
+}
+
+// sourceSegment returns quadTileData coordinates of a drawn segment of the 2x3 tile set with the given corners
+// to be drawn as the seg-th segment of a tile. Of several such segments the one at the same position in its tile
+// is preferred.
+func sourceSegment(want corners, seg int, drawn [6][4]bool) ([2]int, error) {
+	segX, segY := seg%2, seg/2
+	var candidates []string
+	for _, samePosition := range []bool{true, false} {
+		for y, row := range sixQuarterCorners {
+			for x, c := range row {
+				if c != want || (x%2 == segX && y%2 == segY) != samePosition {
+					continue
+				}
+				if drawn[y][x] {
+					return [2]int{x, y}, nil
+				}
+				candidates = append(candidates, fmt.Sprintf("%d,%d", x, y))
+			}
+		}
+	}
+	return [2]int{}, fmt.Errorf("%w: %s segment with %s, source segments %s are transparent",
+		ErrMissingSegment, segmentNames[seg], want, strings.Join(candidates, " "))
+}
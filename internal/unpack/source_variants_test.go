@@ -0,0 +1,138 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// segmentIDSource returns a 2x3 tile set of segW by segH pixel segments where every pixel encodes
+// its segment and position in it.
+func segmentIDSource(segW, segH int) *image.NRGBA {
+	src := image.NewNRGBA(image.Rect(0, 0, 4*segW, 6*segH))
+	for y := 0; y < src.Rect.Dy(); y++ {
+		for x := 0; x < src.Rect.Dx(); x++ {
+			src.SetNRGBA(x, y, color.NRGBA{R: uint8(y/segH*4 + x/segW), G: uint8(x % segW), B: uint8(y % segH), A: 255})
+		}
+	}
+	return src
+}
+
+func TestSourceVariantsUseUnturnedSegments(t *testing.T) {
+	// source segments are never turned, so tiles don't have to be square
+	const segW, segH = 4, 3
+	u := NewUnpacker(segmentIDSource(segW, segH), 2, 3, 0)
+	if err := u.Init(2); err != nil {
+		t.Fatal(err)
+	}
+	for _, layout := range []string{Layout48Terrain1, Layout48Terrain2} {
+		if err := u.SetVariantMode(layout, VariantsSource); err != nil {
+			t.Fatal(err)
+		}
+		img, err := u.ExportContext(context.Background(), layout, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", layout, err)
+		}
+		info, err := DescribeLayout(layout)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tile := range info.Tiles {
+			grid := tile.TerrainGrid()
+			for seg := 0; seg < 4; seg++ {
+				minX, minY := tile.Cell.X*2*segW+seg%2*segW, tile.Cell.Y*2*segH+seg/2*segH
+				id := img.NRGBAAt(minX, minY).R
+				var want corners
+				for corner := 0; corner < 4; corner++ {
+					if grid[seg/2+corner/2][seg%2+corner%2] == Terrain1 {
+						want |= 1 << corner
+					}
+				}
+				if got := sixQuarterCorners[id/4][id%4]; got != want {
+					t.Errorf("%s tile %v %s segment: source segment %d,%d has corners %04b, want %04b",
+						layout, tile.Cell, segmentNames[seg], id%4, id/4, got, want)
+				}
+				for y := 0; y < segH; y++ {
+					for x := 0; x < segW; x++ {
+						wantPix := color.NRGBA{R: id, G: uint8(x), B: uint8(y), A: 255}
+						if got := img.NRGBAAt(minX+x, minY+y); got != wantPix {
+							t.Fatalf("%s tile %v %s segment pixel %d,%d: got %v, want %v",
+								layout, tile.Cell, segmentNames[seg], x, y, got, wantPix)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestSourceVariantsMissingSegment(t *testing.T) {
+	const segSize = 4
+	clear := func(src *image.NRGBA, x, y int) {
+		for py := y * segSize; py < (y+1)*segSize; py++ {
+			for px := x * segSize; px < (x+1)*segSize; px++ {
+				src.SetNRGBA(px, py, color.NRGBA{})
+			}
+		}
+	}
+	src := segmentIDSource(segSize, segSize)
+	// the left edge segment 0,3 has a copy at 0,4
+	clear(src, 0, 3)
+	u := NewUnpacker(src, 2, 3, 0)
+	if err := u.Init(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.SetVariantMode(Layout48Terrain1, VariantsSource); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.ExportContext(context.Background(), Layout48Terrain1, nil); err != nil {
+		t.Fatalf("segment with a drawn copy: %v", err)
+	}
+
+	// the inner corner segment 3,0 is the only one with terrain 2 in the top-right corner
+	clear(src, 3, 0)
+	_, err := u.ExportContext(context.Background(), Layout48Terrain1, nil)
+	var tileErr *TileError
+	if !errors.As(err, &tileErr) || !errors.Is(err, ErrMissingSegment) {
+		t.Fatalf("got %v, want a tile error of a missing segment", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "source segments 3,0 are transparent") ||
+		!strings.Contains(msg, "terrain 1 in top-left, bottom-left, bottom-right corners") {
+		t.Errorf("error %q doesn't name the segment", msg)
+	}
+
+	// rotated variants don't need the segment
+	if err := u.SetVariantMode(Layout48Terrain1, VariantsRotate); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.ExportContext(context.Background(), Layout48Terrain1, nil); err != nil {
+		t.Errorf("rotated variants: %v", err)
+	}
+}
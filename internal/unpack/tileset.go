@@ -25,6 +25,7 @@
 package unpack
 
 import (
+	"context"
 	"image"
 	"image/draw"
 )
@@ -83,21 +84,87 @@ func (t *tileSet) setTile(x, y int, tile *image.NRGBA) {
 // The tile is drawn at its rotated orientation.
 //
 // Parameters:
+// - ctx: The context which cancels the rotation.
 // - x: The x-coordinate on the canvas where the tile will be placed.
 // - y: The y-coordinate on the canvas where the tile will be placed.
 // - tile: The image.NRGBA tile to be drawn onto the canvas.
 //
 // Returns:
 // - A pointer to the rotated image.NRGBA tile.
-func (t *tileSet) setTileWithRotationLeft(x, y int, tile *image.NRGBA) *image.NRGBA {
+// - The context error if the context is done.
+func (t *tileSet) setTileWithRotationLeft(ctx context.Context, x, y int, tile *image.NRGBA) (*image.NRGBA, error) {
 	// Rotate the tile 90 degrees to the left.
-	rotatedTile := rotateLeft90(tile)
+	rotatedTile, err := rotateLeft90(ctx, tile)
+	if err != nil {
+		return nil, err
+	}
 
 	// Draw the rotated tile onto the canvas at the specified coordinates.
 	t.setTile(x, y, rotatedTile)
 
 	// Return the rotated tile.
-	return rotatedTile
+	return rotatedTile, nil
+}
+
+// setTileWithRotation draws the given tile onto the canvas at the specified coordinates (x, y)
+// rotated the given number of times 90 degrees to the left, e.g. 2 for 180 and 3 for 270 degrees.
+//
+// Parameters:
+// - ctx: The context which cancels the rotation.
+// - x: The x-coordinate on the canvas where the tile will be placed.
+// - y: The y-coordinate on the canvas where the tile will be placed.
+// - tile: The image.NRGBA tile to be drawn onto the canvas.
+// - turns: The number of rotations.
+//
+// Returns:
+// - A pointer to the rotated image.NRGBA tile.
+// - The context error if the context is done.
+func (t *tileSet) setTileWithRotation(ctx context.Context, x, y int, tile *image.NRGBA, turns int) (*image.NRGBA, error) {
+	rotatedTile, err := rotateLeft(ctx, tile, turns)
+	if err != nil {
+		return nil, err
+	}
+	t.setTile(x, y, rotatedTile)
+	return rotatedTile, nil
+}
+
+// setTileWithFlip draws the given tile onto the canvas at the specified coordinates (x, y) mirrored.
+//
+// Parameters:
+// - x: The x-coordinate on the canvas where the tile will be placed.
+// - y: The y-coordinate on the canvas where the tile will be placed.
+// - tile: The image.NRGBA tile to be drawn onto the canvas.
+// - f: The mirroring of the tile.
+//
+// Returns:
+// - A pointer to the mirrored image.NRGBA tile.
+func (t *tileSet) setTileWithFlip(x, y int, tile *image.NRGBA, f flip) *image.NRGBA {
+	flippedTile := f.apply(tile)
+	t.setTile(x, y, flippedTile)
+	return flippedTile
+}
+
+// getTile returns a copy of the tile at the specified coordinates (x, y).
+//
+// Parameters:
+// - x: The x-coordinate of the tile on the canvas.
+// - y: The y-coordinate of the tile on the canvas.
+//
+// Returns:
+// - A pointer to a new image.NRGBA holding the tile.
+func (t *tileSet) getTile(x, y int) *image.NRGBA {
+	tile := image.NewNRGBA(image.Rect(0, 0, t.tileWidth, t.tileHeight))
+	draw.Draw(
+		tile,
+		tile.Bounds(),
+		t.canvas,
+		image.Point{
+			X: x * t.tileWidth,
+			Y: y * t.tileHeight,
+		},
+		draw.Src,
+	)
+	return tile
 }
 
 func (t *tileSet) getCanvas() *image.NRGBA {
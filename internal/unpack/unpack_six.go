@@ -25,11 +25,15 @@
 package unpack
 
 import (
+	"context"
+	"fmt"
 	"image"
 )
 
 const (
-	sixPackType = 6
+	sixPackXTiles   = 2
+	sixPackYTiles   = 3
+	sixPackSegments = 2
 )
 
 // From6to16Terrain1 generates a 16x1 tileset image from a 2x3 tileset using terrain 1 pattern.
@@ -44,12 +48,7 @@ const (
 //	*image.NRGBA - a pointer to the generated image
 //	error - an error if the pack type is invalid
 func (u *Unpacker) From6to16Terrain1() (*image.NRGBA, error) {
-	exp := export6to28TileSet()
-
-	// Create the quadMap for terrain 1 pattern
-	// Append specific tiles to create the terrain 1 pattern
-	quadMap := append(exp[:14], exp[15], exp[14]) // 15 - filled terrain 2, 14 - small terrain 1 patch on top
-	return u.from6to16Terrain(quadMap)
+	return u.from6to16Terrain(context.Background(), Layout16Terrain1, export6to16Terrain1TileSet(), 16, nil)
 }
 
 // From6to16Terrain2 generates a 16x1 image from a 2x3 tileset using terrain 2 pattern.
@@ -64,14 +63,8 @@ func (u *Unpacker) From6to16Terrain1() (*image.NRGBA, error) {
 //	*image.NRGBA - a pointer to the generated image
 //	error - an error if the pack type is invalid
 func (u *Unpacker) From6to16Terrain2() (*image.NRGBA, error) {
-	exp := export6to28TileSet()
-
-	// Create the quadMap for terrain 2 pattern
-	// Append specific tiles to create the terrain 2 pattern
-	quadMap := append(exp[14:], exp[1], exp[0]) // 0 - filled terrain 1, 1 - small terrain 2 patch on top
-
 	// Generate the 16x1 image using the terrain 2 pattern
-	return u.from6to16Terrain(quadMap)
+	return u.from6to16Terrain(context.Background(), Layout16Terrain2, export6to16Terrain2TileSet(), 16, nil)
 }
 
 // From6to28 generates a 14x2 canvas with 28 tiles from a 2x3 tileset.
@@ -86,8 +79,14 @@ func (u *Unpacker) From6to16Terrain2() (*image.NRGBA, error) {
 //	*image.NRGBA - a pointer to the generated image
 //	error - an error if the pack type is invalid
 func (u *Unpacker) From6to28() (*image.NRGBA, error) {
-	if u.xTiles*u.yTiles != sixPackType {
-		return nil, errInvalidPackType
+	return u.from6to28(context.Background(), nil)
+}
+
+// from6to28 is From6to28 which can be cancelled with the context between tiles
+// and reports the number of generated tiles to progress.
+func (u *Unpacker) from6to28(ctx context.Context, progress ProgressFunc) (*image.NRGBA, error) {
+	if err := u.checkPackType(sixPackXTiles, sixPackYTiles, sixPackSegments); err != nil {
+		return nil, fmt.Errorf("%s: %w", Layout28, err)
 	}
 	canvas := image.NewNRGBA(image.Rect(0, 0, u.paddedTileWidth()*14, u.paddedTileHeight()*2))
 	// todo optimize to generate automatically and consider scaling for 47 and 255 tilesets
@@ -95,7 +94,11 @@ func (u *Unpacker) From6to28() (*image.NRGBA, error) {
 	quadMap := export6to28TileSet()
 
 	for idx := 0; idx < 28; idx++ {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("%s: %w", Layout28, err)
+		}
 		u.drawFullTile(canvas, quadMap[idx], idx, 14)
+		progress.report(idx+1, 28)
 	}
 	return canvas, nil
 }
@@ -112,42 +115,8 @@ func (u *Unpacker) From6to28() (*image.NRGBA, error) {
 //	*image.NRGBA - a pointer to the generated image
 //	error - an error if the pack type is invalid
 func (u *Unpacker) From6to48Terrain1() (*image.NRGBA, error) {
-	quadMap := export6to28TileSet()
-	quads := [16]quadTileData{
-		quadMap[1],
-		quadMap[4],
-		&[4][2]int{
-			{3, 1}, {2, 1},
-			{3, 0}, {2, 0},
-		},
-		quadMap[2],
-		quadMap[5],
-		quadMap[9],
-		&[4][2]int{
-			{0, 0}, {0, 5},
-			{3, 2}, {0, 2},
-		},
-		quadMap[13],
-		&[4][2]int{
-			{1, 5}, {2, 5},
-			{3, 2}, {1, 1},
-		},
-		&[4][2]int{
-			{1, 5}, {2, 5},
-			{0, 1}, {0, 2},
-		},
-		quadMap[3],
-		&[4][2]int{
-			{3, 5}, {0, 5},
-			{0, 1}, {1, 1},
-		},
-		quadMap[8],
-		quadMap[0],
-		quadMap[14],
-		quadMap[12],
-	}
-
-	return u.from6to48Terrain(quads)
+	return u.from6to48Terrain(
+		context.Background(), Layout48Terrain1, export6to48Terrain1TileSet(), u.variantMode(Layout48Terrain1), nil)
 }
 
 // From6to48Terrain2 generates a 12x4 tile set image from a 2x3 tile set using terrain 2 pattern.
@@ -162,190 +131,144 @@ func (u *Unpacker) From6to48Terrain1() (*image.NRGBA, error) {
 //	*image.NRGBA - a pointer to the generated image
 //	error - an error if the pack type is invalid
 func (u *Unpacker) From6to48Terrain2() (*image.NRGBA, error) {
-	quadMap := export6to28TileSet()
-	offset := 14 // terrain 2 starts from 14th index, so we need to offset it
-	quads := [16]quadTileData{
-		quadMap[offset+1],
-		quadMap[offset+4],
-		&[4][2]int{
-			{0, 2}, {3, 2},
-			{0, 5}, {3, 5},
-		},
-		quadMap[offset+2],
-		quadMap[offset+5],
-		quadMap[offset+9],
-		// 2nd 16
-		&[4][2]int{
-			{1, 3}, {3, 0},
-			{2, 1}, {3, 1},
-		},
-		quadMap[offset+13],
-		&[4][2]int{
-			{1, 2}, {2, 2},
-			{2, 1}, {2, 4},
-		},
-		&[4][2]int{
-			{1, 2}, {2, 2},
-			{1, 4}, {3, 1},
-		},
-		// 3rd 16
-		quadMap[offset+3],
-		&[4][2]int{
-			{2, 0}, {3, 0},
-			{1, 4}, {2, 4},
-		},
-		quadMap[offset+8],
-		quadMap[offset],
-		quadMap[0], // terrain 1
-		quadMap[offset+12],
-	}
-
-	return u.from6to48Terrain(quads)
+	return u.from6to48Terrain(
+		context.Background(), Layout48Terrain2, export6to48Terrain2TileSet(), u.variantMode(Layout48Terrain2), nil)
 }
 
-// from6to16Terrain generates a 16x1 image from a 6x6 tileset using the provided quadMap.
+// from6to16Terrain generates an image of 16 tiles from a 6x6 tileset using the provided quadMap.
 // It draws the 16 tiles on the canvas using the quadMap to determine the tile pattern for each tile.
+// Tiles are placed row by row in the given number of columns, 16 for a 16x1 image and 4 for a 4x4 one.
 //
 // Parameters:
 //
+//	ctx - the context which cancels generation between tiles
+//	layout - the name of the generated layout used in errors
 //	quadMap - a slice of quadTileData representing the tile patterns for each tile
+//	cols - the number of columns of the image, a divisor of 16
+//	progress - the function receiving the number of generated tiles, may be nil
 //
 // Returns:
 //
 //	*image.NRGBA - a pointer to the generated image
 //	error - an error if the pack type is invalid
-func (u *Unpacker) from6to16Terrain(quadMap []quadTileData) (*image.NRGBA, error) {
-	if u.xTiles*u.yTiles != sixPackType {
-		return nil, errInvalidPackType
+func (u *Unpacker) from6to16Terrain(
+	ctx context.Context, layout string, quadMap []quadTileData, cols int, progress ProgressFunc,
+) (*image.NRGBA, error) {
+	if err := u.checkPackType(sixPackXTiles, sixPackYTiles, sixPackSegments); err != nil {
+		return nil, fmt.Errorf("%s: %w", layout, err)
 	}
-	canvas := image.NewNRGBA(image.Rect(0, 0, u.paddedTileWidth()*16, u.paddedTileHeight()*1))
+	canvas := image.NewNRGBA(image.Rect(0, 0, u.paddedTileWidth()*cols, u.paddedTileHeight()*(16/cols)))
 	// todo optimize to generate automatically and consider scaling for 47 and 255 tilesets
 
 	for idx := 0; idx < 16; idx++ {
-		u.drawFullTile(canvas, quadMap[idx], idx, 16)
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("%s: %w", layout, err)
+		}
+		u.drawFullTile(canvas, quadMap[idx], idx, cols)
+		progress.report(idx+1, 16)
 	}
 	return canvas, nil
 }
 
+// from6to48Placements lists where on the 12x4 tile set each of the 16 tile patterns passed to from6to48Terrain
+// is placed. The n-th point of every entry is the position of the pattern rotated n times 90 degrees to the left.
+//
+//nolint:gochecknoglobals //static layout table
+var from6to48Placements = [16][]image.Point{
+	{{0, 2}, {3, 3}, {0, 0}, {1, 3}},
+	{{0, 1}, {2, 3}},
+	{{0, 3}},
+	{{1, 2}, {3, 2}, {3, 0}, {1, 0}},
+	{{1, 1}, {2, 2}, {3, 1}, {2, 0}},
+	{{2, 1}},
+	// 2nd 16
+	{{4, 0}, {4, 3}, {7, 3}, {7, 0}},
+	{{5, 1}, {5, 2}, {6, 2}, {6, 1}},
+	{{5, 0}, {4, 2}, {6, 3}, {7, 1}},
+	{{6, 0}, {4, 1}, {5, 3}, {7, 2}},
+	// 3rd 16
+	{{8, 3}, {11, 3}, {11, 0}, {8, 0}},
+	{{9, 0}, {8, 2}, {10, 3}, {11, 2}},
+	{{8, 1}, {9, 3}, {11, 1}, {10, 0}},
+	{{10, 1}},
+	{{9, 2}},
+	{{9, 1}, {10, 2}},
+}
+
 // from6to48Terrain generates a 12x4 tile set from a 2x3 tile set.
+// Every tile pattern is drawn once and then placed according to from6to48Placements.
+// With VariantsRotate the pattern is rotated 90 degrees to the left before every subsequent placement,
+// with VariantsFlip it is mirrored if mirroring gives the shape of the placement and rotated otherwise.
+// With VariantsSource and for isometric tiles every tile is assembled from unturned segments by from6to48Source.
 //
 // Parameters:
 //
+//	ctx context.Context - the context which cancels generation between tiles
+//	layout string - the name of the generated layout used in errors
 //	quadMap [16]quadTileData - an array of quadTileData representing the tile patterns for each new tile produced
 //	from the original 2x3 tile set required to build 12x4 tile set
+//	mode VariantMode - how orientations of tile patterns are generated
+//	progress ProgressFunc - the function receiving the number of placed tiles, may be nil
 //
 // Returns:
 //
 //	*image.NRGBA - a pointer to a new image.NRGBA representing the 12x4 tile set built from the original 2x3 tile set
 //	error - an error if any occurred during the process
-func (u *Unpacker) from6to48Terrain(quadMap [16]quadTileData) (*image.NRGBA, error) {
-	if u.xTiles*u.yTiles != sixPackType {
-		return nil, errInvalidPackType
+func (u *Unpacker) from6to48Terrain(
+	ctx context.Context, layout string, quadMap [16]quadTileData, mode VariantMode, progress ProgressFunc,
+) (*image.NRGBA, error) {
+	if err := u.checkPackType(sixPackXTiles, sixPackYTiles, sixPackSegments); err != nil {
+		return nil, fmt.Errorf("%s: %w", layout, err)
+	}
+	if mode == VariantsSource || u.isometric {
+		return u.from6to48Source(ctx, layout, progress)
+	}
+	if u.tileWidth != u.tileHeight {
+		// tiles are rotated, so they have to be square
+		return nil, fmt.Errorf("%s: %w: %dx%d px tiles are not square", layout, ErrInvalidTileSize, u.tileWidth, u.tileHeight)
+	}
+	blobLayout, err := NewBlobLayout(BlobLayout12x4)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", layout, err)
 	}
 
-	// todo pass list of tile patterns so it would be reusing code for terrain 2
 	canvas := image.NewNRGBA(image.Rect(0, 0, u.paddedTileWidth()*12, u.paddedTileHeight()*4))
 	tileset := newTileSet(canvas, u.paddedTileWidth(), u.paddedTileHeight())
 
-	tilePattern := quadMap[0]
-	tile := image.NewNRGBA(image.Rect(0, 0, u.paddedTileWidth(), u.paddedTileHeight()))
-	// fully drawn
-	u.drawFullSingleTile(tile, tilePattern)
-	tileset.setTile(0, 2, tile)
-	tile = tileset.setTileWithRotationLeft(3, 3, tile)
-	tile = tileset.setTileWithRotationLeft(0, 0, tile)
-	tile = tileset.setTileWithRotationLeft(1, 3, tile)
-
-	tilePattern = quadMap[1]
-	u.drawFullSingleTile(tile, tilePattern)
-	tileset.setTile(0, 1, tile)
-	tileset.setTileWithRotationLeft(2, 3, tile)
-
-	tilePattern = quadMap[2]
-	u.drawFullSingleTile(tile, tilePattern)
-	tileset.setTile(0, 3, tile)
-
-	tilePattern = quadMap[3]
-	u.drawFullSingleTile(tile, tilePattern)
-	tileset.setTile(1, 2, tile)
-	tile = tileset.setTileWithRotationLeft(3, 2, tile)
-	tile = tileset.setTileWithRotationLeft(3, 0, tile)
-	tile = tileset.setTileWithRotationLeft(1, 0, tile)
-
-	tilePattern = quadMap[4]
-	u.drawFullSingleTile(tile, tilePattern)
-	tileset.setTile(1, 1, tile)
-	tile = tileset.setTileWithRotationLeft(2, 2, tile)
-	tile = tileset.setTileWithRotationLeft(3, 1, tile)
-	tile = tileset.setTileWithRotationLeft(2, 0, tile)
-
-	tilePattern = quadMap[5]
-	u.drawFullSingleTile(tile, tilePattern)
-	tileset.setTile(2, 1, tile)
-
-	// 2nd 16
-	tilePattern = quadMap[6]
-	u.drawFullSingleTile(tile, tilePattern)
-	tileset.setTile(4, 0, tile)
-	tile = tileset.setTileWithRotationLeft(4, 3, tile)
-	tile = tileset.setTileWithRotationLeft(7, 3, tile)
-	tile = tileset.setTileWithRotationLeft(7, 0, tile)
-
-	tilePattern = quadMap[7]
-	u.drawFullSingleTile(tile, tilePattern)
-	tileset.setTile(5, 1, tile)
-	tile = tileset.setTileWithRotationLeft(5, 2, tile)
-	tile = tileset.setTileWithRotationLeft(6, 2, tile)
-	tile = tileset.setTileWithRotationLeft(6, 1, tile)
-
-	tilePattern = quadMap[8]
-	u.drawFullSingleTile(tile, tilePattern)
-	tileset.setTile(5, 0, tile)
-	tile = tileset.setTileWithRotationLeft(4, 2, tile)
-	tile = tileset.setTileWithRotationLeft(6, 3, tile)
-	tile = tileset.setTileWithRotationLeft(7, 1, tile)
-
-	tilePattern = quadMap[9]
-	u.drawFullSingleTile(tile, tilePattern)
-	tileset.setTile(6, 0, tile)
-	tile = tileset.setTileWithRotationLeft(4, 1, tile)
-	tile = tileset.setTileWithRotationLeft(5, 3, tile)
-	tile = tileset.setTileWithRotationLeft(7, 2, tile)
-
-	// 3rd 16
-	tilePattern = quadMap[10]
-	u.drawFullSingleTile(tile, tilePattern)
-	tileset.setTile(8, 3, tile)
-	tile = tileset.setTileWithRotationLeft(11, 3, tile)
-	tile = tileset.setTileWithRotationLeft(11, 0, tile)
-	tile = tileset.setTileWithRotationLeft(8, 0, tile)
-
-	tilePattern = quadMap[11]
-	u.drawFullSingleTile(tile, tilePattern)
-	tileset.setTile(9, 0, tile)
-	tile = tileset.setTileWithRotationLeft(8, 2, tile)
-	tile = tileset.setTileWithRotationLeft(10, 3, tile)
-	tile = tileset.setTileWithRotationLeft(11, 2, tile)
-
-	tilePattern = quadMap[12]
-	u.drawFullSingleTile(tile, tilePattern)
-	tileset.setTile(8, 1, tile)
-	tile = tileset.setTileWithRotationLeft(9, 3, tile)
-	tile = tileset.setTileWithRotationLeft(11, 1, tile)
-	tile = tileset.setTileWithRotationLeft(10, 0, tile)
-
-	tilePattern = quadMap[13]
-	u.drawFullSingleTile(tile, tilePattern)
-	tileset.setTile(10, 1, tile)
-
-	tilePattern = quadMap[14]
-	u.drawFullSingleTile(tile, tilePattern)
-	tileset.setTile(9, 2, tile)
-
-	tilePattern = quadMap[15]
-	u.drawFullSingleTile(tile, tilePattern)
-	tileset.setTile(9, 1, tile)
-	_ = tileset.setTileWithRotationLeft(10, 2, tile)
+	total := 0
+	for _, cells := range from6to48Placements {
+		total += len(cells)
+	}
+	done := 0
+	for i, tilePattern := range quadMap {
+		pattern := image.NewNRGBA(image.Rect(0, 0, u.paddedTileWidth(), u.paddedTileHeight()))
+		u.drawFullSingleTile(pattern, tilePattern)
+		tile := pattern
+		mask, _ := blobLayout.Mask(from6to48Placements[i][0].X, from6to48Placements[i][0].Y)
+		for rotation, cell := range from6to48Placements[i] {
+			if err := ctx.Err(); err != nil {
+				return nil, fmt.Errorf("%s: %w", layout, err)
+			}
+			var err error
+			switch {
+			case rotation == 0:
+				tileset.setTile(cell.X, cell.Y, tile)
+			case mode == VariantsFlip:
+				if f, ok := variantFlip(mask, rotation); ok {
+					tileset.setTileWithFlip(cell.X, cell.Y, pattern, f)
+				} else {
+					_, err = tileset.setTileWithRotation(ctx, cell.X, cell.Y, pattern, rotation)
+				}
+			default:
+				tile, err = tileset.setTileWithRotationLeft(ctx, cell.X, cell.Y, tile)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", layout, err)
+			}
+			done++
+			progress.report(done, total)
+		}
+	}
 
 	return tileset.getCanvas(), nil
 }
@@ -476,3 +399,120 @@ func export6to28TileSet() [28]quadTileData {
 		},
 	}
 }
+
+// export6to16Terrain1TileSet generates a quad from a 2x3 tile set required to build 16x1 tile set
+// for terrain 1 pattern. It reuses the terrain 1 part of export6to28TileSet and appends filled terrain 2
+// and small terrain 1 patch on top.
+//
+// Returns:
+//
+//	[]quadTileData - a slice of 16 quadTileData representing the tile patterns for 16x1 tile set
+func export6to16Terrain1TileSet() []quadTileData {
+	exp := export6to28TileSet()
+
+	// Append specific tiles to create the terrain 1 pattern
+	return append(exp[:14], exp[15], exp[14]) // 15 - filled terrain 2, 14 - small terrain 1 patch on top
+}
+
+// export6to16Terrain2TileSet generates a quad from a 2x3 tile set required to build 16x1 tile set
+// for terrain 2 pattern. It reuses the terrain 2 part of export6to28TileSet and appends filled terrain 1
+// and small terrain 2 patch on top.
+//
+// Returns:
+//
+//	[]quadTileData - a slice of 16 quadTileData representing the tile patterns for 16x1 tile set
+func export6to16Terrain2TileSet() []quadTileData {
+	exp := export6to28TileSet()
+
+	// Append specific tiles to create the terrain 2 pattern
+	return append(exp[14:], exp[1], exp[0]) // 0 - filled terrain 1, 1 - small terrain 2 patch on top
+}
+
+// export6to48Terrain1TileSet generates a quad from a 2x3 tile set required to build 12x4 tile set
+// for terrain 1 pattern. Every pattern is placed on the tile set according to from6to48Placements.
+//
+// Returns:
+//
+//	[16]quadTileData - an array of quadTileData representing the tile patterns for 12x4 tile set
+func export6to48Terrain1TileSet() [16]quadTileData {
+	quadMap := export6to28TileSet()
+	return [16]quadTileData{
+		quadMap[1],
+		quadMap[4],
+		&[4][2]int{
+			{3, 1}, {2, 1},
+			{3, 0}, {2, 0},
+		},
+		quadMap[2],
+		quadMap[5],
+		quadMap[9],
+		&[4][2]int{
+			{0, 0}, {0, 5},
+			{3, 2}, {0, 2},
+		},
+		quadMap[13],
+		&[4][2]int{
+			{1, 5}, {2, 5},
+			{3, 2}, {1, 1},
+		},
+		&[4][2]int{
+			{1, 5}, {2, 5},
+			{0, 1}, {0, 2},
+		},
+		quadMap[3],
+		&[4][2]int{
+			{3, 5}, {0, 5},
+			{0, 1}, {1, 1},
+		},
+		quadMap[8],
+		quadMap[0],
+		quadMap[14],
+		quadMap[12],
+	}
+}
+
+// export6to48Terrain2TileSet generates a quad from a 2x3 tile set required to build 12x4 tile set
+// for terrain 2 pattern. Every pattern is placed on the tile set according to from6to48Placements.
+//
+// Returns:
+//
+//	[16]quadTileData - an array of quadTileData representing the tile patterns for 12x4 tile set
+func export6to48Terrain2TileSet() [16]quadTileData {
+	quadMap := export6to28TileSet()
+	offset := 14 // terrain 2 starts from 14th index, so we need to offset it
+	return [16]quadTileData{
+		quadMap[offset+1],
+		quadMap[offset+4],
+		&[4][2]int{
+			{0, 2}, {3, 2},
+			{0, 5}, {3, 5},
+		},
+		quadMap[offset+2],
+		quadMap[offset+5],
+		quadMap[offset+9],
+		// 2nd 16
+		&[4][2]int{
+			{1, 3}, {3, 0},
+			{2, 1}, {3, 1},
+		},
+		quadMap[offset+13],
+		&[4][2]int{
+			{1, 2}, {2, 2},
+			{2, 1}, {2, 4},
+		},
+		&[4][2]int{
+			{1, 2}, {2, 2},
+			{1, 4}, {3, 1},
+		},
+		// 3rd 16
+		quadMap[offset+3],
+		&[4][2]int{
+			{2, 0}, {3, 0},
+			{1, 4}, {2, 4},
+		},
+		quadMap[offset+8],
+		quadMap[offset],
+		quadMap[0], // terrain 1
+		quadMap[offset+12],
+	}
+}
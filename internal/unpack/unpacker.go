@@ -25,19 +25,14 @@
 package unpack
 
 import (
-	"errors"
 	"fmt"
 	"image"
-	"image/draw"
 	"image/png"
+	"io"
 	"os"
 	"time"
 )
 
-var (
-	errInvalidPackType = errors.New("invalid pack type")
-)
-
 // anchorSet represents a set of anchor points for a tile set.
 // The anchor point is used to determine the starting point for drawing a tile on the canvas.
 // The image will be inserted down and right from the anchor point.
@@ -59,25 +54,55 @@ type quadTileData *[4][2]int
 type Unpacker struct {
 	anchors               anchorSet
 	tileWidth, tileHeight int
-	src                   image.Image
+	src                   *image.NRGBA
 	xTiles                int
 	yTiles                int
 	padding               int
+	tileSideSegments      int
+	// variantModes holds variant modes of layouts set with SetVariantMode.
+	variantModes map[string]VariantMode
+	// isometric is set by SetIsometric.
+	isometric bool
+	// weights are weights of the packs of the source set by SetRandomVariants.
+	weights []float64
+	// variants are unpackers of the packs after the first one, created by Init.
+	variants []*Unpacker
 }
 
+// NewUnpacker creates an unpacker for the packed tile set of xTiles by yTiles tiles.
+// The source is converted to image.NRGBA once, so tiles are drawn with plain pixel copies
+// regardless of the decoded image type. Arguments are validated by Init.
 func NewUnpacker(src image.Image, xTiles, yTiles, padding int) *Unpacker {
 	// todo auto detect
-	tileWidth := src.Bounds().Dx() / xTiles
-	tileHeight := src.Bounds().Dy() / yTiles
+	u := &Unpacker{
+		xTiles:  xTiles,
+		yTiles:  yTiles,
+		padding: padding,
+	}
+	if src != nil {
+		u.src = asNRGBA(src)
+	}
+	if u.src != nil && xTiles > 0 && yTiles > 0 {
+		u.tileWidth = u.src.Bounds().Dx() / xTiles
+		u.tileHeight = u.src.Bounds().Dy() / yTiles
+	}
+	return u
+}
 
-	return &Unpacker{
-		src:        src,
-		tileWidth:  tileWidth,
-		tileHeight: tileHeight,
-		xTiles:     xTiles,
-		yTiles:     yTiles,
-		padding:    padding,
+// Decode decodes an image in one of the registered formats (PNG is always registered).
+//
+// Parameters:
+// - r: The reader of the encoded image.
+//
+// Returns:
+// - The decoded image.
+// - An error wrapping ErrDecode if the image can't be decoded.
+func Decode(r io.Reader) (image.Image, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecode, err)
 	}
+	return img, nil
 }
 
 // getAnchorPoint calculates the anchor point for a specific tile position and tile side segments.
@@ -98,7 +123,48 @@ func (u *Unpacker) getAnchorPoint(x, y, tileSideSegments int) image.Point {
 	return anchor
 }
 
+// Init validates the unpacker and calculates anchor points of every tile segment.
+// It has to be called before generating any tile set. The source is split into packs of random variants first
+// if SetRandomVariants was called.
+//
+// Parameters:
+// - tileSideSegments: The number of segments in a tile (e.g. 2 for 2x2).
+//
+// Returns:
+// - An error if the number of tiles, segments, padding or the image size are invalid.
 func (u *Unpacker) Init(tileSideSegments int) error {
+	switch {
+	case u.xTiles < 1 || u.yTiles < 1:
+		return fmt.Errorf("%w: %dx%d tiles", ErrInvalidTileCount, u.xTiles, u.yTiles)
+	case tileSideSegments < 1:
+		return fmt.Errorf("%w: %d segments per tile side", ErrInvalidTileCount, tileSideSegments)
+	case u.src == nil:
+		return fmt.Errorf("%w: no image", ErrImageTooSmall)
+	}
+	if len(u.weights) > 1 && u.variants == nil {
+		if err := u.splitVariants(); err != nil {
+			return err
+		}
+	}
+	for i, variant := range u.variants {
+		if err := variant.Init(tileSideSegments); err != nil {
+			return fmt.Errorf("variant %d: %w", i+1, err)
+		}
+	}
+	size := u.src.Bounds().Size()
+	switch {
+	case u.tileWidth < tileSideSegments || u.tileHeight < tileSideSegments:
+		return fmt.Errorf("%w: %dx%d px can't be split into %dx%d tiles of %dx%d segments",
+			ErrImageTooSmall, size.X, size.Y, u.xTiles, u.yTiles, tileSideSegments, tileSideSegments)
+	case size.X%u.xTiles != 0 || size.Y%u.yTiles != 0:
+		return fmt.Errorf("%w: %dx%d px can't be split into %dx%d tiles",
+			ErrTileSizeNotDivisible, size.X, size.Y, u.xTiles, u.yTiles)
+	case u.tileWidth%tileSideSegments != 0 || u.tileHeight%tileSideSegments != 0:
+		return fmt.Errorf("%w: %dx%d px tile can't be split into %dx%d segments",
+			ErrTileSizeNotDivisible, u.tileWidth, u.tileHeight, tileSideSegments, tileSideSegments)
+	case u.padding < 0 || u.padding > u.tileWidth || u.padding > u.tileHeight:
+		return fmt.Errorf("%w: %d px for %dx%d px tile", ErrInvalidPadding, u.padding, u.tileWidth, u.tileHeight)
+	}
 	xCnt := u.xTiles * tileSideSegments
 	yCnt := u.yTiles * tileSideSegments
 	anchors := make([][]image.Point, xCnt)
@@ -110,9 +176,24 @@ func (u *Unpacker) Init(tileSideSegments int) error {
 		}
 	}
 	u.anchors = anchors
+	u.tileSideSegments = tileSideSegments
 	return nil
 }
 
+// checkPackType returns an error if the unpacker is not initialized for the packed tile set
+// of xTiles by yTiles tiles split into tileSideSegments by tileSideSegments segments.
+func (u *Unpacker) checkPackType(xTiles, yTiles, tileSideSegments int) error {
+	if u.anchors == nil {
+		return ErrNotInitialized
+	}
+	if u.xTiles != xTiles || u.yTiles != yTiles || u.tileSideSegments != tileSideSegments {
+		return fmt.Errorf("%w: %dx%d tiles of %dx%d segments, expected %dx%d tiles of %dx%d segments",
+			ErrUnsupportedPackType, u.xTiles, u.yTiles, u.tileSideSegments, u.tileSideSegments,
+			xTiles, yTiles, tileSideSegments, tileSideSegments)
+	}
+	return u.checkIsometric()
+}
+
 // outXTiles is the number of output tiles in x direction
 // tileSideSegments is the number of segments in a tile (e.g. packed tile was segmented. 2 for to 2x2, 3 for 3x3, etc.)
 // drawFullTile draws a full tile on the canvas based on the provided data and index.
@@ -131,35 +212,36 @@ func (u *Unpacker) drawFullTile(canvas *image.NRGBA, data quadTileData, idx, out
 		return
 	}
 	for i, xy := range data {
-		x := xy[0]
-		y := xy[1]
-		line := idx / outXTiles
-		row := idx % outXTiles
-		paddingY := u.padding + line*2*u.padding
-		paddingX := u.padding + row*2*u.padding
-
-		shiftX := i%2*u.tileWidth/2 + paddingX
-		shiftY := i>>1*u.tileHeight/2 + paddingY
-		canvasMin := image.Point{
-			X: row*u.tileWidth + shiftX,
-			Y: line*u.tileHeight + shiftY,
-		}
-		canvasArea := image.Rectangle{
-			Min: canvasMin,
-			Max: image.Point{
-				X: canvasMin.X + u.tileWidth/2,
-				Y: canvasMin.Y + u.tileHeight/2,
-			},
-		}
-		point := u.anchors[x][y]
-		draw.Draw(
-			canvas,
-			canvasArea,
-			u.src,
-			point,
-			draw.Src,
-		)
+		u.drawSegment(canvas, i, xy, idx, outXTiles)
+	}
+}
+
+// drawSegment draws the segment of the source image at the given quadTileData coordinates
+// as the i-th segment of the idx-th tile of the canvas.
+func (u *Unpacker) drawSegment(canvas *image.NRGBA, i int, xy [2]int, idx, outXTiles int) {
+	if u.isometric {
+		u.drawIsoSegment(canvas, i, xy, idx, outXTiles)
+		return
+	}
+	line := idx / outXTiles
+	row := idx % outXTiles
+	paddingY := u.padding + line*2*u.padding
+	paddingX := u.padding + row*2*u.padding
+
+	shiftX := i%2*u.tileWidth/2 + paddingX
+	shiftY := i>>1*u.tileHeight/2 + paddingY
+	canvasMin := image.Point{
+		X: row*u.tileWidth + shiftX,
+		Y: line*u.tileHeight + shiftY,
 	}
+	point := u.anchors[xy[0]][xy[1]]
+	copyArea(canvas, canvasMin, u.src, image.Rectangle{
+		Min: point,
+		Max: image.Point{
+			X: point.X + u.tileWidth/2,
+			Y: point.Y + u.tileHeight/2,
+		},
+	})
 }
 
 // drawFullSingleTile used for rendering a single tile.
@@ -25,30 +25,187 @@
 package unpack
 
 import (
+	"context"
 	"image"
+	"image/draw"
 	"runtime"
 	"sync"
 )
 
+// toNRGBA returns a copy of the given image as image.NRGBA with bounds starting at (0, 0).
+func toNRGBA(img image.Image) *image.NRGBA {
+	res := image.NewNRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(res, res.Bounds(), img, img.Bounds().Min, draw.Src)
+	return res
+}
+
+// asNRGBA returns the given image if it is image.NRGBA with bounds starting at (0, 0) and its copy otherwise.
+// The result must not be modified as it may share pixels with img.
+func asNRGBA(img image.Image) *image.NRGBA {
+	if res, ok := img.(*image.NRGBA); ok && (res == nil || res.Rect.Min == image.Point{}) {
+		return res
+	}
+	return toNRGBA(img)
+}
+
+// copyArea copies the area of src to dst starting at dstMin row by row.
+// Both areas must fit into bounds of their images.
+//
+// Parameters:
+// - dst: The image to copy pixels to.
+// - dstMin: The top-left point of the destination area.
+// - src: The image to copy pixels from.
+// - srcArea: The area of src to copy.
+func copyArea(dst *image.NRGBA, dstMin image.Point, src *image.NRGBA, srcArea image.Rectangle) {
+	rowSize := srcArea.Dx() * 4
+	for y := 0; y < srcArea.Dy(); y++ {
+		dstOffset := dst.PixOffset(dstMin.X, dstMin.Y+y)
+		srcOffset := src.PixOffset(srcArea.Min.X, srcArea.Min.Y+y)
+		copy(dst.Pix[dstOffset:dstOffset+rowSize], src.Pix[srcOffset:srcOffset+rowSize])
+	}
+}
+
+// minParallelRotationPixels is the image size starting from which rotateLeft90 spreads the work across goroutines.
+// Spawning goroutines for every rotation of a smaller tile costs more than it saves.
+const minParallelRotationPixels = 256 * 256
+
 // rotateLeft90 rotates the given image 90 degrees counter-clockwise.
 //
 // Parameters:
+// - ctx: The context which cancels the rotation.
 // - img: The input image to be rotated.
 //
 // Returns:
-// - A new image that is the result of rotating the input image 90 degrees counter-clockwise.
-func rotateLeft90(img *image.NRGBA) *image.NRGBA {
+// - A new image that is the result of rotating the input image 90 degrees counter-clockwise (width and height swapped).
+// - The context error if the context is done.
+func rotateLeft90(ctx context.Context, img *image.NRGBA) (*image.NRGBA, error) {
 	width := img.Bounds().Dx()
 	height := img.Bounds().Dy()
-	rowSize := width * 4
-	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
-	parallel(0, height, func(ys <-chan int) {
+	// every row of the rotated image is a column of the source image
+	rowSize := height * 4
+	dst := image.NewNRGBA(image.Rect(0, 0, height, width))
+	rotateRow := func(dstY int) {
+		i := dstY * dst.Stride
+		srcX := width - dstY - 1
+		scan(img, dst.Pix[i:i+rowSize], srcX, 0, srcX+1, height)
+	}
+	if err := rotateRows(ctx, dst, rotateRow); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// rotateRight90 rotates the given image 90 degrees clockwise, which is 270 degrees counter-clockwise.
+//
+// Parameters:
+// - ctx: The context which cancels the rotation.
+// - img: The input image to be rotated.
+//
+// Returns:
+// - A new image that is the result of rotating the input image 90 degrees clockwise (width and height swapped).
+// - The context error if the context is done.
+func rotateRight90(ctx context.Context, img *image.NRGBA) (*image.NRGBA, error) {
+	width := img.Bounds().Dx()
+	height := img.Bounds().Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, height, width))
+	// every row of the rotated image is a column of the source image read from the bottom
+	rotateRow := func(dstY int) {
+		row := dst.Pix[dstY*dst.Stride:]
+		for dstX := 0; dstX < height; dstX++ {
+			i := img.PixOffset(img.Rect.Min.X+dstY, img.Rect.Max.Y-dstX-1)
+			copy(row[dstX*4:dstX*4+4], img.Pix[i:i+4])
+		}
+	}
+	if err := rotateRows(ctx, dst, rotateRow); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// rotateRows fills every row of the rotated image dst with rotateRow.
+// Rows of images smaller than minParallelRotationPixels are filled sequentially, otherwise concurrently.
+func rotateRows(ctx context.Context, dst *image.NRGBA, rotateRow func(dstY int)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	rows := dst.Rect.Dy()
+	if rows*dst.Rect.Dx() < minParallelRotationPixels {
+		for dstY := 0; dstY < rows; dstY++ {
+			rotateRow(dstY)
+		}
+		return nil
+	}
+	return parallel(ctx, 0, rows, func(ys <-chan int) {
 		for dstY := range ys {
-			i := dstY * dst.Stride
-			srcX := height - dstY - 1
-			scan(img, dst.Pix[i:i+rowSize], srcX, 0, srcX+1, height)
+			rotateRow(dstY)
 		}
 	})
+}
+
+// rotate180 rotates the given image 180 degrees, which is the same as mirroring it both ways.
+//
+// Parameters:
+// - img: The input image to be rotated.
+//
+// Returns:
+// - A new image with rows and columns of the input image in reverse order.
+func rotate180(img *image.NRGBA) *image.NRGBA {
+	return flipVertical(flipHorizontal(img))
+}
+
+// rotateLeft rotates the given image 90 degrees counter-clockwise the given number of times.
+// A single rotation is done for any number of turns, the image itself is returned for full turns.
+func rotateLeft(ctx context.Context, img *image.NRGBA, turns int) (*image.NRGBA, error) {
+	switch (turns%4 + 4) % 4 {
+	case 1:
+		return rotateLeft90(ctx, img)
+	case 2:
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return rotate180(img), nil
+	case 3:
+		return rotateRight90(ctx, img)
+	}
+	return img, nil
+}
+
+// flipHorizontal mirrors the given image left to right.
+//
+// Parameters:
+// - img: The input image to be flipped.
+//
+// Returns:
+// - A new image with columns of the input image in reverse order.
+func flipHorizontal(img *image.NRGBA) *image.NRGBA {
+	width := img.Bounds().Dx()
+	height := img.Bounds().Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		srcRow := img.Pix[img.PixOffset(img.Rect.Min.X, img.Rect.Min.Y+y):]
+		dstRow := dst.Pix[y*dst.Stride:]
+		for x := 0; x < width; x++ {
+			copy(dstRow[x*4:x*4+4], srcRow[(width-x-1)*4:(width-x)*4])
+		}
+	}
+	return dst
+}
+
+// flipVertical mirrors the given image top to bottom.
+//
+// Parameters:
+// - img: The input image to be flipped.
+//
+// Returns:
+// - A new image with rows of the input image in reverse order.
+func flipVertical(img *image.NRGBA) *image.NRGBA {
+	width := img.Bounds().Dx()
+	height := img.Bounds().Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		copyArea(dst, image.Point{Y: height - y - 1}, img, image.Rect(
+			img.Rect.Min.X, img.Rect.Min.Y+y, img.Rect.Max.X, img.Rect.Min.Y+y+1))
+	}
 	return dst
 }
 
@@ -91,19 +248,21 @@ func scan(srcImg *image.NRGBA, dstPx []uint8, x1, y1, x2, y2 int) {
 // parallel is a helper function that executes a given function in parallel across multiple goroutines.
 // It distributes the work by sending indices from the start to stop (exclusive) to a channel,
 // and each goroutine receives an index from the channel and executes the given function.
+// Indices are no longer sent once the context is done.
 // The function waits for all goroutines to finish before returning.
 //
 // Parameters:
+// - ctx: The context which cancels processing of the remaining indices.
 // - start: The starting index for the range of indices to be processed.
 // - stop: The exclusive ending index for the range of indices to be processed.
 // - fn: The function to be executed in parallel. It should accept a channel of integers as its parameter.
 //
 // Returns:
-// - None.
-func parallel(start, stop int, fn func(<-chan int)) {
+// - The context error if the context is done.
+func parallel(ctx context.Context, start, stop int, fn func(<-chan int)) error {
 	count := stop - start
 	if count < 1 {
-		return
+		return ctx.Err()
 	}
 
 	// Determine the number of goroutines to use.
@@ -113,16 +272,8 @@ func parallel(start, stop int, fn func(<-chan int)) {
 		procs = count
 	}
 
-	// Create a buffered channel with a capacity equal to the count.
-	c := make(chan int, count)
-
-	// Fill the channel with indices from start to stop (exclusive).
-	for i := start; i < stop; i++ {
-		c <- i
-	}
-
-	// Close the channel to indicate that no more indices will be sent.
-	close(c)
+	// Create a buffered channel with a capacity equal to the number of goroutines.
+	c := make(chan int, procs)
 
 	var wg sync.WaitGroup
 
@@ -137,6 +288,20 @@ func parallel(start, stop int, fn func(<-chan int)) {
 		}()
 	}
 
+	// Send indices from start to stop (exclusive) until the context is done.
+feed:
+	for i := start; i < stop; i++ {
+		select {
+		case c <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+
+	// Close the channel to indicate that no more indices will be sent.
+	close(c)
+
 	// Wait for all goroutines to finish.
 	wg.Wait()
+	return ctx.Err()
 }
@@ -0,0 +1,268 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"image"
+	"image/color"
+)
+
+// SeamMismatch describes a seam between two tiles which may sit next to each other but whose edges don't match.
+type SeamMismatch struct {
+	// Tile is a position of the left (or the top) tile.
+	Tile image.Point
+	// Other is a position of the right (or the bottom) tile.
+	Other image.Point
+	// Vertical is true if Other is below Tile.
+	Vertical bool
+	// Pixels is the number of mismatching pixels along the seam.
+	Pixels int
+}
+
+// SeamReport is a result of ValidateSeams.
+type SeamReport struct {
+	Mismatches []SeamMismatch
+	// Diff is a copy of the tile set with mismatching edge pixels highlighted.
+	Diff *image.NRGBA
+}
+
+// SeamOptions configures ValidateSeams.
+type SeamOptions struct {
+	// Padding is the padding of every tile in px.
+	Padding int
+	// Tolerance is the maximal difference of any channel of two edge pixels still considered equal.
+	Tolerance uint8
+	// MaxMismatch is the maximal share (0..1) of mismatching pixels along a seam still considered matching.
+	MaxMismatch float64
+}
+
+// DefaultSeamOptions returns SeamOptions good enough for most of the tile sets.
+func DefaultSeamOptions() SeamOptions {
+	return SeamOptions{
+		Tolerance:   64,
+		MaxMismatch: 0.2,
+	}
+}
+
+// seamHighlight is a color used to highlight mismatching pixels on a diff image.
+func seamHighlight() color.NRGBA {
+	return color.NRGBA{R: 255, G: 0, B: 255, A: 255}
+}
+
+// ValidateSeams checks that every pair of tiles of a blob tile set which may sit next to each other
+// according to their masks has matching edges. Edges of a pair are the last column (row) of pixels of the
+// left (top) tile and the first column (row) of the right (bottom) tile.
+// If the layout has a background tile, seams between blob tiles and the background are checked as well.
+//
+// Parameters:
+// - src: The tile set image.
+// - layout: The layout of the tile set.
+// - opts: Validation options.
+//
+// Returns:
+// - A SeamReport with all mismatching seams and a diff image.
+// - An error if the tile set is too small for the layout.
+func ValidateSeams(src image.Image, layout *BlobLayout, opts SeamOptions) (*SeamReport, error) {
+	paddedTileWidth, paddedTileHeight, err := paddedTileSize(src, layout, opts.Padding)
+	if err != nil {
+		return nil, err
+	}
+	v := &seamValidator{
+		src:        toNRGBA(src),
+		opts:       opts,
+		tileWidth:  paddedTileWidth - opts.Padding*2,
+		tileHeight: paddedTileHeight - opts.Padding*2,
+	}
+	v.diff = toNRGBA(v.src)
+
+	horizontal, vertical := compatibleMasks()
+	background, hasBackground := layout.Background()
+	for y := 0; y < layout.Rows; y++ {
+		for x := 0; x < layout.Cols; x++ {
+			m, ok := layout.Mask(x, y)
+			if !ok {
+				continue
+			}
+			tile := image.Point{X: x, Y: y}
+			for _, other := range horizontal[m.Canonical()] {
+				if cell, ok := layout.Cell(other); ok {
+					v.check(tile, cell, false)
+				}
+			}
+			for _, other := range vertical[m.Canonical()] {
+				if cell, ok := layout.Cell(other); ok {
+					v.check(tile, cell, true)
+				}
+			}
+			if !hasBackground {
+				continue
+			}
+			if m&East == 0 {
+				v.check(tile, background, false)
+			}
+			if m&West == 0 {
+				v.check(background, tile, false)
+			}
+			if m&South == 0 {
+				v.check(tile, background, true)
+			}
+			if m&North == 0 {
+				v.check(background, tile, true)
+			}
+		}
+	}
+	return &SeamReport{
+		Mismatches: v.mismatches,
+		Diff:       v.diff,
+	}, nil
+}
+
+// compatibleMasks returns canonical masks of the tiles which may be placed to the right of (horizontal)
+// and below (vertical) a tile with the given canonical mask. Pairs are found by enumerating all possible
+// neighbourhoods of two adjacent blob tiles.
+func compatibleMasks() (horizontal, vertical map[Mask][]Mask) {
+	horizontal = make(map[Mask][]Mask)
+	vertical = make(map[Mask][]Mask)
+	seenH := make(map[[2]Mask]bool)
+	seenV := make(map[[2]Mask]bool)
+	// 10 cells surround a pair of adjacent tiles
+	for n := 0; n < 1<<10; n++ {
+		// horizontal pair at (1, 1) and (2, 1) of 4x3 grid
+		grid := neighbourhood(n, 4, 3, image.Point{X: 1, Y: 1}, image.Point{X: 2, Y: 1})
+		a, b := gridMask(grid, 1, 1), gridMask(grid, 2, 1)
+		if !seenH[[2]Mask{a, b}] {
+			seenH[[2]Mask{a, b}] = true
+			horizontal[a] = append(horizontal[a], b)
+		}
+		// vertical pair at (1, 1) and (1, 2) of 3x4 grid
+		grid = neighbourhood(n, 3, 4, image.Point{X: 1, Y: 1}, image.Point{X: 1, Y: 2})
+		a, b = gridMask(grid, 1, 1), gridMask(grid, 1, 2)
+		if !seenV[[2]Mask{a, b}] {
+			seenV[[2]Mask{a, b}] = true
+			vertical[a] = append(vertical[a], b)
+		}
+	}
+	return horizontal, vertical
+}
+
+// neighbourhood returns a grid of cells where the given pair of cells is set and other cells are set
+// according to the bits of n.
+func neighbourhood(n, cols, rows int, a, b image.Point) [][]bool {
+	grid := make([][]bool, rows)
+	bit := 0
+	for y := range grid {
+		grid[y] = make([]bool, cols)
+		for x := range grid[y] {
+			p := image.Point{X: x, Y: y}
+			if p == a || p == b {
+				grid[y][x] = true
+				continue
+			}
+			grid[y][x] = n&(1<<bit) != 0
+			bit++
+		}
+	}
+	return grid
+}
+
+// gridMask returns the canonical mask of the cell (x, y) of the grid. The cell must not be on the border.
+func gridMask(grid [][]bool, x, y int) Mask {
+	neighbours := [8]image.Point{{0, -1}, {1, -1}, {1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}}
+	var m Mask
+	for i, d := range neighbours {
+		if grid[y+d.Y][x+d.X] {
+			m |= 1 << i
+		}
+	}
+	return m.Canonical()
+}
+
+// seamValidator holds the state of a single ValidateSeams call.
+type seamValidator struct {
+	src, diff             *image.NRGBA
+	opts                  SeamOptions
+	tileWidth, tileHeight int
+	mismatches            []SeamMismatch
+}
+
+// check compares the edges of the given pair of tiles and records a mismatch if they differ.
+func (v *seamValidator) check(tile, other image.Point, vertical bool) {
+	length := v.tileHeight
+	if vertical {
+		length = v.tileWidth
+	}
+	var bad []int
+	for i := 0; i < length; i++ {
+		a, b := v.edgePixels(tile, other, vertical, i)
+		if !v.similar(v.src.NRGBAAt(a.X, a.Y), v.src.NRGBAAt(b.X, b.Y)) {
+			bad = append(bad, i)
+		}
+	}
+	if len(bad) == 0 || float64(len(bad)) <= v.opts.MaxMismatch*float64(length) {
+		return
+	}
+	for _, i := range bad {
+		a, b := v.edgePixels(tile, other, vertical, i)
+		v.diff.SetNRGBA(a.X, a.Y, seamHighlight())
+		v.diff.SetNRGBA(b.X, b.Y, seamHighlight())
+	}
+	v.mismatches = append(v.mismatches, SeamMismatch{
+		Tile:     tile,
+		Other:    other,
+		Vertical: vertical,
+		Pixels:   len(bad),
+	})
+}
+
+// edgePixels returns coordinates of the i-th pixels of the touching edges of the given pair of tiles.
+func (v *seamValidator) edgePixels(tile, other image.Point, vertical bool, i int) (a, b image.Point) {
+	paddedTileWidth := v.tileWidth + v.opts.Padding*2
+	paddedTileHeight := v.tileHeight + v.opts.Padding*2
+	a = image.Point{X: tile.X*paddedTileWidth + v.opts.Padding, Y: tile.Y*paddedTileHeight + v.opts.Padding}
+	b = image.Point{X: other.X*paddedTileWidth + v.opts.Padding, Y: other.Y*paddedTileHeight + v.opts.Padding}
+	if vertical {
+		a = a.Add(image.Point{X: i, Y: v.tileHeight - 1})
+		b = b.Add(image.Point{X: i})
+	} else {
+		a = a.Add(image.Point{X: v.tileWidth - 1, Y: i})
+		b = b.Add(image.Point{Y: i})
+	}
+	return a, b
+}
+
+// similar reports whether all channels of two colors differ by no more than the tolerance.
+func (v *seamValidator) similar(a, b color.NRGBA) bool {
+	return channelDiff(a.R, b.R) <= v.opts.Tolerance &&
+		channelDiff(a.G, b.G) <= v.opts.Tolerance &&
+		channelDiff(a.B, b.B) <= v.opts.Tolerance &&
+		channelDiff(a.A, b.A) <= v.opts.Tolerance
+}
+
+func channelDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
@@ -0,0 +1,110 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+// validatedTileSet returns a 12x4 tile set generated from the golden source with the given padding.
+func validatedTileSet(t *testing.T, padding int) (*image.NRGBA, *BlobLayout) {
+	t.Helper()
+	u := NewUnpacker(loadImage(t, goldenSource), 2, 3, padding)
+	if err := u.Init(2); err != nil {
+		t.Fatal(err)
+	}
+	img, err := u.From6to48Terrain1()
+	if err != nil {
+		t.Fatal(err)
+	}
+	layout, err := NewBlobLayout(BlobLayout12x4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img, layout
+}
+
+func TestValidateSeamsGenerated(t *testing.T) {
+	for _, padding := range []int{0, 1} {
+		img, layout := validatedTileSet(t, padding)
+		report, err := ValidateSeams(img, layout, SeamOptions{Padding: padding, Tolerance: 64, MaxMismatch: 0.2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Mismatches) != 0 {
+			t.Errorf("padding %d: unexpected mismatches %+v", padding, report.Mismatches)
+		}
+		if !reflect.DeepEqual(report.Diff.Pix, img.Pix) {
+			t.Errorf("padding %d: diff of a matching tile set differs from it", padding)
+		}
+	}
+}
+
+func TestValidateSeamsCorruptedEdge(t *testing.T) {
+	for _, padding := range []int{0, 1} {
+		t.Run(fmt.Sprintf("p%d", padding), func(t *testing.T) {
+			img, layout := validatedTileSet(t, padding)
+			// the east side of the tile with the north neighbour only can touch just the background tile
+			tile, ok := layout.Cell(North)
+			if !ok {
+				t.Fatal("no tile for the north mask")
+			}
+			background, _ := layout.Background()
+			tileSize := img.Rect.Dx() / layout.Cols
+			edgeX := (tile.X+1)*tileSize - padding - 1
+			// touching edges of the tiles: the last column of the tile and the first column of the background
+			edge := image.Rect(edgeX, tile.Y*tileSize+padding, edgeX+1, (tile.Y+1)*tileSize-padding)
+			backgroundEdge := image.Rectangle{Max: image.Pt(1, edge.Dy())}.
+				Add(background.Mul(tileSize).Add(image.Pt(padding, padding)))
+			for y := edge.Min.Y; y < edge.Max.Y; y++ {
+				c := img.NRGBAAt(edgeX, y)
+				img.SetNRGBA(edgeX, y, color.NRGBA{R: c.R + 128, G: c.G + 128, B: c.B + 128, A: c.A + 128})
+			}
+
+			report, err := ValidateSeams(img, layout, SeamOptions{Padding: padding, Tolerance: 64, MaxMismatch: 0.2})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []SeamMismatch{{Tile: tile, Other: background, Pixels: edge.Dy()}}
+			if !reflect.DeepEqual(report.Mismatches, want) {
+				t.Fatalf("got mismatches %+v, want %+v", report.Mismatches, want)
+			}
+			for y := report.Diff.Rect.Min.Y; y < report.Diff.Rect.Max.Y; y++ {
+				for x := report.Diff.Rect.Min.X; x < report.Diff.Rect.Max.X; x++ {
+					p := image.Point{X: x, Y: y}
+					highlighted := p.In(edge) || p.In(backgroundEdge)
+					got := report.Diff.NRGBAAt(x, y)
+					if highlighted && got != seamHighlight() || !highlighted && got != img.NRGBAAt(x, y) {
+						t.Fatalf("pixel %v: got %v, highlighted %v", p, got, highlighted)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"fmt"
+	"slices"
+)
+
+// VariantMode selects how tiles which differ only by orientation are generated from a single drawn tile.
+type VariantMode string
+
+const (
+	// VariantsRotate rotates the drawn tile. It is the default mode.
+	VariantsRotate VariantMode = "rotate"
+	// VariantsFlip mirrors the drawn tile where mirroring gives the required shape and rotates it otherwise.
+	// Mirroring keeps horizontal or vertical features of the art in place, e.g. the light coming from the top
+	// stays on the top when a tile is mirrored horizontally, while rotation moves it to the side.
+	VariantsFlip VariantMode = "flip"
+	// VariantsSource never turns the art: every tile is assembled from segments of the packed tile set
+	// at their original orientation, so highlights, shadows and directional textures stay in place.
+	// A tile requiring a segment which is transparent in the packed tile set is reported as an error.
+	VariantsSource VariantMode = "source"
+)
+
+// VariantModes lists all variant modes.
+func VariantModes() []VariantMode {
+	return []VariantMode{VariantsRotate, VariantsFlip, VariantsSource}
+}
+
+// SetVariantMode selects how orientations of tiles of the layout are generated.
+// Only 12x4 layouts place the same tile in several orientations, the mode doesn't affect other layouts.
+// It must not be called while tile sets are generated.
+//
+// Parameters:
+// - layout: One of the layouts listed by ExportLayouts.
+// - mode: One of the modes listed by VariantModes.
+//
+// Returns:
+// - An error wrapping ErrUnknownLayout or ErrUnknownVariantMode.
+func (u *Unpacker) SetVariantMode(layout string, mode VariantMode) error {
+	if !slices.Contains(ExportLayouts(), layout) {
+		return fmt.Errorf("%w: %s", ErrUnknownLayout, layout)
+	}
+	if !slices.Contains(VariantModes(), mode) {
+		return fmt.Errorf("%w: %s", ErrUnknownVariantMode, mode)
+	}
+	if u.variantModes == nil {
+		u.variantModes = make(map[string]VariantMode)
+	}
+	u.variantModes[layout] = mode
+	for _, variant := range u.variants {
+		if err := variant.SetVariantMode(layout, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// variantMode returns the variant mode of the layout.
+func (u *Unpacker) variantMode(layout string) VariantMode {
+	if mode, ok := u.variantModes[layout]; ok {
+		return mode
+	}
+	return VariantsRotate
+}
+
+// variantFlip returns the mirroring which turns the tile with the given mask into the tile rotated
+// the given number of times 90 degrees to the left and false if there is no such mirroring.
+func variantFlip(m Mask, turns int) (flip, bool) {
+	want := m
+	for i := 0; i < turns; i++ {
+		want = want.rotatedLeft()
+	}
+	want = want.Canonical()
+	for _, f := range []flip{flipH, flipV, flipH | flipV} {
+		if m.mirrored(f).Canonical() == want {
+			return f, true
+		}
+	}
+	return flipNone, false
+}
@@ -0,0 +1,143 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package unpack
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestPlacementsAreRotations(t *testing.T) {
+	layout, err := NewBlobLayout(BlobLayout12x4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, cells := range from6to48Placements {
+		if len(cells) == 1 {
+			// the background cell has no mask
+			continue
+		}
+		mask, _ := layout.Mask(cells[0].X, cells[0].Y)
+		for turns, cell := range cells {
+			want, ok := layout.Mask(cell.X, cell.Y)
+			if !ok {
+				t.Fatalf("pattern %d: no tile at %v", i, cell)
+			}
+			if mask.Canonical() != want.Canonical() {
+				t.Errorf("pattern %d rotated %d times: got mask %d, want %d at %v", i, turns, mask.Canonical(), want, cell)
+			}
+			mask = mask.rotatedLeft()
+		}
+	}
+}
+
+func TestMaskMirrored(t *testing.T) {
+	tests := []struct {
+		mask Mask
+		flip flip
+		want Mask
+	}{
+		{North | NorthEast | East, flipH, North | NorthWest | West},
+		{North | NorthEast | East, flipV, South | SouthEast | East},
+		{North | NorthEast | East, flipH | flipV, South | SouthWest | West},
+		{North | South, flipH, North | South},
+		{East, flipNone, East},
+	}
+	for _, tt := range tests {
+		if got := tt.mask.mirrored(tt.flip); got != tt.want {
+			t.Errorf("%d mirrored %d: got %d, want %d", tt.mask, tt.flip, got, tt.want)
+		}
+	}
+}
+
+func TestVariantFlip(t *testing.T) {
+	for m := 0; m < 256; m++ {
+		mask := Mask(m).Canonical()
+		// 180 degrees rotation is always a mirroring both ways
+		if f, ok := variantFlip(mask, 2); !ok || mask.mirrored(f).Canonical() != mask.rotatedLeft().rotatedLeft().Canonical() {
+			t.Errorf("mask %d: no mirroring for 180 degrees rotation", mask)
+		}
+	}
+	// the north edge can't become the west edge by mirroring
+	if f, ok := variantFlip(North, 1); ok {
+		t.Errorf("north edge rotated left: got mirroring %d", f)
+	}
+	// the north-east corner becomes the north-west one
+	if f, ok := variantFlip(North|East, 1); !ok || f != flipH {
+		t.Errorf("north-east corner rotated left: got mirroring %d, %v, want %d", f, ok, flipH)
+	}
+}
+
+func TestRotations(t *testing.T) {
+	for _, size := range []image.Point{{3, 2}, {300, 260}} {
+		img := image.NewNRGBA(image.Rect(0, 0, size.X, size.Y))
+		for y := 0; y < size.Y; y++ {
+			for x := 0; x < size.X; x++ {
+				img.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: uint8(x >> 8), A: 255})
+			}
+		}
+		want := img
+		for turns := 0; turns < 4; turns++ {
+			got, err := rotateLeft(context.Background(), img, turns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Rect != want.Rect || string(got.Pix) != string(want.Pix) {
+				t.Errorf("%v rotated %d times doesn't match %d rotations by 90 degrees", size, turns, turns)
+			}
+			if want, err = rotateLeft90(context.Background(), want); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := rotateRight90(ctx, image.NewNRGBA(image.Rect(0, 0, 2, 2))); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestSetVariantMode(t *testing.T) {
+	u := NewUnpacker(image.NewNRGBA(image.Rect(0, 0, 128, 192)), 2, 3, 0)
+	if err := u.SetVariantMode(BlobLayout7x7, VariantsFlip); !errors.Is(err, ErrUnknownLayout) {
+		t.Errorf("unknown layout: got %v", err)
+	}
+	if err := u.SetVariantMode(Layout48Terrain1, "mirror"); !errors.Is(err, ErrUnknownVariantMode) {
+		t.Errorf("unknown mode: got %v", err)
+	}
+	if err := u.SetVariantMode(Layout48Terrain1, VariantsFlip); err != nil {
+		t.Fatal(err)
+	}
+	if got := u.variantMode(Layout48Terrain1); got != VariantsFlip {
+		t.Errorf("got %s, want %s", got, VariantsFlip)
+	}
+	if got := u.variantMode(Layout48Terrain2); got != VariantsRotate {
+		t.Errorf("other layout: got %s, want %s", got, VariantsRotate)
+	}
+}
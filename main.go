@@ -25,133 +25,529 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"image"
 	"image/png"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 
+	"github.com/krylphi/autotiler/internal/exporter"
+	"github.com/krylphi/autotiler/internal/output"
 	"github.com/krylphi/autotiler/internal/unpack"
 )
 
 const (
-	inKey      = "in"
-	paddingKey = "p"
-	exportKey  = "e"
-	outKey     = "o"
+	inKey        = "in"
+	paddingKey   = "p"
+	exportKey    = "e"
+	outKey       = "o"
+	layoutKey    = "l"
+	fromKey      = "from"
+	toKey        = "to"
+	toleranceKey = "t"
+	workersKey   = "j"
+	sourceKey    = "s"
+	variantsKey  = "variants"
+	gridKey      = "grid"
+	weightsKey   = "weights"
 )
 
 const (
-	export16  = "16"
-	export28  = "28"
-	export48  = "48"
-	exportAll = "all"
+	packCommand     = "pack"
+	convertCommand  = "convert"
+	validateCommand = "validate"
+	inspectCommand  = "inspect"
+	serveCommand    = "serve"
+	terrainsCommand = "terrains"
+	edgesCommand    = "edges"
+	hexCommand      = "hex"
 )
 
+var (
+	errMissingInput   = errors.New("missing input file")
+	errInvalidWorkers = errors.New("number of workers has to be positive")
+	errUnknownSource  = errors.New("unknown source layout")
+	errVariantsSource = errors.New("random variants need a 2x3 source")
+)
+
+// source2x3 is the default source layout, see examples/2x3_packed.png.
+const source2x3 = "2x3"
+
+const (
+	export16   = "16"
+	export28   = "28"
+	export48   = "48"
+	exportDual = "dual"
+	exportAll  = "all"
+)
+
+// commands returns handlers of the commands which can be passed as the first argument.
+func commands() map[string]func(ctx context.Context, args map[string][]string) error {
+	return map[string]func(ctx context.Context, args map[string][]string) error{
+		packCommand:     pack,
+		convertCommand:  convert,
+		validateCommand: validate,
+		inspectCommand:  validate,
+		serveCommand:    serve,
+		terrainsCommand: terrains,
+		edgesCommand:    edges,
+		hexCommand:      hex,
+	}
+}
+
 func main() {
-	args := parseArgs()
-	inFiles, ok := args[inKey]
-	if !ok {
-		log.Print("Missing input file")
+	if err := run(os.Args[1:]); err != nil {
+		printError(err)
 		os.Exit(1)
 	}
-	outFiles, outs := args[outKey]
-	for i, inFile := range inFiles {
-		inputFile := inFile
-		outputFile := fmt.Sprintf("%d.local.png", i)
-		if outs {
-			if len(outFiles) > i {
-				outputFile = outFiles[i]
-			}
-		}
-		err := export(args, inputFile, outputFile)
-		if err != nil {
-			log.Print(err)
-			os.Exit(1)
+}
+
+// run executes the command passed as the first argument or unpacks 2x3 tile sets if there is none.
+func run(osArgs []string) error {
+	handler := unpackFiles
+	args := make(map[string][]string)
+	if command, ok := commands()[firstArg(osArgs)]; ok {
+		// commands report missing arguments themselves, serve has none required
+		handler = command
+		if len(osArgs) > 1 {
+			args = parseArgs(osArgs[1:])
 		}
+	} else {
+		args = parseArgs(osArgs)
+	}
+	stopProfiling, err := startProfiling(args)
+	if err != nil {
+		return err
 	}
+	defer stopProfiling()
+	// interrupting the program cancels jobs which haven't finished yet
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return handler(ctx, args)
 }
 
-func export(args map[string][]string, inputFile, outputFile string) error { // todo wrap errors
-	imgFile, err := os.Open(inputFile)
+// firstArg returns the first argument or an empty string if there are none.
+func firstArg(osArgs []string) string {
+	if len(osArgs) == 0 {
+		return ""
+	}
+	return osArgs[0]
+}
+
+// unpackFiles generates tile sets from 2x3 tile sets passed with -in.
+// Inputs are decoded and then every (input, layout) pair is exported as a separate job by a pool of -j workers.
+// A failed job doesn't stop the others, all errors are returned together.
+func unpackFiles(ctx context.Context, args map[string][]string) error {
+	inFiles, ok := args[inKey]
+	if !ok {
+		return errMissingInput
+	}
+	padding, err := parsePadding(args)
 	if err != nil {
 		return err
 	}
-	defer imgFile.Close()
-
-	img, _, err := image.Decode(imgFile)
+	workers, err := parseWorkers(args)
 	if err != nil {
 		return err
 	}
+	source, err := parseSource(args)
+	if err != nil {
+		return err
+	}
+	variantModes, err := parseVariantModes(args)
+	if err != nil {
+		return err
+	}
+	grid, err := parseGrid(args[gridKey])
+	if err != nil {
+		return err
+	}
+	weights, err := parseWeights(args[weightsKey])
+	if err != nil {
+		return err
+	}
+	if len(weights) > 1 && source != source2x3 {
+		return fmt.Errorf("--%s: %w", weightsKey, errVariantsSource)
+	}
+	outFiles := args[outKey]
+	if err := checkStdio(inFiles, outFiles); err != nil {
+		return err
+	}
+	layouts := exportLayouts(args)
+	bundlePath := ""
+	var bundleExps []exporter.Exporter
+	if values, ok := args[bundleKey]; ok {
+		if len(outFiles) > 0 {
+			return errBundleWithOutput
+		}
+		bundlePath = values[0]
+		if bundleExps, err = bundleExporters(args); err != nil {
+			return err
+		}
+	}
+	format := ""
+	if len(outFiles) > 0 && outFiles[0] == stdio {
+		if format, err = streamFormat(args, layouts); err != nil {
+			return err
+		}
+	}
+	outputFiles := make([]string, len(inFiles))
+	for i := range inFiles {
+		outputFiles[i] = fmt.Sprintf("%d.local.png", i)
+		if len(outFiles) > i {
+			outputFiles[i] = outFiles[i]
+		}
+	}
 
-	padding := 0
-	if paddings, ok := args[paddingKey]; ok {
-		padding, err = strconv.Atoi(paddings[0])
+	unpackers := make([]*unpack.Unpacker, len(inFiles))
+	loadErr := runJobs(ctx, len(inFiles), workers, func(i int) error {
+		unpacker, err := loadUnpacker(inFiles[i], padding, source, weights)
 		if err != nil {
 			return err
 		}
+		for layout, mode := range variantModes {
+			if err := unpacker.SetVariantMode(layout, mode); err != nil {
+				return err
+			}
+		}
+		unpacker.SetIsometric(grid == output.GridIso)
+		unpackers[i] = unpacker
+		return nil
+	})
+	if source == unpack.SourceBlobMin5 {
+		logMissingTiles(inFiles, unpackers, layouts)
+	}
+
+	if bundlePath != "" {
+		if loadErr != nil {
+			return loadErr
+		}
+		return bundleFiles(ctx, bundlePath, inFiles, unpackers, layouts, padding, bundleExps, workers)
+	}
+	if format != "" {
+		if unpackers[0] == nil {
+			return loadErr
+		}
+		return writeStream(ctx, os.Stdout, unpackers[0], layouts, padding, format, workers)
+	}
+
+	type exportJob struct {
+		inputFile, outputFile, layout string
+		unpacker                      *unpack.Unpacker
+	}
+	var jobs []exportJob
+	for i, unpacker := range unpackers {
+		if unpacker == nil {
+			continue
+		}
+		for _, layout := range layouts {
+			jobs = append(jobs, exportJob{
+				inputFile:  inFiles[i],
+				outputFile: outputFiles[i],
+				layout:     layout,
+				unpacker:   unpacker,
+			})
+		}
+	}
+	exportErr := runJobs(ctx, len(jobs), workers, func(i int) error {
+		job := jobs[i]
+		err := produceTileset(func() (*image.NRGBA, error) {
+			return job.unpacker.ExportContext(ctx, job.layout, nil)
+		}, job.outputFile, job.layout)
+		if err != nil {
+			return fmt.Errorf("%s: %w", job.inputFile, err)
+		}
+		return nil
+	})
+	return errors.Join(loadErr, exportErr)
+}
+
+// logMissingTiles logs tiles of the layouts which are left transparent because the source doesn't draw
+// every segment of a 2x3 tile set, like a blob min strip without the terrain 2 fill.
+func logMissingTiles(inFiles []string, unpackers []*unpack.Unpacker, layouts []string) {
+	for i, unpacker := range unpackers {
+		if unpacker == nil {
+			continue
+		}
+		for _, layout := range layouts {
+			// unknown layouts are reported by the export
+			missing, err := unpacker.MissingTiles(layout)
+			if err != nil || len(missing) == 0 {
+				continue
+			}
+			log.Printf("%s: tiles %v of %s are not fully drawn in the source and stay partly transparent\n",
+				inFiles[i], missing, layout)
+		}
 	}
+}
 
+// loadUnpacker decodes the tile set of the given source layout from the file and initializes an unpacker for it.
+// Sources other than 2x3 are converted to a 2x3 tile set first. A 2x3 source holds a pack for every weight.
+func loadUnpacker(inputFile string, padding int, source string, weights []float64) (*unpack.Unpacker, error) {
+	img, err := decodeImage(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	switch source {
+	case unpack.SourceCornerWang16:
+		img, err = unpack.From16CornerTo6(img)
+	case unpack.SourceBlobMin5:
+		img, err = unpack.From5BlobMinTo6(img)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", inputFile, err)
+	}
 	unpacker := unpack.NewUnpacker(img, 2, 3, padding)
+	if err := unpacker.SetRandomVariants(weights); err != nil {
+		return nil, err
+	}
 	if err := unpacker.Init(2); err != nil {
-		return err
+		return nil, fmt.Errorf("%s: %w", inputFile, err)
+	}
+	return unpacker, nil
+}
+
+// parseVariantModes returns variant modes by layout passed with --variants <mode> for all layouts
+// or --variants <layout>=<mode> for a single one.
+func parseVariantModes(args map[string][]string) (map[string]unpack.VariantMode, error) {
+	res := make(map[string]unpack.VariantMode)
+	for _, value := range args[variantsKey] {
+		layout, mode, ok := strings.Cut(value, "=")
+		if !ok {
+			for _, layout := range unpack.ExportLayouts() {
+				res[layout] = unpack.VariantMode(value)
+			}
+			continue
+		}
+		res[layout] = unpack.VariantMode(mode)
+	}
+	// validate names once instead of reporting them for every input
+	probe := unpack.NewUnpacker(nil, 2, 3, 0)
+	for layout, mode := range res {
+		if err := probe.SetVariantMode(layout, mode); err != nil {
+			return nil, fmt.Errorf("--%s: %w", variantsKey, err)
+		}
+	}
+	return res, nil
+}
+
+// parseGrid returns the grid of tiles passed with --grid or square if there is none.
+func parseGrid(values []string) (string, error) {
+	if len(values) == 0 {
+		return output.GridSquare, nil
 	}
+	switch values[0] {
+	case output.GridSquare, output.GridIso:
+		return values[0], nil
+	}
+	return "", fmt.Errorf("--%s: %w: %s", gridKey, output.ErrUnknownGrid, values[0])
+}
+
+// parseWeights returns weights of random variants passed with --weights <weight,weight,...>,
+// one for every 2x3 pack of the source side by side, or nil if there are none.
+func parseWeights(values []string) ([]float64, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	var res []float64
+	for _, value := range strings.Split(values[0], ",") {
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("--%s: %w: %s", weightsKey, unpack.ErrInvalidWeight, value)
+		}
+		res = append(res, weight)
+	}
+	// validate weights once instead of reporting them for every input
+	if err := unpack.NewUnpacker(nil, 2, 3, 0).SetRandomVariants(res); err != nil {
+		return nil, fmt.Errorf("--%s: %w", weightsKey, err)
+	}
+	return res, nil
+}
 
+// parseSource returns the source layout passed with -s or 2x3 if there is none.
+func parseSource(args map[string][]string) (string, error) {
+	values, ok := args[sourceKey]
+	if !ok {
+		return source2x3, nil
+	}
+	switch values[0] {
+	case source2x3, unpack.SourceCornerWang16, unpack.SourceBlobMin5:
+		return values[0], nil
+	}
+	return "", fmt.Errorf("-%s: %w: %s", sourceKey, errUnknownSource, values[0])
+}
+
+// exportLayouts returns names of the layouts requested with -e without duplicates.
+// Unknown export types are ignored.
+func exportLayouts(args map[string][]string) []string {
 	exports, ok := args[exportKey]
 	var exportTypes []string
 	if !ok || len(exports) == 0 || exports[0] == exportAll {
+		// the dual grid layout is generated only on request
 		exportTypes = []string{export16, export28, export48}
 	} else {
 		exportTypes = exports
 	}
 
-	for e := range exportTypes {
-		exportType := exportTypes[e]
-		switch exportType {
-		case export16:
-			err := produceTileset(unpacker.From6to16Terrain1, outputFile, "16x1_terrain1")
-			if err != nil {
-				return err
-			}
-			err = produceTileset(unpacker.From6to16Terrain2, outputFile, "16x1_terrain2")
-			if err != nil {
-				return err
-			}
-		case export28:
-			err := produceTileset(unpacker.From6to28, outputFile, "14x2")
-			if err != nil {
-				return err
-			}
-		case export48:
-			err := produceTileset(unpacker.From6to48Terrain1, outputFile, "12x4_terrain1")
-			if err != nil {
-				return err
-			}
-			err = produceTileset(unpacker.From6to48Terrain2, outputFile, "12x4_terrain2")
-			if err != nil {
-				return err
+	layoutsByType := map[string][]string{
+		export16:   {unpack.Layout16Terrain1, unpack.Layout16Terrain2},
+		export28:   {unpack.Layout28},
+		export48:   {unpack.Layout48Terrain1, unpack.Layout48Terrain2},
+		exportDual: {unpack.LayoutDualGrid16},
+	}
+	var res []string
+	seen := make(map[string]bool)
+	for _, exportType := range exportTypes {
+		for _, layout := range layoutsByType[exportType] {
+			if !seen[layout] {
+				seen[layout] = true
+				res = append(res, layout)
 			}
 		}
 	}
-	return nil
+	return res
 }
 
-func parseArgs() map[string][]string {
-	if len(os.Args) < 2 {
+// decodeImage reads and decodes an image from the given file or stdin if the name is "-".
+// Errors are wrapped with the file name.
+func decodeImage(inputFile string) (image.Image, error) {
+	imgFile, err := openInput(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	defer imgFile.Close()
+
+	img, err := unpack.Decode(imgFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", inputFile, err)
+	}
+	return img, nil
+}
+
+// errorHints maps errors to advices printed along with them.
+//
+//nolint:gochecknoglobals //static table
+var errorHints = []struct {
+	err  error
+	hint string
+}{
+	{errMissingInput, "pass an image with -in <file>"},
+	{unpack.ErrDecode, "the input has to be a PNG image"},
+	{unpack.ErrImageTooSmall, "make sure the image and -p match the layout"},
+	{unpack.ErrTileSizeNotDivisible, "the image has to consist of equal tiles matching the layout"},
+	{unpack.ErrInvalidPadding, "-p has to be between 0 and the tile size"},
+	{unpack.ErrInvalidTileSize, "the tiles have to be square and match -p, an edge strip has 5 or 6 tiles in a row, a hex strip 2"},
+	{unpack.ErrUnknownVariantMode, "--variants is rotate, flip or source, optionally prefixed with <layout>="},
+	{unpack.ErrUnknownLayout, "run without arguments to see the supported layouts"},
+	{unpack.ErrMissingTile, "the source layout lacks a tile required by the target layout"},
+	{unpack.ErrMissingSegment, "draw the named segment in the source or use --variants rotate"},
+	{strconv.ErrSyntax, "-p, -t and -j have to be integers"},
+	{errStdoutSingleInput, "pass a single -in to write to stdout with -o -"},
+	{errPNGSingleLayout, "pass a single layout with -e or use -f tar or -f zip"},
+	{errMissingPairs, "pass 2x3 tile sets of terrain pairs with --pair <terrain1>,<terrain2>=<file>"},
+	{errInvalidPair, "--pair is <terrain1>,<terrain2>=<file>"},
+	{unpack.ErrInvalidTerrains, "terrain 1 of every --pair has to come before its terrain 2 in --terrains"},
+	{unpack.ErrMissingPack, "pass a --pair for every two terrains"},
+	{unpack.ErrApproximateTiles, "pass --approximate true to generate them anyway, Tiled wangsets leave them out"},
+	{errInvalidApproximate, "--approximate is true or false"},
+	{exporter.ErrUnsupportedTileset, "the exporter doesn't support the tile set, use manifest or tiled"},
+	{errUnknownSource, "-s is one of 2x3, 4x4_corner, 5x1_blob_min"},
+	{output.ErrUnknownGrid, "--grid is square or iso"},
+	{unpack.ErrInvalidWeight, "--weights are positive numbers separated by commas, one for every 2x3 pack of the source"},
+	{errVariantsSource, "put the 2x3 packs of random variants side by side and drop -s"},
+	{unpack.ErrUnknownOrientation, "--orientation is pointy or flat"},
+	{errInvalidMaxUpload, "-max is the upload size limit in bytes"},
+	{errBundleWithOutput, "all results are written to the bundle, drop -o"},
+	{exporter.ErrUnknownExporter, "--exporter is one of manifest, tiled, godot"},
+}
+
+// printError logs the error along with a hint how to fix it if there is one.
+// Every error joined with errors.Join is logged separately.
+func printError(err error) {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			printError(err)
+		}
+		return
+	}
+	for _, h := range errorHints {
+		if errors.Is(err, h.err) {
+			log.Printf("%v (%s)", err, h.hint)
+			return
+		}
+	}
+	log.Print(err)
+}
+
+// parseWorkers returns the number of workers passed with -j or the number of CPUs if there is none.
+func parseWorkers(args map[string][]string) (int, error) {
+	values, ok := args[workersKey]
+	if !ok {
+		return runtime.GOMAXPROCS(0), nil
+	}
+	workers, err := strconv.Atoi(values[0])
+	if err != nil {
+		return 0, fmt.Errorf("-%s: %w", workersKey, err)
+	}
+	if workers < 1 {
+		return 0, fmt.Errorf("-%s: %w", workersKey, errInvalidWorkers)
+	}
+	return workers, nil
+}
+
+// parsePadding returns the padding passed with -p or 0 if there is none.
+func parsePadding(args map[string][]string) (int, error) {
+	paddings, ok := args[paddingKey]
+	if !ok {
+		return 0, nil
+	}
+	padding, err := strconv.Atoi(paddings[0])
+	if err != nil {
+		return 0, fmt.Errorf("-%s: %w", paddingKey, err)
+	}
+	return padding, nil
+}
+
+func parseArgs(osArgs []string) map[string][]string {
+	if len(osArgs) < 1 {
 		log.Print(
-			"Usage: autotiler -in <file_in> [-o <file_out>] [-p <padding>] [-e <export_type(16,28,48,all)>]\n" +
-				"       -e can be repeated\n")
+			"Usage: autotiler -in <file_in> [-o <file_out>] [-p <padding>] [-e <export_type(16,28,48,dual,all)>] [-j <workers>]\n" +
+				"       -e can be repeated, all is 16, 28 and 48, - for -in and -o stands for stdin and stdout\n" +
+				"       [-s <2x3|4x4_corner|5x1_blob_min>] sets the layout of the input, 2x3 by default\n" +
+				"       [--variants [<layout>=]<rotate|flip|source>] sets how 12x4 tiles are turned, can be repeated\n" +
+				"       [--grid <square|iso>] iso unpacks diamond tiles, every tile side has to be divisible by 4\n" +
+				"       [--weights <weight,weight,...>] the source holds a 2x3 pack of random variants side by side for every weight\n" +
+				"       [-f <png|tar|zip>] sets the format written to stdout, png for a single layout and tar otherwise by default\n" +
+				"       [--bundle <file.zip>] writes all images, manifests and engine files with an index to a zip archive\n" +
+				"       [--exporter <manifest|tiled|godot>] selects engine files of the bundle, can be repeated (all by default)\n" +
+				"       autotiler pack -in <tileset_in> -l <layout> [-o <file_out>] [-p <padding>]\n" +
+				"       layout is one of 16x1_terrain1, 16x1_terrain2, 4x4_terrain1, 4x4_terrain2, 14x2, " +
+				"12x4_terrain1, 12x4_terrain2, 4x4_dual\n" +
+				"       autotiler convert -in <tileset_in> -from <layout> -to <layout> [-o <file_out>] [-p <padding>]\n" +
+				"       autotiler validate -in <tileset_in> -l <layout> [-o <diff_out>] [-p <padding>] [-t <tolerance>]\n" +
+				"       convert and validate layout is one of 12x4, 7x7, 24x11, 16x16\n" +
+				"       autotiler serve [-addr <host:port>] [-max <max_upload_bytes>]\n" +
+				"       autotiler terrains --pair <terrain1>,<terrain2>=<2x3_in> [--pair ...] [--terrains <name,name,...>] " +
+				"[--approximate <true|false>] [-o <file_out>] [-p <padding>] [--exporter <tiled>]\n" +
+				"       autotiler edges -in <strip_in> [-o <file_out>] [-p <padding>] [--exporter <tiled>]\n" +
+				"       autotiler hex -in <strip_in> [--orientation <pointy|flat>] [-o <file_out>] [-p <padding>] " +
+				"[--exporter <tiled>]\n" +
+				"       every command accepts [--cpuprofile <file>] [--memprofile <file>]\n")
 		os.Exit(1)
 	}
 	res := make(map[string][]string)
 	allTilesets := false
-	for i := 1; i < len(os.Args); i += 2 {
-		key := strings.TrimPrefix(os.Args[i], "-")
+	for i := 0; i < len(osArgs); i += 2 {
+		key := strings.TrimLeft(osArgs[i], "-")
 		v, ok := res[key]
-		value := os.Args[i+1]
+		value := osArgs[i+1]
 		if key == exportKey && allTilesets {
 			continue
 		}
@@ -191,7 +587,7 @@ func produceTileset(unpackFunction func() (*image.NRGBA, error), outputPath, exp
 	}(file)
 	err = png.Encode(file, canvas)
 	if err != nil {
-		return err
+		return fmt.Errorf("%s: %w", outputFile, err)
 	}
 	return nil
 }
@@ -0,0 +1,54 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/krylphi/autotiler/internal/unpack"
+)
+
+func TestExportLayouts(t *testing.T) {
+	tests := []struct {
+		name    string
+		exports []string
+		want    []string
+	}{
+		{"default", nil, unpack.DefaultExportLayouts()},
+		{"all", []string{exportAll}, unpack.DefaultExportLayouts()},
+		{"dual", []string{exportDual}, []string{unpack.LayoutDualGrid16}},
+		{"duplicates", []string{export48, exportDual, export48}, []string{unpack.Layout48Terrain1, unpack.Layout48Terrain2, unpack.LayoutDualGrid16}},
+	}
+	for _, tt := range tests {
+		args := map[string][]string{}
+		if tt.exports != nil {
+			args[exportKey] = tt.exports
+		}
+		if got := exportLayouts(args); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
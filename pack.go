@@ -0,0 +1,94 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"log"
+
+	"github.com/krylphi/autotiler/internal/unpack"
+)
+
+var errMissingLayout = errors.New("missing layout")
+
+// pack restores 2x3 tile sets from tile sets passed with -in.
+// Conflicting and missing quarters are reported to the log.
+func pack(ctx context.Context, args map[string][]string) error {
+	inFiles, ok := args[inKey]
+	if !ok {
+		return errMissingInput
+	}
+	layouts, ok := args[layoutKey]
+	if !ok {
+		return errMissingLayout
+	}
+	padding, err := parsePadding(args)
+	if err != nil {
+		return err
+	}
+	outFiles := args[outKey]
+	for i, inputFile := range inFiles {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		outputFile := fmt.Sprintf("%d.local.png", i)
+		if len(outFiles) > i {
+			outputFile = outFiles[i]
+		}
+		layout := layouts[0]
+		if len(layouts) > i {
+			layout = layouts[i]
+		}
+		if err := packTileset(ctx, inputFile, outputFile, layout, padding); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func packTileset(ctx context.Context, inputFile, outputFile, layout string, padding int) error {
+	img, err := decodeImage(inputFile)
+	if err != nil {
+		return err
+	}
+
+	res, err := unpack.PackContext(ctx, img, layout, padding, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", inputFile, err)
+	}
+	for _, conflict := range res.Conflicts {
+		log.Printf("%s: quarter %v of tile %v differs from tile %v\n",
+			inputFile, conflict.Quarter, conflict.Other, conflict.Tile)
+	}
+	if len(res.Missing) > 0 {
+		log.Printf("%s: quarters %v are not present in %s layout\n", inputFile, res.Missing, layout)
+	}
+	return produceTileset(func() (*image.NRGBA, error) {
+		return res.Image, nil
+	}, outputFile, "2x3")
+}
@@ -0,0 +1,69 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// runJobs calls fn for every job index from 0 to count (exclusive) using at most workers goroutines.
+// A failed job doesn't stop the others. Jobs which haven't started are skipped once the context is done.
+//
+// Parameters:
+// - ctx: The context which cancels jobs which haven't started yet.
+// - count: The number of jobs.
+// - workers: The maximum number of jobs running at the same time.
+// - fn: The function running the job with the given index.
+//
+// Returns:
+// - Errors of all failed jobs joined in the order of jobs followed by the context error
+// or nil if every job succeeded.
+func runJobs(ctx context.Context, count, workers int, fn func(i int) error) error {
+	errs := make([]error, count)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, count); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+feed:
+	for i := 0; i < count; i++ {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return errors.Join(append(errs, ctx.Err())...)
+}
@@ -0,0 +1,92 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunJobs(t *testing.T) {
+	const workers = 3
+	var running, maxRunning, done atomic.Int32
+	errOdd := errors.New("odd job")
+	err := runJobs(context.Background(), 20, workers, func(i int) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		done.Add(1)
+		if i%2 == 1 {
+			return fmt.Errorf("job %d: %w", i, errOdd)
+		}
+		return nil
+	})
+	if done.Load() != 20 {
+		t.Errorf("%d jobs done, want 20", done.Load())
+	}
+	if maxRunning.Load() > workers {
+		t.Errorf("%d jobs were running at the same time, want at most %d", maxRunning.Load(), workers)
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok || len(joined.Unwrap()) != 10 || !errors.Is(err, errOdd) {
+		t.Fatalf("got %v, want 10 joined errors", err)
+	}
+	if first := joined.Unwrap()[0].Error(); first != "job 1: odd job" {
+		t.Errorf("first error is %q, errors have to be in the order of jobs", first)
+	}
+}
+
+func TestRunJobsNoJobs(t *testing.T) {
+	if err := runJobs(context.Background(), 0, 4, func(int) error { return errors.New("unexpected") }); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRunJobsCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var done atomic.Int32
+	err := runJobs(ctx, 100, 2, func(int) error {
+		if done.Add(1) == 5 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	if n := done.Load(); n > 7 {
+		t.Errorf("%d jobs done after cancellation", n-5)
+	}
+}
@@ -0,0 +1,79 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+const (
+	cpuProfileKey = "cpuprofile"
+	memProfileKey = "memprofile"
+)
+
+// startProfiling starts CPU profiling if --cpuprofile is passed. The returned function stops it and writes
+// the heap profile if --memprofile is passed. Profiles can be inspected with `go tool pprof`.
+func startProfiling(args map[string][]string) (func(), error) {
+	var cpuFile *os.File
+	if files, ok := args[cpuProfileKey]; ok {
+		var err error
+		cpuFile, err = os.Create(files[0])
+		if err != nil {
+			return nil, fmt.Errorf("-%s: %w", cpuProfileKey, err)
+		}
+		if err := pprof.StartCPUProfile(cpuFile); err != nil {
+			cpuFile.Close()
+			return nil, fmt.Errorf("-%s: %w", cpuProfileKey, err)
+		}
+	}
+	return func() {
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			if err := cpuFile.Close(); err != nil {
+				log.Println(err)
+			}
+		}
+		if files, ok := args[memProfileKey]; ok {
+			if err := writeHeapProfile(files[0]); err != nil {
+				log.Printf("-%s: %v", memProfileKey, err)
+			}
+		}
+	}, nil
+}
+
+// writeHeapProfile writes the heap profile to the given file.
+func writeHeapProfile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	runtime.GC() // get up-to-date statistics
+	return pprof.WriteHeapProfile(file)
+}
@@ -0,0 +1,331 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"io/fs"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/krylphi/autotiler/internal/exporter"
+	"github.com/krylphi/autotiler/internal/output"
+	"github.com/krylphi/autotiler/internal/unpack"
+)
+
+const (
+	addrKey      = "addr"
+	maxUploadKey = "max"
+)
+
+const (
+	defaultServeAddr = "127.0.0.1:8080"
+	// defaultMaxUploadBytes limits the size of a request body.
+	defaultMaxUploadBytes = 16 << 20
+	// maxServePixels limits the size of an uploaded image after decoding.
+	maxServePixels = 4096 * 4096
+	// maxServeOutputPixels limits the size of all tile sets generated for a request together,
+	// as padding, layouts and random variants make them much larger than the uploaded image.
+	maxServeOutputPixels = 4 * maxServePixels
+	// multipartMemory is the part of a multipart form kept in memory, the rest is stored in temporary files.
+	multipartMemory = 8 << 20
+	shutdownTimeout = 5 * time.Second
+)
+
+// Response formats of the unpack endpoint.
+const (
+	formatPNG  = "png"
+	formatZip  = "zip"
+	formatJSON = "json"
+)
+
+var (
+	errImageTooLarge    = errors.New("image is too large")
+	errOutputTooLarge   = errors.New("generated tile sets are too large")
+	errMalformedForm    = errors.New("malformed multipart form")
+	errMissingImage     = errors.New("missing image file")
+	errUnknownFormat    = errors.New("unknown format")
+	errPNGSingleLayout  = errors.New("png format requires exactly one layout")
+	errInvalidMaxUpload = errors.New("max upload size has to be positive")
+)
+
+// server generates tile sets from uploaded 2x3 tile sets.
+type server struct {
+	maxUploadBytes int64
+}
+
+// serve runs an HTTP server generating tile sets until the context is done.
+//
+// Endpoints:
+// - GET /healthz responds with 200 OK.
+// - POST /unpack takes a multipart form with a PNG image in the "image" field and optional fields
+// "layout" and "exporter" (both can be repeated), "padding", "grid", "weights" and "format" (png, zip or json).
+func serve(ctx context.Context, args map[string][]string) error {
+	addr := defaultServeAddr
+	if values, ok := args[addrKey]; ok {
+		addr = values[0]
+	}
+	s := &server{maxUploadBytes: defaultMaxUploadBytes}
+	if values, ok := args[maxUploadKey]; ok {
+		limit, err := strconv.ParseInt(values[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("-%s: %w", maxUploadKey, err)
+		}
+		if limit < 1 {
+			return fmt.Errorf("-%s: %w: %d", maxUploadKey, errInvalidMaxUpload, limit)
+		}
+		s.maxUploadBytes = limit
+	}
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           s.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errs := make(chan error, 1)
+	go func() {
+		log.Printf("listening on %s\n", addr)
+		errs <- httpServer.ListenAndServe()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return httpServer.Shutdown(shutdownCtx) //nolint:contextcheck //the server context is already done
+	}
+}
+
+// routes returns the handler of all endpoints.
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.health)
+	mux.HandleFunc("POST /unpack", s.unpack)
+	return mux
+}
+
+func (s *server) health(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = io.WriteString(w, "ok\n")
+}
+
+// unpackRequest holds parsed options of the unpack endpoint.
+type unpackRequest struct {
+	img       image.Image
+	layouts   []string
+	padding   int
+	exporters []exporter.Exporter
+	format    string
+	grid      string
+	weights   []float64
+}
+
+func (s *server) unpack(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxUploadBytes)
+	req, err := s.parseUnpackRequest(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	unpacker, err := output.NewUnpacker(req.img, req.padding, req.grid, req.weights)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	if err := checkOutputSize(unpacker, req.layouts); err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	outputs, err := output.Generate(r.Context(), unpacker, req.layouts, req.padding)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+
+	switch req.format {
+	case formatPNG:
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, outputs[0].Image); err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(buf.Bytes())
+	case formatZip:
+		manifest, err := exporter.New("manifest")
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		var buf bytes.Buffer
+		if err := output.WriteZip(&buf, outputs, append([]exporter.Exporter{manifest}, req.exporters...)); err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="tilesets.zip"`)
+		_, _ = w.Write(buf.Bytes())
+	case formatJSON:
+		res := struct {
+			Tilesets []*exporter.Tileset `json:"tilesets"`
+		}{}
+		for _, out := range outputs {
+			res.Tilesets = append(res.Tilesets, out.Description)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(res)
+	}
+}
+
+// parseUnpackRequest parses the multipart form of the unpack endpoint and decodes the uploaded image.
+func (s *server) parseUnpackRequest(r *http.Request) (*unpackRequest, error) {
+	if err := r.ParseMultipartForm(multipartMemory); err != nil {
+		return nil, formError(err)
+	}
+	defer func() {
+		_ = r.MultipartForm.RemoveAll()
+	}()
+	req := &unpackRequest{}
+	var err error
+	if req.layouts, err = output.Layouts(r.MultipartForm.Value["layout"]); err != nil {
+		return nil, err
+	}
+	if padding := r.FormValue("padding"); padding != "" {
+		if req.padding, err = strconv.Atoi(padding); err != nil {
+			return nil, fmt.Errorf("padding: %w", err)
+		}
+	}
+	if req.exporters, err = output.Exporters(r.MultipartForm.Value["exporter"]); err != nil {
+		return nil, err
+	}
+	if req.grid, err = parseGrid(r.MultipartForm.Value["grid"]); err != nil {
+		return nil, err
+	}
+	if req.weights, err = parseWeights(r.MultipartForm.Value["weights"]); err != nil {
+		return nil, err
+	}
+	req.format = r.FormValue("format")
+	switch {
+	case req.format == "" && len(req.layouts) == 1 && len(req.exporters) == 0:
+		req.format = formatPNG
+	case req.format == "":
+		req.format = formatZip
+	case req.format == formatPNG && len(req.layouts) != 1:
+		return nil, errPNGSingleLayout
+	case req.format != formatPNG && req.format != formatZip && req.format != formatJSON:
+		return nil, fmt.Errorf("%w: %s", errUnknownFormat, req.format)
+	}
+
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errMissingImage, err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", unpack.ErrDecode, err)
+	}
+	if cfg.Width > maxServePixels/max(cfg.Height, 1) {
+		return nil, fmt.Errorf("%w: %dx%d px", errImageTooLarge, cfg.Width, cfg.Height)
+	}
+	if req.img, err = unpack.Decode(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// formError marks an error of parsing the multipart form caused by the request, like a malformed part
+// or a body ending too early, with errMalformedForm. Too large bodies and failures to store parts
+// in temporary files are returned as they are.
+func formError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	var pathErr *fs.PathError
+	if errors.As(err, &maxBytesErr) || errors.As(err, &pathErr) || errors.Is(err, multipart.ErrMessageTooLarge) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", errMalformedForm, err)
+}
+
+// checkOutputSize returns an error wrapping errOutputTooLarge if the tile sets of the layouts would have
+// more than maxServeOutputPixels pixels together. The unpacker has to be initialized.
+func checkOutputSize(unpacker *unpack.Unpacker, layouts []string) error {
+	total := 0
+	for _, layout := range layouts {
+		size, err := unpacker.ExportSize(layout)
+		if err != nil {
+			return err
+		}
+		total += size.X * size.Y
+	}
+	if total > maxServeOutputPixels {
+		return fmt.Errorf("%w: %d px, at most %d px", errOutputTooLarge, total, maxServeOutputPixels)
+	}
+	return nil
+}
+
+// httpStatus returns the status code of the response to a request which failed with the given error.
+func httpStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr), errors.Is(err, errImageTooLarge), errors.Is(err, errOutputTooLarge),
+		errors.Is(err, multipart.ErrMessageTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, unpack.ErrDecode), errors.Is(err, unpack.ErrImageTooSmall),
+		errors.Is(err, unpack.ErrTileSizeNotDivisible), errors.Is(err, unpack.ErrInvalidPadding),
+		errors.Is(err, unpack.ErrInvalidTileSize), errors.Is(err, unpack.ErrUnknownLayout),
+		errors.Is(err, exporter.ErrUnknownExporter), errors.Is(err, errMissingImage),
+		errors.Is(err, errUnknownFormat), errors.Is(err, errPNGSingleLayout),
+		errors.Is(err, output.ErrUnknownGrid), errors.Is(err, strconv.ErrSyntax),
+		errors.Is(err, unpack.ErrInvalidWeight),
+		errors.Is(err, errMalformedForm):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// writeHTTPError responds with a JSON object holding the error message.
+func writeHTTPError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus(err))
+	_ = json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{Error: err.Error()})
+}
@@ -0,0 +1,341 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/krylphi/autotiler/internal/exporter"
+	"github.com/krylphi/autotiler/internal/output"
+	"github.com/krylphi/autotiler/internal/unpack"
+)
+
+const exampleSource = "examples/2x3_packed.png"
+
+// newUnpackRequest returns a multipart request of the unpack endpoint with the given image and form fields.
+func newUnpackRequest(t *testing.T, img []byte, fields map[string][]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for key, values := range fields {
+		for _, value := range values {
+			if err := form.WriteField(key, value); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if img != nil {
+		part, err := form.CreateFormFile("image", "2x3.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := part.Write(img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := form.Close(); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/unpack", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return req
+}
+
+func exampleImage(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile(exampleSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func serveRequest(req *http.Request, maxUploadBytes int64) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	(&server{maxUploadBytes: maxUploadBytes}).routes().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServeHealth(t *testing.T) {
+	rec := serveRequest(httptest.NewRequest(http.MethodGet, "/healthz", nil), defaultMaxUploadBytes)
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok\n" {
+		t.Errorf("got %d %q", rec.Code, rec.Body.String())
+	}
+	rec = serveRequest(httptest.NewRequest(http.MethodGet, "/unpack", nil), defaultMaxUploadBytes)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /unpack: got %d", rec.Code)
+	}
+}
+
+func TestServePNG(t *testing.T) {
+	data := exampleImage(t)
+	rec := serveRequest(newUnpackRequest(t, data, map[string][]string{
+		"layout":  {unpack.Layout28},
+		"padding": {"1"},
+	}), defaultMaxUploadBytes)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("got %d %s: %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+	got, err := png.Decode(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := unpack.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := unpack.NewUnpacker(src, 2, 3, 1)
+	if err := u.Init(2); err != nil {
+		t.Fatal(err)
+	}
+	want, err := u.From6to28()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotNRGBA, ok := got.(*image.NRGBA)
+	if !ok || !bytes.Equal(gotNRGBA.Pix, want.Pix) {
+		t.Error("response differs from From6to28 result")
+	}
+}
+
+func TestServeZip(t *testing.T) {
+	rec := serveRequest(newUnpackRequest(t, exampleImage(t), map[string][]string{
+		"layout":   {unpack.Layout48Terrain1, unpack.Layout16Terrain1},
+		"exporter": {"tiled", "godot", "tiled"},
+	}), defaultMaxUploadBytes)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("got %d %s: %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+	archive, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	want := "12x4_terrain1.json 12x4_terrain1.png 12x4_terrain1.tres 12x4_terrain1.tsx " +
+		"16x1_terrain1.json 16x1_terrain1.png 16x1_terrain1.tres 16x1_terrain1.tsx"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("got files %s, want %s", got, want)
+	}
+}
+
+func TestServeJSON(t *testing.T) {
+	rec := serveRequest(newUnpackRequest(t, exampleImage(t), map[string][]string{
+		"format": {formatJSON},
+	}), defaultMaxUploadBytes)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body.String())
+	}
+	var res struct {
+		Tilesets []exporter.Tileset `json:"tilesets"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Tilesets) != len(unpack.DefaultExportLayouts()) {
+		t.Fatalf("got %d tile sets", len(res.Tilesets))
+	}
+	for i, ts := range res.Tilesets {
+		if ts.Name != unpack.DefaultExportLayouts()[i] || ts.TileWidth != 64 || len(ts.Tiles) == 0 {
+			t.Errorf("unexpected tile set %s: %d px, %d tiles", ts.Name, ts.TileWidth, len(ts.Tiles))
+		}
+	}
+}
+
+func TestServeIsometric(t *testing.T) {
+	data, err := os.ReadFile("examples/2x3_packed_iso.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := serveRequest(newUnpackRequest(t, data, map[string][]string{
+		"grid":   {output.GridIso},
+		"layout": {unpack.Layout48Terrain1},
+		"format": {formatJSON},
+	}), defaultMaxUploadBytes)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body.String())
+	}
+	var res struct {
+		Tilesets []exporter.Tileset `json:"tilesets"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if ts := res.Tilesets[0]; ts.Orientation != exporter.OrientationIsometric || ts.TileWidth != 128 || ts.TileHeight != 64 {
+		t.Errorf("unexpected %s tile set of %dx%d px tiles", ts.Orientation, ts.TileWidth, ts.TileHeight)
+	}
+}
+
+func TestServeRandomVariants(t *testing.T) {
+	data, err := os.ReadFile("examples/2x3_packed_variants.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := serveRequest(newUnpackRequest(t, data, map[string][]string{
+		"weights": {"3,1"},
+		"layout":  {unpack.Layout48Terrain1},
+		"format":  {formatJSON},
+	}), defaultMaxUploadBytes)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body.String())
+	}
+	var res struct {
+		Tilesets []exporter.Tileset `json:"tilesets"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	ts := res.Tilesets[0]
+	if ts.TileHeight != 64 || ts.Rows != 8 || len(ts.Tiles) != 96 {
+		t.Fatalf("unexpected tile set of %d px tiles, %d rows, %d tiles", ts.TileHeight, ts.Rows, len(ts.Tiles))
+	}
+	if base, variant := ts.Tiles[0], ts.Tiles[48]; base.Probability != 3 || variant.Probability != 1 || variant.Variant != 1 {
+		t.Errorf("unexpected base tile %+v and variant %+v", base, variant)
+	}
+}
+
+func TestServeErrors(t *testing.T) {
+	data := exampleImage(t)
+	tests := []struct {
+		name           string
+		img            []byte
+		fields         map[string][]string
+		maxUploadBytes int64
+		want           int
+	}{
+		{"too large", data, nil, 1024, http.StatusRequestEntityTooLarge},
+		{"missing image", nil, nil, defaultMaxUploadBytes, http.StatusBadRequest},
+		{"not an image", []byte("not an image"), nil, defaultMaxUploadBytes, http.StatusBadRequest},
+		{"unknown layout", data, map[string][]string{"layout": {"7x7"}}, defaultMaxUploadBytes, http.StatusBadRequest},
+		{"unknown exporter", data, map[string][]string{"exporter": {"unity"}}, defaultMaxUploadBytes, http.StatusBadRequest},
+		{"unknown format", data, map[string][]string{"format": {"gif"}}, defaultMaxUploadBytes, http.StatusBadRequest},
+		{"png of many layouts", data, map[string][]string{"format": {formatPNG}}, defaultMaxUploadBytes, http.StatusBadRequest},
+		{"invalid padding", data, map[string][]string{"padding": {"-1"}}, defaultMaxUploadBytes, http.StatusBadRequest},
+		{"unknown grid", data, map[string][]string{"grid": {"hex"}}, defaultMaxUploadBytes, http.StatusBadRequest},
+		{"padding not a number", data, map[string][]string{"padding": {"one"}}, defaultMaxUploadBytes, http.StatusBadRequest},
+		{"invalid weights", data, map[string][]string{"weights": {"1,0"}}, defaultMaxUploadBytes, http.StatusBadRequest},
+		{"weights not numbers", data, map[string][]string{"weights": {"1,two"}}, defaultMaxUploadBytes, http.StatusBadRequest},
+		{"more weights than packs", data, map[string][]string{"weights": {"1,1,1"}}, defaultMaxUploadBytes, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveRequest(newUnpackRequest(t, tt.img, tt.fields), tt.maxUploadBytes)
+			body, _ := io.ReadAll(rec.Body)
+			if rec.Code != tt.want {
+				t.Fatalf("got %d, want %d: %s", rec.Code, tt.want, body)
+			}
+			var res struct {
+				Error string `json:"error"`
+			}
+			if err := json.Unmarshal(body, &res); err != nil || res.Error == "" {
+				t.Errorf("no error message in %s", body)
+			}
+		})
+	}
+}
+
+func TestServeImageTooLarge(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 8192, 4096))); err != nil {
+		t.Fatal(err)
+	}
+	rec := serveRequest(newUnpackRequest(t, buf.Bytes(), nil), defaultMaxUploadBytes)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestServeOutputTooLarge(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 512, 768))); err != nil {
+		t.Fatal(err)
+	}
+	// a small image with padding as large as its tiles
+	rec := serveRequest(newUnpackRequest(t, buf.Bytes(), map[string][]string{"padding": {"256"}}), defaultMaxUploadBytes)
+	if rec.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rec.Body.String(), errOutputTooLarge.Error()) {
+		t.Errorf("got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = serveRequest(newUnpackRequest(t, buf.Bytes(), map[string][]string{
+		"padding": {"256"},
+		"layout":  {unpack.Layout16Terrain1},
+	}), defaultMaxUploadBytes)
+	if rec.Code != http.StatusOK {
+		t.Errorf("single layout: got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestServeMalformedForm(t *testing.T) {
+	full := newUnpackRequest(t, exampleImage(t), nil)
+	body, err := io.ReadAll(full.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	boundary := strings.TrimPrefix(full.Header.Get("Content-Type"), "multipart/form-data; boundary=")
+	tests := []struct {
+		name, contentType, body string
+	}{
+		{"not multipart", "text/plain", "image"},
+		{"missing boundary", "multipart/form-data", string(body)},
+		{"unexpected end", full.Header.Get("Content-Type"), string(body[:len(body)/2])},
+		{"malformed part", full.Header.Get("Content-Type"), "--" + boundary + "\r\nno header\r\n\r\nvalue\r\n--" + boundary + "--\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/unpack", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rec := serveRequest(req, defaultMaxUploadBytes)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("got %d: %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestServeInvalidMaxUpload(t *testing.T) {
+	for _, limit := range []string{"0", "-1"} {
+		// the limit is checked before the server starts listening
+		err := serve(context.Background(), map[string][]string{maxUploadKey: {limit}, addrKey: {"127.0.0.1:0"}})
+		if !errors.Is(err, errInvalidMaxUpload) {
+			t.Errorf("-max %s: got %v", limit, err)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image/png"
+	"io"
+	"os"
+
+	"github.com/krylphi/autotiler/internal/exporter"
+	"github.com/krylphi/autotiler/internal/output"
+	"github.com/krylphi/autotiler/internal/unpack"
+)
+
+// stdio is the file name which stands for stdin in -in and for stdout in -o.
+const stdio = "-"
+
+// formatKey selects the format of tile sets written to stdout.
+const formatKey = "f"
+
+// formatTar is the tar archive format of tile sets written to stdout. Other formats are shared with serve.
+const formatTar = "tar"
+
+var (
+	errStdinOnce         = errors.New("stdin can be passed to -in only once")
+	errStdoutSingleInput = errors.New("only a single input can be written to stdout")
+)
+
+// openInput opens the given file or returns stdin if the name is "-".
+func openInput(inputFile string) (io.ReadCloser, error) {
+	if inputFile == stdio {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(inputFile)
+}
+
+// checkStdio checks that stdin is read at most once and that stdout is written only for a single input.
+func checkStdio(inFiles, outFiles []string) error {
+	stdinCount := 0
+	for _, f := range inFiles {
+		if f == stdio {
+			stdinCount++
+		}
+	}
+	if stdinCount > 1 {
+		return errStdinOnce
+	}
+	for _, f := range outFiles {
+		if f == stdio && len(inFiles) != 1 {
+			return errStdoutSingleInput
+		}
+	}
+	return nil
+}
+
+// streamFormat returns the format passed with -f or the default one: png for a single layout and tar otherwise.
+func streamFormat(args map[string][]string, layouts []string) (string, error) {
+	format := ""
+	if values, ok := args[formatKey]; ok {
+		format = values[0]
+	}
+	switch {
+	case format == "" && len(layouts) == 1:
+		return formatPNG, nil
+	case format == "":
+		return formatTar, nil
+	case format == formatPNG && len(layouts) != 1:
+		return "", fmt.Errorf("-%s: %w", formatKey, errPNGSingleLayout)
+	case format != formatPNG && format != formatTar && format != formatZip:
+		return "", fmt.Errorf("-%s: %w: %s", formatKey, errUnknownFormat, format)
+	}
+	return format, nil
+}
+
+// writeStream generates tile sets of the given layouts and writes them to w.
+// Layouts are generated concurrently by a pool of workers.
+//
+// Parameters:
+// - ctx: The context which cancels generation.
+// - w: The writer, usually stdout.
+// - unpacker: The initialized unpacker of a 2x3 tile set.
+// - layouts: Names of the layouts to generate.
+// - padding: The padding of every tile in px the unpacker was created with.
+// - format: png to write the image of a single layout, tar or zip to write images of all layouts as an archive.
+// - workers: The maximum number of layouts generated at once.
+//
+// Returns:
+// - An error if any layout fails or writing fails. Nothing is written if generation fails.
+func writeStream(
+	ctx context.Context, w io.Writer, unpacker *unpack.Unpacker, layouts []string, padding int, format string, workers int,
+) error {
+	outputs := make([]*output.Tileset, len(layouts))
+	err := runJobs(ctx, len(layouts), workers, func(i int) error {
+		out, err := output.Generate(ctx, unpacker, layouts[i:i+1], padding)
+		if err != nil {
+			return fmt.Errorf("%s: %w", layouts[i], err)
+		}
+		outputs[i] = out[0]
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	switch format {
+	case formatPNG:
+		if len(outputs) != 1 {
+			return errPNGSingleLayout
+		}
+		return png.Encode(w, outputs[0].Image)
+	case formatZip:
+		return output.WriteZip(w, outputs, nil)
+	case formatTar:
+		return writeTar(w, outputs, nil)
+	}
+	return fmt.Errorf("%w: %s", errUnknownFormat, format)
+}
+
+// writeTar writes the images of the tile sets and files of the exporters to a tar archive.
+// Files are named the same way as by output.WriteZip.
+func writeTar(w io.Writer, outputs []*output.Tileset, exporters []exporter.Exporter) error {
+	archive := tar.NewWriter(w)
+	add := func(name string, write func(w io.Writer) error) error {
+		// tar headers need the size, so files are encoded in memory first
+		var buf bytes.Buffer
+		if err := write(&buf); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		err := archive.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0o644,
+			Size:     int64(buf.Len()),
+			Typeflag: tar.TypeReg,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = archive.Write(buf.Bytes())
+		return err
+	}
+	for _, out := range outputs {
+		err := add(out.ImageName(), func(w io.Writer) error {
+			return png.Encode(w, out.Image)
+		})
+		if err != nil {
+			return err
+		}
+		for _, e := range exporters {
+			err := add(out.FileName(e), func(w io.Writer) error {
+				return e.Export(w, out.Description)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return archive.Close()
+}
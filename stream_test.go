@@ -0,0 +1,163 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"image/png"
+	"io"
+	"testing"
+
+	"github.com/krylphi/autotiler/internal/unpack"
+)
+
+func TestCheckStdio(t *testing.T) {
+	tests := []struct {
+		name      string
+		in, out   []string
+		wantError error
+	}{
+		{"files", []string{"a.png", "b.png"}, []string{"a_out.png"}, nil},
+		{"stdin and stdout", []string{stdio}, []string{stdio}, nil},
+		{"stdin with files", []string{"a.png", stdio}, nil, nil},
+		{"stdin twice", []string{stdio, stdio}, nil, errStdinOnce},
+		{"stdout for several inputs", []string{"a.png", "b.png"}, []string{stdio}, errStdoutSingleInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkStdio(tt.in, tt.out); !errors.Is(err, tt.wantError) {
+				t.Errorf("got %v, want %v", err, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestStreamFormat(t *testing.T) {
+	one := []string{unpack.Layout28}
+	two := []string{unpack.Layout16Terrain1, unpack.Layout16Terrain2}
+	tests := []struct {
+		name      string
+		format    []string
+		layouts   []string
+		want      string
+		wantError error
+	}{
+		{"default single layout", nil, one, formatPNG, nil},
+		{"default several layouts", nil, two, formatTar, nil},
+		{"zip", []string{formatZip}, one, formatZip, nil},
+		{"png for several layouts", []string{formatPNG}, two, "", errPNGSingleLayout},
+		{"unknown", []string{"gif"}, one, "", errUnknownFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := map[string][]string{}
+			if tt.format != nil {
+				args[formatKey] = tt.format
+			}
+			got, err := streamFormat(args, tt.layouts)
+			if got != tt.want || !errors.Is(err, tt.wantError) {
+				t.Errorf("got %q, %v, want %q, %v", got, err, tt.want, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestWriteStream(t *testing.T) {
+	unpacker, err := loadUnpacker(exampleSource, 0, source2x3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layouts := []string{unpack.Layout16Terrain1, unpack.Layout28, unpack.Layout48Terrain2}
+	wantNames := []string{"16x1_terrain1.png", "14x2.png", "12x4_terrain2.png"}
+
+	t.Run("png", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := writeStream(context.Background(), &buf, unpacker, layouts[1:2], 0, formatPNG, 2); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := png.Decode(&buf); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("tar", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := writeStream(context.Background(), &buf, unpacker, layouts, 0, formatTar, 2); err != nil {
+			t.Fatal(err)
+		}
+		archive := tar.NewReader(&buf)
+		for _, want := range wantNames {
+			h, err := archive.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if h.Name != want {
+				t.Errorf("got file %s, want %s", h.Name, want)
+			}
+			if _, err := png.Decode(archive); err != nil {
+				t.Errorf("%s: %v", h.Name, err)
+			}
+		}
+		if _, err := archive.Next(); !errors.Is(err, io.EOF) {
+			t.Errorf("unexpected files after %v: %v", wantNames, err)
+		}
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := writeStream(context.Background(), &buf, unpacker, layouts, 0, formatZip, 2); err != nil {
+			t.Fatal(err)
+		}
+		archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(archive.File) != len(wantNames) {
+			t.Fatalf("got %d files, want %d", len(archive.File), len(wantNames))
+		}
+		for i, f := range archive.File {
+			if f.Name != wantNames[i] {
+				t.Errorf("got file %s, want %s", f.Name, wantNames[i])
+			}
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		var buf bytes.Buffer
+		err := writeStream(ctx, &buf, unpacker, layouts, 0, formatTar, 2)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v, want %v", err, context.Canceled)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("%d bytes written after cancellation", buf.Len())
+		}
+	})
+}
@@ -0,0 +1,193 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/krylphi/autotiler/internal/exporter"
+	"github.com/krylphi/autotiler/internal/output"
+	"github.com/krylphi/autotiler/internal/unpack"
+)
+
+const (
+	pairKey        = "pair"
+	terrainsKey    = "terrains"
+	approximateKey = "approximate"
+)
+
+// defaultTerrainsOutput is the image written by the terrains command without -o.
+const defaultTerrainsOutput = "terrains.local.png"
+
+var (
+	errMissingPairs       = errors.New("missing terrain pairs")
+	errInvalidPair        = errors.New("invalid terrain pair")
+	errInvalidApproximate = errors.New("invalid approximate value")
+)
+
+// terrainPair is a 2x3 tile set of two terrains passed with --pair <terrain1>,<terrain2>=<file>.
+type terrainPair struct {
+	terrain1, terrain2, file string
+}
+
+// parseTerrainPairs returns 2x3 tile sets passed with --pair.
+func parseTerrainPairs(args map[string][]string) ([]terrainPair, error) {
+	values, ok := args[pairKey]
+	if !ok {
+		return nil, errMissingPairs
+	}
+	res := make([]terrainPair, 0, len(values))
+	for _, value := range values {
+		names, file, ok := strings.Cut(value, "=")
+		terrain1, terrain2, ok2 := strings.Cut(names, ",")
+		if !ok || !ok2 || file == "" {
+			return nil, fmt.Errorf("--%s: %w: %s", pairKey, errInvalidPair, value)
+		}
+		res = append(res, terrainPair{terrain1: terrain1, terrain2: terrain2, file: file})
+	}
+	return res, nil
+}
+
+// pairTerrains returns terrains passed with --terrains <name>,<name>,... or terrains of the pairs
+// in the order they first appear.
+func pairTerrains(args map[string][]string, pairs []terrainPair) []string {
+	if values, ok := args[terrainsKey]; ok {
+		return strings.Split(values[0], ",")
+	}
+	var res []string
+	seen := make(map[string]bool)
+	for _, pair := range pairs {
+		for _, name := range []string{pair.terrain1, pair.terrain2} {
+			if !seen[name] {
+				seen[name] = true
+				res = append(res, name)
+			}
+		}
+	}
+	return res
+}
+
+// parseApproximate reports whether tiles which can only be approximated are allowed with --approximate true.
+func parseApproximate(values []string) (bool, error) {
+	if len(values) == 0 {
+		return false, nil
+	}
+	approximate, err := strconv.ParseBool(values[0])
+	if err != nil {
+		return false, fmt.Errorf("--%s: %w: %s", approximateKey, errInvalidApproximate, values[0])
+	}
+	return approximate, nil
+}
+
+// terrains generates the corner Wang tile set of several terrains from 2x3 tile sets of pairs of them passed with --pair.
+// The image is written to -o and files of the manifest and exporters passed with --exporter next to it.
+func terrains(ctx context.Context, args map[string][]string) error {
+	pairs, err := parseTerrainPairs(args)
+	if err != nil {
+		return err
+	}
+	padding, err := parsePadding(args)
+	if err != nil {
+		return err
+	}
+	exporters, err := output.Exporters(append([]string{"manifest"}, args[exporterKey]...))
+	if err != nil {
+		return err
+	}
+	approximate, err := parseApproximate(args[approximateKey])
+	if err != nil {
+		return err
+	}
+	outputFile := defaultTerrainsOutput
+	if values, ok := args[outKey]; ok {
+		outputFile = values[0]
+	}
+	names := pairTerrains(args, pairs)
+	multi := unpack.NewMultiTerrain(names, padding)
+	multi.SetApproximate(approximate)
+	for _, pair := range pairs {
+		img, err := decodeImage(pair.file)
+		if err != nil {
+			return err
+		}
+		if err := multi.AddPack(pair.terrain1, pair.terrain2, img); err != nil {
+			return fmt.Errorf("%s: %w", pair.file, err)
+		}
+	}
+	img, err := multi.Generate(ctx, nil)
+	if err != nil {
+		return err
+	}
+	ts := exporter.NewCornerWangTileset(names, filepath.Base(outputFile), img.Rect.Dx(), img.Rect.Dy(), padding)
+	return writeTilesetFiles(outputFile, img, ts, exporters)
+}
+
+// writeTilesetFiles writes the tile set image and files of the exporters named after it.
+func writeTilesetFiles(outputFile string, img *image.NRGBA, ts *exporter.Tileset, exporters []exporter.Exporter) error {
+	if err := writePNG(outputFile, img); err != nil {
+		return err
+	}
+	base := strings.TrimSuffix(outputFile, filepath.Ext(outputFile))
+	for _, e := range exporters {
+		if err := writeExport(base+e.Ext(), e, ts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writePNG encodes the image to the file.
+func writePNG(name string, img image.Image) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return f.Close()
+}
+
+// writeExport writes the tile set description of the exporter to the file.
+func writeExport(name string, e exporter.Exporter, ts *exporter.Tileset) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := e.Export(f, ts); err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return f.Close()
+}
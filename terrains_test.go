@@ -0,0 +1,126 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/krylphi/autotiler/internal/exporter"
+	"github.com/krylphi/autotiler/internal/unpack"
+)
+
+func TestTerrains(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "terrains.png")
+	args := map[string][]string{
+		pairKey: {
+			"grass,dirt=" + exampleSource,
+			"dirt,water=" + exampleSource,
+			"grass,water=" + exampleSource,
+		},
+		outKey:         {out},
+		exporterKey:    {"tiled"},
+		paddingKey:     {"1"},
+		approximateKey: {"true"},
+	}
+	if err := terrains(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.DecodeConfig(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Width != 9*66 || img.Height != 9*66 {
+		t.Errorf("got %dx%d px, want 9x9 tiles of 66 px", img.Width, img.Height)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "terrains.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ts exporter.Tileset
+	if err := json.Unmarshal(data, &ts); err != nil {
+		t.Fatal(err)
+	}
+	if ts.Image != "terrains.png" || len(ts.Tiles) != 81 || len(ts.Terrains) != 3 || ts.Terrains[1] != "dirt" {
+		t.Errorf("unexpected manifest of %s with terrains %v and %d tiles", ts.Image, ts.Terrains, len(ts.Tiles))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "terrains.tsx")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTerrainsErrors(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "terrains.png")
+	tests := []struct {
+		name string
+		args map[string][]string
+		want error
+	}{
+		{"no pairs", map[string][]string{}, errMissingPairs},
+		{"no file", map[string][]string{pairKey: {"grass,dirt"}}, errInvalidPair},
+		{"single terrain", map[string][]string{pairKey: {"grass=" + exampleSource}}, errInvalidPair},
+		{"missing pair", map[string][]string{
+			pairKey: {"grass,dirt=" + exampleSource, "dirt,water=" + exampleSource},
+			outKey:  {out},
+		}, unpack.ErrMissingPack},
+		{"out of order", map[string][]string{
+			pairKey:     {"grass,dirt=" + exampleSource},
+			terrainsKey: {"dirt,grass"},
+			outKey:      {out},
+		}, unpack.ErrInvalidTerrains},
+		{"approximation not allowed", map[string][]string{
+			pairKey: {"grass,dirt=" + exampleSource, "dirt,water=" + exampleSource, "grass,water=" + exampleSource},
+			outKey:  {out},
+		}, unpack.ErrApproximateTiles},
+		{"invalid approximate", map[string][]string{
+			pairKey:        {"grass,dirt=" + exampleSource},
+			approximateKey: {"maybe"},
+			outKey:         {out},
+		}, errInvalidApproximate},
+		{"godot", map[string][]string{
+			pairKey:     {"grass,dirt=" + exampleSource},
+			exporterKey: {"godot"},
+			outKey:      {out},
+		}, exporter.ErrUnsupportedTileset},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := terrains(context.Background(), tt.args); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 The autotiler authors
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"strconv"
+
+	"github.com/krylphi/autotiler/internal/unpack"
+)
+
+var errSeamMismatch = errors.New("tileset has mismatching seams")
+
+// validate checks seams of blob tile sets passed with -in. A diff image with highlighted mismatching pixels
+// is written for every tile set with mismatching seams.
+func validate(ctx context.Context, args map[string][]string) error {
+	inFiles, ok := args[inKey]
+	if !ok {
+		return errMissingInput
+	}
+	layouts, ok := args[layoutKey]
+	if !ok {
+		return errMissingLayout
+	}
+	layout, err := unpack.NewBlobLayout(layouts[0])
+	if err != nil {
+		return err
+	}
+	opts := unpack.DefaultSeamOptions()
+	opts.Padding, err = parsePadding(args)
+	if err != nil {
+		return err
+	}
+	if tolerances, ok := args[toleranceKey]; ok {
+		tolerance, err := strconv.ParseUint(tolerances[0], 10, 8)
+		if err != nil {
+			return fmt.Errorf("-%s: %w", toleranceKey, err)
+		}
+		opts.Tolerance = uint8(tolerance)
+	}
+	outFiles := args[outKey]
+	failed := false
+	for i, inputFile := range inFiles {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		outputFile := fmt.Sprintf("%d.local.png", i)
+		if len(outFiles) > i {
+			outputFile = outFiles[i]
+		}
+		img, err := decodeImage(inputFile)
+		if err != nil {
+			return err
+		}
+		report, err := unpack.ValidateSeams(img, layout, opts)
+		if err != nil {
+			return fmt.Errorf("%s: %w", inputFile, err)
+		}
+		if len(report.Mismatches) == 0 {
+			continue
+		}
+		failed = true
+		for _, mismatch := range report.Mismatches {
+			side := "right of"
+			if mismatch.Vertical {
+				side = "below"
+			}
+			log.Printf("%s: tile %v does not match tile %v %s it (%d px)\n",
+				inputFile, mismatch.Other, mismatch.Tile, side, mismatch.Pixels)
+		}
+		err = produceTileset(func() (*image.NRGBA, error) {
+			return report.Diff, nil
+		}, outputFile, "seams")
+		if err != nil {
+			return err
+		}
+	}
+	if failed {
+		return errSeamMismatch
+	}
+	return nil
+}